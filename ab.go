@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/beorn7/perks/quantile"
+)
+
+// variantStats are the stats of the requests sent to A or B of '--url-b'.
+type variantStats struct {
+	count    int64
+	errors   int64
+	non2xx   int64
+	latency  Stats
+	quantile *quantile.Stream
+}
+
+func (v *variantStats) add(r *ReportRecord) {
+	v.count++
+	if r.error != "" {
+		v.errors++
+	} else if r.code != "2xx" {
+		v.non2xx++
+	}
+	v.latency.Update(float64(r.cost))
+	v.quantile.Insert(float64(r.cost))
+}
+
+// VariantReport is the outcome of the requests sent to A or B of '--url-b'.
+type VariantReport struct {
+	Name   string
+	URL    string
+	Count  int64
+	Errors int64
+	// Non2xx counts the responses other than 2xx
+	Non2xx      int64
+	RPS         float64
+	Mean        time.Duration
+	Max         time.Duration
+	Percentiles []*struct {
+		Percentile float64
+		Latency    time.Duration
+	}
+}
+
+// CompareVariants makes the snapshots report the requests sent to urlA and
+// urlB of '--url-b' side by side.
+func (s *StreamReport) CompareVariants(urlA, urlB string) {
+	s.lock.Lock()
+	s.variantURLs = []string{urlA, urlB}
+	s.variants = map[string]*variantStats{
+		"A": {quantile: quantile.NewTargeted(quantilesTarget)},
+		"B": {quantile: quantile.NewTargeted(quantilesTarget)},
+	}
+	s.lock.Unlock()
+}
+
+// variantReports must be called with the lock held, their percentiles given
+// once rc is queried.
+func (s *StreamReport) variantReports(rc *reportCopy) []*VariantReport {
+	elapsed := rc.elapsed
+	var reports []*VariantReport
+	for i, name := range []string{"A", "B"} {
+		v := s.variants[name]
+		vr := &VariantReport{
+			Name:   name,
+			URL:    s.variantURLs[i],
+			Count:  v.count,
+			Errors: v.errors,
+			Non2xx: v.non2xx,
+			RPS:    float64(v.count) / elapsed.Seconds(),
+			Mean:   time.Duration(v.latency.Mean()),
+			Max:    time.Duration(v.latency.max),
+		}
+		if v.count > 0 {
+			rc.queryLater(v.quantile, quantilesTarget, func(q *quantileCopy) {
+				for i, p := range queryQuantiles(q) {
+					vr.Percentiles = append(vr.Percentiles, &struct {
+						Percentile float64
+						Latency    time.Duration
+					}{quantiles[i], time.Duration(p)})
+				}
+			})
+		}
+		reports = append(reports, vr)
+	}
+	return reports
+}
+
+// variantMetrics are the metrics of A and B side by side, for
+// buildCompareTable.
+func variantMetrics(a, b *VariantReport, useSeconds bool) []*compareMetric {
+	count := func(v float64) string { return fmt.Sprintf("%d", int64(v)) }
+	percent := func(v float64) string { return fmt.Sprintf("%.2f%%", v) }
+	duration := func(v float64) string { return durationToString(time.Duration(v), useSeconds) }
+	// the shares of the requests, as A and B get the ones of the split
+	share := func(n int64, vr *VariantReport) float64 {
+		if vr.Count == 0 {
+			return 0
+		}
+		return float64(n) / float64(vr.Count) * 100
+	}
+	metrics := []*compareMetric{
+		{name: "Count", a: float64(a.Count), b: float64(b.Count), neutral: true, format: count},
+		{name: "RPS", a: a.RPS, b: b.RPS, neutral: true, format: rateToString},
+		{name: "Errors", a: share(a.Errors, a), b: share(b.Errors, b), format: percent},
+		{name: "Non-2xx", a: share(a.Non2xx, a), b: share(b.Non2xx, b), format: percent},
+		{name: "Mean", a: float64(a.Mean), b: float64(b.Mean), format: duration},
+	}
+	if len(a.Percentiles) == len(b.Percentiles) {
+		for i, p := range a.Percentiles {
+			metrics = append(metrics, &compareMetric{name: percentileName(p.Percentile),
+				a: float64(p.Latency), b: float64(b.Percentiles[i].Latency), format: duration})
+		}
+	}
+	return append(metrics, &compareMetric{name: "Max", a: float64(a.Max), b: float64(b.Max), format: duration})
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AbortCondition is a bound of '--abort-on', like error-rate>50%:10s: the run
+// is aborted once every second of the window went beyond it.
+type AbortCondition struct {
+	*sloCondition
+	Window time.Duration
+	text   string
+}
+
+// ParseAbortConditions parses the comma-separated conditions of '--abort-on',
+// on the error rate, percentiles, mean or max latency of every second, each
+// held for a window, like error-rate>50%:10s,p99>2s:30s.
+func ParseAbortConditions(s string) ([]*AbortCondition, error) {
+	var conds []*AbortCondition
+	for _, f := range strings.Split(s, ",") {
+		i := strings.LastIndex(f, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid abort condition %q, expected a condition and a window like error-rate>50%%:10s", f)
+		}
+		window, err := time.ParseDuration(strings.TrimSpace(f[i+1:]))
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid abort condition %q, expected a window like 10s", f)
+		}
+		c, err := parseCondition(f[:i], ">", "abort condition")
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, &AbortCondition{sloCondition: c, Window: window, text: strings.TrimSpace(f)})
+	}
+	return conds, nil
+}
+
+// trackAbortPercentiles charts the percentiles of the conditions, as those of
+// every second are the charted ones.
+func trackAbortPercentiles(conds []*AbortCondition) {
+	slo := make([]*sloCondition, len(conds))
+	for i, c := range conds {
+		slo[i] = c.sloCondition
+	}
+	trackSLOPercentiles(slo)
+	qs := append([]float64{}, chartPercentiles...)
+	for _, c := range conds {
+		if c.quantile != 0 && chartPercentileIndex(qs, c.quantile) < 0 {
+			qs = append(qs, c.quantile)
+		}
+	}
+	sort.Float64s(qs)
+	chartPercentiles = qs
+}
+
+func chartPercentileIndex(qs []float64, q float64) int {
+	for i, v := range qs {
+		if v == q {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *AbortCondition) String() string {
+	return c.text
+}
+
+// secondValue returns the value of the metric bound by the condition over the
+// second of cr.
+func (c *AbortCondition) secondValue(cr *ChartsReport) float64 {
+	switch {
+	case c.name == "errors":
+		if cr.RPS == 0 {
+			return 0
+		}
+		return cr.Errors / cr.RPS * 100
+	case c.name == "mean":
+		return cr.Latency.Mean()
+	case c.name == "max":
+		return cr.Latency.max
+	}
+	if i := chartPercentileIndex(chartPercentiles, c.quantile); i >= 0 && i < len(cr.Percentiles) {
+		return cr.Percentiles[i]
+	}
+	return 0
+}
+
+// WatchAbort checks the conditions against every second of the run, calling
+// abort with the first one held for its window and the value it last went to,
+// unless doneChan is closed first. The seconds without any request leave the
+// conditions as they were.
+func WatchAbort(conds []*AbortCondition, charts func() *ChartsReport, abort func(c *AbortCondition, v float64), doneChan <-chan struct{}) {
+	ticker := time.NewTicker(stableCheckInterval)
+	defer ticker.Stop()
+	// since is when every condition started to be held, zero if it isn't
+	since := make([]time.Time, len(conds))
+	for {
+		select {
+		case <-doneChan:
+			return
+		case now := <-ticker.C:
+			cr := charts()
+			if cr == nil {
+				continue
+			}
+			for i, c := range conds {
+				v := c.secondValue(cr)
+				if v <= c.limit {
+					since[i] = time.Time{}
+					continue
+				}
+				if since[i].IsZero() {
+					// the second the value covers
+					since[i] = now.Add(-time.Second)
+				}
+				if now.Sub(since[i]) >= c.Window {
+					abort(c, v)
+					return
+				}
+			}
+		}
+	}
+}
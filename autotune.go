@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// autotuneReached is the share of the rate of a stage its RPS must reach to
+// be sustained, the connections falling behind otherwise.
+const autotuneReached = 0.95
+
+// sloCondition is a bound of the SLO of 'autotune', like p99<100ms.
+type sloCondition struct {
+	name string
+	// quantile is that of a percentile, 0 for the mean, max and errors
+	quantile float64
+	// limit is a latency, or a percent of the requests for errors
+	limit float64
+}
+
+// ParseSLO parses the SLO of 'autotune', comma-separated conditions on the
+// percentiles, mean or max latency and percent of errors, like
+// p99<100ms,errors<1%.
+func ParseSLO(s string) ([]*sloCondition, error) {
+	var conds []*sloCondition
+	for _, f := range strings.Split(s, ",") {
+		c, err := parseCondition(f, "<", "slo")
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, c)
+	}
+	return conds, nil
+}
+
+// parseCondition parses a condition f comparing a metric to a limit with op,
+// like p99<100ms or errors>1%, what naming it in the errors.
+func parseCondition(f, op, what string) (*sloCondition, error) {
+	n := strings.SplitN(strings.TrimSpace(f), op, 2)
+	if len(n) != 2 {
+		return nil, fmt.Errorf("invalid %s %q, expected conditions like p99%s100ms or errors%s1%%", what, f, op, op)
+	}
+	c := &sloCondition{name: strings.ToLower(strings.TrimSpace(n[0]))}
+	if c.name == "error-rate" {
+		c.name = "errors"
+	}
+	value := strings.TrimSpace(n[1])
+	switch {
+	case c.name == "errors":
+		v, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil || v < 0 || v > 100 {
+			return nil, fmt.Errorf("invalid %s %q, expected a percent of errors", what, f)
+		}
+		c.limit = v
+		return c, nil
+	case c.name == "mean" || c.name == "max":
+	case strings.HasPrefix(c.name, "p"):
+		p, err := strconv.ParseFloat(c.name[1:], 64)
+		if err != nil || p <= 0 || p >= 100 {
+			return nil, fmt.Errorf("invalid %s %q, expected a percentile like p99", what, f)
+		}
+		c.quantile = percentileQuantile(p)
+	default:
+		return nil, fmt.Errorf("invalid %s %q, expected a percentile, mean, max or errors", what, f)
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("invalid %s %q, expected a latency like 100ms", what, f)
+	}
+	c.limit = float64(d)
+	return c, nil
+}
+
+// trackSLOPercentiles adds the percentiles of the SLO to those tracked.
+func trackSLOPercentiles(conds []*sloCondition) {
+	qs := append([]float64{}, quantiles...)
+	for _, c := range conds {
+		if c.quantile == 0 {
+			continue
+		}
+		tracked := false
+		for _, q := range qs {
+			tracked = tracked || q == c.quantile
+		}
+		if !tracked {
+			qs = append(qs, c.quantile)
+		}
+	}
+	if len(qs) > len(quantiles) {
+		sort.Float64s(qs)
+		setPercentiles(qs)
+	}
+}
+
+// value returns the value of the metric bound by the condition in rs.
+func (c *sloCondition) value(rs *SnapshotReport) float64 {
+	switch {
+	case c.name == "errors":
+		var errs int64
+		for _, n := range rs.Errors {
+			errs += n
+		}
+		if rs.Count == 0 {
+			return 0
+		}
+		return float64(errs) / float64(rs.Count) * 100
+	case c.name == "mean":
+		return float64(rs.Stats.Mean)
+	case c.name == "max":
+		return float64(rs.Stats.Max)
+	}
+	for _, p := range rs.Percentiles {
+		if p.Percentile == c.quantile {
+			return float64(p.Latency)
+		}
+	}
+	return 0
+}
+
+func (c *sloCondition) format(v float64, useSeconds bool) string {
+	if c.name == "errors" {
+		return fmt.Sprintf("%.2f%%", v)
+	}
+	return durationToString(time.Duration(v), useSeconds)
+}
+
+// autotuneStage is the outcome of the run at the rate of a stage.
+type autotuneStage struct {
+	Rate float64
+	RPS  float64
+	// Values are those of the conditions of the SLO in order
+	Values []float64
+	// Missed is why the stage didn't sustain the rate, "" if it did
+	Missed string
+}
+
+func newAutotuneStage(rate float64, rs *SnapshotReport, conds []*sloCondition, useSeconds bool) *autotuneStage {
+	st := &autotuneStage{Rate: rate, RPS: rs.RPS}
+	var missed []string
+	for _, c := range conds {
+		v := c.value(rs)
+		st.Values = append(st.Values, v)
+		if v >= c.limit {
+			missed = append(missed, fmt.Sprintf("%s %s", c.name, c.format(v, useSeconds)))
+		}
+	}
+	if rs.RPS < rate*autotuneReached {
+		missed = append(missed, fmt.Sprintf("RPS %s", rateToString(rs.RPS)))
+	}
+	st.Missed = strings.Join(missed, ", ")
+	return st
+}
+
+// limitRate paces the requests of the run at rate.
+func (r *Requester) limitRate(rate float64) {
+	if r.limiter == nil {
+		r.gate = NewConcurrencyGate(r.concurrency)
+		r.limiter = NewRateLimiter(r.clientOpt.arrival)
+	}
+	r.limiter.SetRate(rate)
+}
+
+// runAutotuneStage runs the benchmark of the options at a rate.
+func runAutotuneStage(rate float64) *SnapshotReport {
+	requester, _ := buildRequester()
+	requester.limitRate(rate)
+	if *warmup > 0 {
+		requester.Warmup()
+	} else if *prewarm {
+		_, _, _ = requester.Prewarm()
+	}
+	go requester.Run()
+	report := NewStreamReport()
+	if *burnIn {
+		report.BurnIn()
+	}
+	report.SetChecks(requester.clientOpt.checks)
+	go report.Collect(requester.RecordChan())
+	<-report.Done()
+	return report.Snapshot()
+}
+
+// runAutotune searches the highest rate sustained within the SLO, doubling
+// the rate from startRate until a stage misses it, then bisecting between the
+// last stage sustained and the first missed until they are within precision
+// percent of each other, in maxStages at most.
+func runAutotune(conds []*sloCondition, slo string, startRate, maxRate, precision float64, maxStages int) {
+	if *duration <= 0 {
+		errAndExit("autotune requires a duration for every stage")
+		return
+	}
+	if *schedule != "" || *cooldown > 0 || *sse {
+		errAndExit("can not use schedule, cooldown or sse with autotune")
+		return
+	}
+	if startRate <= 0 || (maxRate > 0 && maxRate < startRate) {
+		errAndExit("start-rate must be positive and max-rate above it")
+		return
+	}
+	trackSLOPercentiles(conds)
+	// the Web UI and its control endpoints follow a single run
+	*chartsListenAddr = ""
+	if *waitReady != "" {
+		fmt.Printf("Waiting for %s to be ready...\n\n", *waitReady)
+		requester, _ := buildRequester()
+		if err := requester.WaitReady(*waitReady, *waitTimeout); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+	}
+	fmt.Printf("Autotuning %s for %s per stage using %d connection(s), under %s.\n\n",
+		strings.Join(*urls, ", "), duration.String(), *concurrency, slo)
+
+	// stop the search along with the stage running
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	var (
+		stages []*autotuneStage
+		best   *autotuneStage
+		// missed is the lowest rate missed, 0 until one is
+		missed  float64
+		stopped string
+	)
+	rate := startRate
+	for len(stages) < maxStages {
+		if *warmup > 0 {
+			fmt.Printf("Warming up for %s...\n", warmup.String())
+		}
+		st := newAutotuneStage(rate, runAutotuneStage(rate), conds, *seconds)
+		stages = append(stages, st)
+		outcome := "sustained"
+		if st.Missed != "" {
+			outcome = "missed: " + st.Missed
+		}
+		fmt.Printf("Stage %d at %s RPS: %s RPS, %s\n", len(stages), rateToString(rate), rateToString(st.RPS), outcome)
+
+		select {
+		case <-sigs:
+			stopped = "Interrupted."
+		default:
+		}
+		if stopped != "" {
+			break
+		}
+		if st.Missed == "" {
+			best = st
+		} else {
+			missed = rate
+		}
+		switch {
+		case missed == 0 && maxRate > 0 && rate >= maxRate:
+			stopped = fmt.Sprintf("Stopped at max-rate %s RPS, sustained.", rateToString(maxRate))
+		case missed == 0:
+			rate *= 2
+			if maxRate > 0 && rate > maxRate {
+				rate = maxRate
+			}
+			continue
+		case best == nil:
+			stopped = fmt.Sprintf("The SLO was missed at start-rate %s RPS already.", rateToString(startRate))
+		case (missed-best.Rate)/missed*100 <= precision:
+		default:
+			rate = (best.Rate + missed) / 2
+			continue
+		}
+		break
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("\nAutotune:\n")
+	writeBulk(&buf, buildAutotuneTable(stages, conds, *seconds))
+	os.Stdout.Write(buf.Bytes())
+	if stopped != "" {
+		fmt.Printf("\n%s\n", stopped)
+	}
+	if best != nil {
+		fmt.Printf("\nHighest sustainable throughput under %s: %s RPS at a rate of %s\n",
+			slo, rateToString(best.RPS), rateToString(best.Rate))
+	}
+}
+
+// buildAutotuneTable lays out the stages in the order run, with the values
+// of the conditions of the SLO.
+func buildAutotuneTable(stages []*autotuneStage, conds []*sloCondition, useSeconds bool) [][]string {
+	header := []string{"Stage", "Rate", "RPS"}
+	for _, c := range conds {
+		header = append(header, strings.ToUpper(c.name[:1])+c.name[1:])
+	}
+	bulk := [][]string{append(header, "")}
+	aligns := []int{AlignRight, AlignRight, AlignRight}
+	for range conds {
+		aligns = append(aligns, AlignRight)
+	}
+	for i, st := range stages {
+		row := []string{strconv.Itoa(i + 1), rateToString(st.Rate), rateToString(st.RPS)}
+		for k, c := range conds {
+			v := c.format(st.Values[k], useSeconds)
+			if st.Values[k] >= c.limit {
+				v = colorize(v, FgMagentaColor)
+			}
+			row = append(row, v)
+		}
+		outcome := "sustained"
+		if st.Missed != "" {
+			outcome = colorize("missed", FgMagentaColor)
+		}
+		bulk = append(bulk, append(row, outcome))
+	}
+	alignBulk(bulk, append(aligns, AlignLeft)...)
+	return bulk
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// baselineRegressions are the gated metrics which got worse than in the
+// baseline by more than tolerance percent.
+func baselineRegressions(metrics []*compareMetric, tolerance float64) []string {
+	var regressions []string
+	for _, m := range metrics {
+		if !m.gated || m.a == 0 {
+			continue
+		}
+		pct := (m.b/m.a - 1) * 100
+		if m.higher {
+			pct = -pct
+		}
+		if pct > tolerance {
+			regressions = append(regressions, fmt.Sprintf("%s %s -> %s", m.name, m.format(m.a), m.format(m.b)))
+		}
+	}
+	return regressions
+}
+
+// printBaseline prints the final report next to the baseline of '--baseline',
+// returning the regressions past '--baseline-tolerance', nil unless given.
+func printBaseline(w io.Writer, baseline *SavedReport, fileName string, rs *SnapshotReport, tolerance float64, useSeconds bool) []string {
+	metrics := compareMetrics(baseline.Summary, rs, useSeconds)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "\nBaseline: %s %s\n", fileName, baseline.Description)
+	writeBulk(&buf, buildCompareTable(metrics, "Baseline", "Run"))
+	if tolerance <= 0 {
+		_, _ = w.Write(buf.Bytes())
+		return nil
+	}
+	regressions := baselineRegressions(metrics, tolerance)
+	if len(regressions) > 0 {
+		fmt.Fprintf(&buf, "\nRegressed by more than %s%%: %s\n", formatFloat64(tolerance), strings.Join(regressions, ", "))
+	}
+	_, _ = w.Write(buf.Bytes())
+	if regressions == nil {
+		regressions = []string{}
+	}
+	return regressions
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxBodyName caps the length of the inline bodies of '--body-weighted' as
+// named in the report.
+const maxBodyName = 24
+
+// WeightedBody is one of the bodies of '--body-weighted', sent in proportion
+// to its weight and reported apart under its name, the file name or the body
+// itself.
+type WeightedBody struct {
+	Name   string
+	Weight int
+	Bytes  []byte
+}
+
+// ParseWeightedBody parses WEIGHT:BODY, where the body is read from a file
+// if it starts with @ as with '--body'.
+func ParseWeightedBody(spec string) (*WeightedBody, error) {
+	n := strings.SplitN(spec, ":", 2)
+	if len(n) != 2 {
+		return nil, fmt.Errorf("invalid weighted body %q, expected WEIGHT:BODY", spec)
+	}
+	weight, err := strconv.Atoi(n[0])
+	if err != nil || weight <= 0 {
+		return nil, fmt.Errorf("invalid weight of body %q, expected a positive integer", spec)
+	}
+	b := &WeightedBody{Weight: weight}
+	if strings.HasPrefix(n[1], "@") {
+		b.Name = n[1][1:]
+		if b.Bytes, err = ioutil.ReadFile(b.Name); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+	b.Bytes = []byte(n[1])
+	b.Name = n[1]
+	if r := []rune(b.Name); len(r) > maxBodyName {
+		b.Name = string(r[:maxBodyName-3]) + "..."
+	}
+	return b, nil
+}
+
+// ParseBodySize parses SIZE[:zero|random] of '--body-size', returning the
+// size in bytes and whether the bodies are random.
+func ParseBodySize(spec string) (int, bool, error) {
+	sizeSpec, mode := spec, "zero"
+	if i := strings.IndexByte(spec, ':'); i >= 0 {
+		sizeSpec, mode = spec[:i], spec[i+1:]
+	}
+	if mode != "zero" && mode != "random" {
+		return 0, false, fmt.Errorf("invalid body-size %q, expected SIZE[:zero|random]", spec)
+	}
+	size, err := parseSize(sizeSpec)
+	if err != nil {
+		return 0, false, err
+	}
+	if size <= 0 {
+		return 0, false, fmt.Errorf("invalid body-size %q, the size must be positive", spec)
+	}
+	return size, mode == "random", nil
+}
+
+// parseSize parses a size in bytes like 512, 64KB or 1.5MB, the units being
+// powers of 1024 as in the report.
+func parseSize(s string) (int, error) {
+	units := []struct {
+		suffix string
+		scale  float64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+	v, scale := strings.ToUpper(strings.TrimSpace(s)), 1.0
+	for _, u := range units {
+		if strings.HasSuffix(v, u.suffix) {
+			v, scale = strings.TrimSuffix(v, u.suffix), u.scale
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected like 64KB", s)
+	}
+	return int(n * scale), nil
+}
+
+// stdinBody is the body of '--body @-', read once from the standard input as
+// the requester is built again for every level of 'sweep'.
+var stdinBody struct {
+	once   sync.Once
+	bytes  []byte
+	stream func() (io.Reader, error)
+	err    error
+}
+
+// readStdinBody reads the body piped to plow into memory, or with stream
+// copies it to a temporary file streamed from its start by every request, as
+// generated payloads may not fit in memory.
+func readStdinBody(stream bool) ([]byte, func() (io.Reader, error), error) {
+	stdinBody.once.Do(func() {
+		if !stream {
+			stdinBody.bytes, stdinBody.err = ioutil.ReadAll(os.Stdin)
+			return
+		}
+		f, err := ioutil.TempFile("", "plow-body-")
+		if err != nil {
+			stdinBody.err = err
+			return
+		}
+		// gone once plow exits, where a file may be removed while open
+		_ = os.Remove(f.Name())
+		size, err := io.Copy(f, os.Stdin)
+		if err != nil {
+			f.Close()
+			stdinBody.err = err
+			return
+		}
+		stdinBody.stream = func() (io.Reader, error) {
+			return io.NewSectionReader(f, 0, size), nil
+		}
+	})
+	return stdinBody.bytes, stdinBody.stream, stdinBody.err
+}
+
+// ParseWeightedBodies parses the bodies of '--body-weighted', whose names
+// have to tell them apart.
+func ParseWeightedBodies(specs []string) ([]*WeightedBody, error) {
+	var bodies []*WeightedBody
+	names := make(map[string]bool)
+	for _, spec := range specs {
+		b, err := ParseWeightedBody(spec)
+		if err != nil {
+			return nil, err
+		}
+		if names[b.Name] {
+			return nil, fmt.Errorf("duplicate weighted body %q", b.Name)
+		}
+		names[b.Name] = true
+		bodies = append(bodies, b)
+	}
+	return bodies, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// cacheStatuses are the outcomes of a request to a cache, in the printed order.
+var cacheStatuses = []string{"hit", "miss", "unknown"}
+
+// classifyCache tells whether the response was served by a cache from its
+// X-Cache header, like "HIT" or "Miss from cloudfront", or else from its
+// Age header, which caches set to the time the response was stored. The age
+// is returned as well, -1 if the response has none.
+func classifyCache(resp *fasthttp.Response) (string, time.Duration) {
+	age := time.Duration(-1)
+	if v := resp.Header.Peek("Age"); len(v) > 0 {
+		if n, err := strconv.ParseInt(string(bytes.TrimSpace(v)), 10, 64); err == nil && n >= 0 {
+			age = time.Duration(n) * time.Second
+		}
+	}
+	xcache := bytes.ToUpper(resp.Header.Peek("X-Cache"))
+	switch {
+	case bytes.Contains(xcache, []byte("HIT")):
+		return "hit", age
+	case bytes.Contains(xcache, []byte("MISS")):
+		return "miss", age
+	case age > 0:
+		return "hit", age
+	case age == 0:
+		// just fetched from the origin
+		return "miss", age
+	}
+	return "unknown", age
+}
+
+// cacheControl returns the Cache-Control header of the response normalized,
+// or "none".
+func cacheControl(resp *fasthttp.Response) string {
+	v := resp.Header.Peek(fasthttp.HeaderCacheControl)
+	if len(v) == 0 {
+		return "none"
+	}
+	directives := strings.Split(strings.ToLower(string(v)), ",")
+	for i, d := range directives {
+		directives[i] = strings.TrimSpace(d)
+	}
+	return strings.Join(directives, ", ")
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// WireCapture records the raw bytes of the first exchanges of every
+// connection into a file per connection, as seen by the client above TLS.
+type WireCapture struct {
+	dir       string
+	exchanges int
+	connID    int64
+}
+
+func NewWireCapture(dir string, exchanges int) (*WireCapture, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &WireCapture{dir: dir, exchanges: exchanges}, nil
+}
+
+func (wc *WireCapture) Dial(dial fasthttp.DialFunc) fasthttp.DialFunc {
+	return func(addr string) (net.Conn, error) {
+		conn, err := dial(addr)
+		if err != nil {
+			return nil, err
+		}
+		id := atomic.AddInt64(&wc.connID, 1)
+		return &captureConn{
+			Conn:     conn,
+			fileName: filepath.Join(wc.dir, fmt.Sprintf("conn-%d.txt", id)),
+			max:      wc.exchanges,
+		}, nil
+	}
+}
+
+type captureConn struct {
+	net.Conn
+	lock      sync.Mutex
+	fileName  string
+	file      *os.File
+	max       int
+	exchanges int
+	reading   bool
+}
+
+// record appends data to the capture file. An exchange starts with the first
+// write following a read, capturing stops once max exchanges are recorded.
+func (c *captureConn) record(write bool, data []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if write && (c.exchanges == 0 || c.reading) {
+		c.exchanges++
+	}
+	c.reading = !write
+	if c.exchanges == 0 || c.exchanges > c.max {
+		c.closeFile()
+		return
+	}
+	if c.file == nil {
+		file, err := os.Create(c.fileName)
+		if err != nil {
+			c.max = 0
+			return
+		}
+		c.file = file
+	}
+	dir := "<"
+	if write {
+		dir = ">"
+	}
+	fmt.Fprintf(c.file, "### %s %s %d bytes (exchange %d)\n", time.Now().Format(time.RFC3339Nano), dir, len(data), c.exchanges)
+	_, _ = c.file.Write(data)
+	_, _ = c.file.WriteString("\n")
+}
+
+func (c *captureConn) closeFile() {
+	if c.file != nil {
+		c.file.Close()
+		c.file = nil
+	}
+}
+
+func (c *captureConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.record(false, b[:n])
+	}
+	return n, err
+}
+
+func (c *captureConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.record(true, b[:n])
+	}
+	return n, err
+}
+
+func (c *captureConn) Close() error {
+	c.lock.Lock()
+	c.closeFile()
+	c.lock.Unlock()
+	return c.Conn.Close()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+func isPKCS12(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".p12" || ext == ".pfx"
+}
+
+// loadClientCert loads the client certificate of '--cert', either a PKCS#12
+// bundle or a PEM certificate with the PEM key of '--key'. Both the bundle and
+// the key may be encrypted with the passphrase.
+func loadClientCert(certPath, keyPath, passphrase string) (tls.Certificate, error) {
+	if isPKCS12(certPath) {
+		return loadPKCS12(certPath, passphrase)
+	}
+	certPEM, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPEM, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if keyPEM, err = decryptKeyPEM(keyPEM, passphrase); err != nil {
+		return tls.Certificate{}, fmt.Errorf("%s: %v", keyPath, err)
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+func loadPKCS12(path, passphrase string) (tls.Certificate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	blocks, err := pkcs12.ToPEM(data, passphrase)
+	if err != nil {
+		if _, ok := err.(pkcs12.NotImplementedError); ok {
+			// only the legacy 3DES and RC2 bundles are supported, not the AES
+			// ones OpenSSL 3 makes by default
+			return tls.Certificate{}, fmt.Errorf("%s: %v, export the bundle with 'openssl pkcs12 -export -legacy'", path, err)
+		}
+		return tls.Certificate{}, fmt.Errorf("%s: %v", path, err)
+	}
+	var certPEM, keyPEM []byte
+	for _, b := range blocks {
+		if b.Type == "CERTIFICATE" {
+			certPEM = append(certPEM, pem.EncodeToMemory(b)...)
+		} else {
+			keyPEM = append(keyPEM, pem.EncodeToMemory(b)...)
+		}
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// decryptKeyPEM decrypts the key if it's encrypted the legacy PEM way, with a
+// Proc-Type header. Encrypted PKCS#8 keys aren't supported by the standard
+// library and have to be converted, or bundled as PKCS#12.
+func decryptKeyPEM(keyPEM []byte, passphrase string) ([]byte, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return keyPEM, nil
+	}
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		return nil, fmt.Errorf("encrypted PKCS#8 keys are not supported, convert the key with openssl or use a PKCS#12 bundle")
+	}
+	//nolint:staticcheck // legacy PEM encryption is insecure but still issued
+	if !x509.IsEncryptedPEMBlock(block) {
+		return keyPEM, nil
+	}
+	if passphrase == "" {
+		return nil, fmt.Errorf("the key is encrypted, give its passphrase with '--cert-pass'")
+	}
+	//nolint:staticcheck
+	der, err := x509.DecryptPEMBlock(block, []byte(passphrase))
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der}), nil
+}
@@ -5,11 +5,16 @@ import (
 	"embed"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 	"net"
 	"os"
 	"os/exec"
+	"regexp"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
@@ -18,6 +23,7 @@ import (
 	"github.com/go-echarts/go-echarts/v2/charts"
 	"github.com/go-echarts/go-echarts/v2/components"
 	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/go-echarts/go-echarts/v2/render"
 	"github.com/go-echarts/go-echarts/v2/templates"
 	"github.com/valyala/fasthttp"
 )
@@ -29,34 +35,85 @@ var assetsFS embed.FS
 var (
 	assetsPath      = "/echarts/statics/"
 	apiPath         = "/data/"
+	streamPath      = "/data/stream"
 	latencyView     = "latency"
 	rpsView         = "rps"
+	concurrencyView = "concurrency"
+	throughputView  = "throughput"
+	splitView       = "split"
+	ttfbView        = "ttfb"
+	heatmapView     = "heatmap"
+	endpointView    = "endpoint"
+	// chartCodes are the status classes charted along the RPS
+	chartCodes      = []string{"2xx", "3xx", "4xx", "5xx"}
 	timeFormat      = "15:04:05"
 	refreshInterval = time.Second
 )
 
 const (
 	ViewTpl = `
-$(function () { setInterval({{ .ViewID }}_sync, {{ .Interval }}); });
-function {{ .ViewID }}_sync() {
-    $.ajax({
-        type: "GET",
-        url: "{{ .APIPath }}{{ .Route }}",
-        dataType: "json",
-        success: function (result) {
-            let opt = goecharts_{{ .ViewID }}.getOption();
-            let x = opt.xAxis[0].data;
-            x.push(result.time);
-            opt.xAxis[0].data = x;
-            for (let i = 0; i < result.values.length; i++) {
-                let y = opt.series[i].data;
-                y.push({ value: result.values[i] });
-                opt.series[i].data = y;
-                goecharts_{{ .ViewID }}.setOption(opt);
-            }
+$(function () { plowSubscribe("{{ .Route }}", {{ .ViewID }}_sync); });
+function {{ .ViewID }}_sync(result) {
+    let opt = goecharts_{{ .ViewID }}.getOption();
+    let x = opt.xAxis[0].data;
+    x.push(result.time);
+    opt.xAxis[0].data = x;
+    for (let i = 0; i < result.values.length; i++) {
+        let y = opt.series[i].data;
+        y.push({ value: result.values[i] });
+        opt.series[i].data = y;
+        goecharts_{{ .ViewID }}.setOption(opt);
+    }
+}`
+	// SeriesTpl shows the series named by the series query parameter only,
+	// like ?series=p50,p99,errors, and the charts showing one of them.
+	SeriesTpl = `
+<script>
+$(function () {
+    let param = new URLSearchParams(window.location.search).get("series");
+    if (param === null) {
+        return;
+    }
+    let shown = param.toLowerCase().split(",");
+    $(".item").each(function () {
+        let chart = echarts.getInstanceByDom(this);
+        if (!chart) {
+            return;
+        }
+        let any = false;
+        chart.getOption().series.forEach(function (series) {
+            let show = shown.indexOf(series.name.toLowerCase()) >= 0;
+            chart.dispatchAction({ type: show ? "legendSelect" : "legendUnSelect", name: series.name });
+            any = any || show;
+        });
+        if (!any) {
+            $(this).parent().hide();
         }
     });
-}`
+});
+</script>
+`
+	// StreamTpl receives the values of all the views at once from the
+	// stream of '/data/stream', rather than every chart polling its own.
+	StreamTpl = `
+<script>
+var plowViews = {};
+function plowSubscribe(view, update) {
+    plowViews[view] = update;
+}
+$(function () {
+    let source = new EventSource("%s");
+    source.onmessage = function (event) {
+        let result = JSON.parse(event.data);
+        for (let view in plowViews) {
+            if (result.values[view]) {
+                plowViews[view]({ time: result.time, values: result.values[view] });
+            }
+        }
+    };
+});
+</script>
+`
 	PageTpl = `
 {{- define "page" }}
 <!DOCTYPE html>
@@ -65,29 +122,130 @@ function {{ .ViewID }}_sync() {
 <body>
 <p align="center">🚀 <a href="https://github.com/six-ddc/plow"><b>Plow</b></a> %s</p>
 <style> .box { justify-content:center; display:flex; flex-wrap:wrap } </style>
-<div class="box"> {{- range .Charts }} {{ template "base" . }} {{- end }} </div>
+<script> var plowCharts = {}; </script>
+%s<div class="box"> {{- range .Charts }} {{ template "base" . }} {{- end }} </div>
 </body>
 </html>
 {{ end }}
+`
+	// RunsTpl overlays the charts of a run of '--history' picked from the
+	// list of '/api/runs', dotted, on those of the page.
+	RunsTpl = `
+<style> .runs { justify-content:center; display:flex; margin:8px } </style>
+<div class="runs">
+    <label>Compare with <select id="plow-run"><option value="">none</option></select></label>
+</div>
+<script>
+$(function () {
+    let own = {};
+    function overlay(run) {
+        for (let view in plowCharts) {
+            let chart = plowCharts[view];
+            let opt = chart.getOption();
+            if (!(view in own)) {
+                own[view] = opt.series.length;
+            }
+            opt.series = opt.series.slice(0, own[view]);
+            if (run && run.views[view]) {
+                run.views[view].forEach(function (data, i) {
+                    opt.series.push({
+                        name: opt.series[i].name + " (" + run.id + ")",
+                        type: "line",
+                        smooth: true,
+                        lineStyle: { type: "dotted" },
+                        data: data
+                    });
+                });
+            }
+            chart.setOption(opt, true);
+        }
+    }
+    $.getJSON("%[1]s", function (runs) {
+        (runs || []).forEach(function (run) {
+            let started = new Date(run.Started).toLocaleString();
+            $("#plow-run").append($("<option>").val(run.ID).text(run.ID + ", " + started + ", " + run.Description));
+        });
+    });
+    $("#plow-run").change(function () {
+        if (this.value === "") {
+            overlay(null);
+            return;
+        }
+        $.getJSON("%[1]s/" + encodeURIComponent(this.value), overlay);
+    });
+});
+</script>
+`
+	// ControlsTpl adjusts the run through the control endpoints, following
+	// its phase on '/api/status'.
+	ControlsTpl = `
+<style> .controls { justify-content:center; display:flex; gap:16px; align-items:center; margin:8px } </style>
+<div id="plow-controls" class="controls">
+    <label>Concurrency <input id="plow-concurrency" type="range" min="1" value="1"> <span id="plow-concurrency-value"></span></label>
+    <label>Rate <input id="plow-rate" type="number" min="0" step="any" placeholder="no limit" size="8"> req/s</label>
+    <button id="plow-rate-set">Set</button>
+    <button id="plow-pause">Pause</button>
+    <button id="plow-stop">Stop</button>
+    <span id="plow-phase"></span>
+</div>
+<script>
+$(function () {
+    function update(status) {
+        $("#plow-phase").text(status.Phase);
+        $("#plow-pause").text(status.Phase === "paused" ? "Resume" : "Pause");
+        let over = status.Phase !== "running" && status.Phase !== "paused";
+        $("#plow-controls :input").prop("disabled", over);
+    }
+    function post(path, data) {
+        $.ajax({
+            type: "POST",
+            url: path,
+            data: data,
+            dataType: "json",
+            success: update,
+            error: function (xhr) { alert(xhr.responseText); }
+        });
+    }
+    $.getJSON("%[1]s", function (status) {
+        let c = status.Config.Concurrency;
+        $("#plow-concurrency").attr("max", c).val(c);
+        $("#plow-concurrency-value").text(c);
+        update(status);
+    });
+    setInterval(function () { $.getJSON("%[1]s", update); }, %[2]d);
+    $("#plow-concurrency").on("input", function () {
+        $("#plow-concurrency-value").text(this.value);
+    }).on("change", function () {
+        post("%[3]s", { concurrency: this.value });
+    });
+    $("#plow-rate-set").click(function () {
+        post("%[4]s", { rate: $("#plow-rate").val() || 0 });
+    });
+    $("#plow-pause").click(function () {
+        post($(this).text() === "Pause" ? "%[5]s" : "%[6]s");
+    });
+    $("#plow-stop").click(function () {
+        if (confirm("Stop the run?")) {
+            post("%[7]s");
+        }
+    });
+});
+</script>
 `
 )
 
-func (c *Charts) genViewTemplate(vid, route string) string {
-	tpl, err := template.New("view").Parse(ViewTpl)
+func (c *Charts) genViewTemplate(viewTpl, vid, route string) string {
+	tpl, err := template.New("view").Parse(viewTpl)
 	if err != nil {
 		panic("failed to parse template " + err.Error())
 	}
 
 	var d = struct {
-		Interval int
-		APIPath  string
-		Route    string
-		ViewID   string
+		Route  string
+		ViewID string
 	}{
-		Interval: int(refreshInterval.Milliseconds()),
-		APIPath:  apiPath,
-		Route:    route,
-		ViewID:   vid,
+		Route:  route,
+		ViewID: vid,
 	}
 
 	buf := bytes.Buffer{}
@@ -112,21 +270,60 @@ func (c *Charts) newBasicView(route string) *charts.Line {
 			XAxisIndex: []int{0},
 		}),
 	)
-	graph.SetXAxis([]string{}).SetSeriesOptions(charts.WithLineChartOpts(opts.LineChart{Smooth: true}))
-	graph.AddJSFuncs(c.genViewTemplate(graph.ChartID, route))
+	graph.SetXAxis(c.historyTimes()).SetSeriesOptions(charts.WithLineChartOpts(opts.LineChart{Smooth: true}))
+	graph.AddJSFuncs(fmt.Sprintf("plowCharts[%q] = goecharts_%s;", route, graph.ChartID))
+	if c.dataFunc != nil {
+		graph.AddJSFuncs(c.genViewTemplate(ViewTpl, graph.ChartID, route))
+	}
 	return graph
 }
 
+func (c *Charts) historyTimes() []string {
+	times := make([]string, 0, len(c.history))
+	for _, cr := range c.history {
+		times = append(times, cr.Time.Format(timeFormat))
+	}
+	return times
+}
+
+// historySeries returns the n series of view filled with the history, which
+// is empty unless serving a saved report or given by SetHistory.
+func (c *Charts) historySeries(view string, n int) [][]opts.LineData {
+	series := make([][]opts.LineData, n)
+	for i := range series {
+		series[i] = []opts.LineData{}
+	}
+	for _, cr := range c.history {
+		for i, v := range c.chartValues(view, cr) {
+			series[i] = append(series[i], opts.LineData{Value: v})
+		}
+	}
+	return series
+}
+
+// percentileName names the percentile of quantile q like P99.9.
+func percentileName(q float64) string {
+	return "P" + formatFloat64(math.Round(q*1e9)/1e7)
+}
+
 func (c *Charts) newLatencyView() components.Charter {
 	graph := c.newBasicView(latencyView)
+	selected := map[string]bool{"Min": false, "Max": false}
+	for _, q := range chartPercentiles {
+		selected[percentileName(q)] = false
+	}
 	graph.SetGlobalOptions(
 		charts.WithTitleOpts(opts.Title{Title: "Latency"}),
 		charts.WithYAxisOpts(opts.YAxis{Scale: true, AxisLabel: &opts.AxisLabel{Formatter: "{value} ms"}}),
-		charts.WithLegendOpts(opts.Legend{Show: true, Selected: map[string]bool{"Min": false, "Max": false}}),
+		charts.WithLegendOpts(opts.Legend{Show: true, Selected: selected}),
 	)
-	graph.AddSeries("Min", []opts.LineData{}).
-		AddSeries("Mean", []opts.LineData{}).
-		AddSeries("Max", []opts.LineData{})
+	series := c.historySeries(latencyView, 3+len(chartPercentiles))
+	graph.AddSeries("Min", series[0]).
+		AddSeries("Mean", series[1]).
+		AddSeries("Max", series[2])
+	for i, q := range chartPercentiles {
+		graph.AddSeries(percentileName(q), series[3+i])
+	}
 	return graph
 }
 
@@ -135,8 +332,92 @@ func (c *Charts) newRPSView() components.Charter {
 	graph.SetGlobalOptions(
 		charts.WithTitleOpts(opts.Title{Title: "Reqs/sec"}),
 		charts.WithYAxisOpts(opts.YAxis{Scale: true}),
+		// the failures stand out against the RPS, not the successes
+		charts.WithLegendOpts(opts.Legend{Show: true, Selected: map[string]bool{"Errors": false, "2xx": false, "3xx": false}}),
+	)
+	n := 2 + len(chartCodes)
+	if c.schedule {
+		n++
+	}
+	series := c.historySeries(rpsView, n)
+	graph.AddSeries("RPS", series[0])
+	series = series[1:]
+	if c.schedule {
+		graph.AddSeries("Target", series[0], charts.WithLineStyleOpts(opts.LineStyle{Type: "dashed"}))
+		series = series[1:]
+	}
+	graph.AddSeries("Errors", series[0])
+	for i, code := range chartCodes {
+		graph.AddSeries(code, series[1+i])
+	}
+	return graph
+}
+
+func (c *Charts) newThroughputView() components.Charter {
+	graph := c.newBasicView(throughputView)
+	graph.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Throughput"}),
+		// the names of the series are those of '?series='
+		charts.WithYAxisOpts(opts.YAxis{Name: "reads of responses, writes of requests", Scale: true, AxisLabel: &opts.AxisLabel{Formatter: "{value} MB/s"}}),
+		charts.WithLegendOpts(opts.Legend{Show: true}),
+	)
+	series := c.historySeries(throughputView, 2)
+	graph.AddSeries("Reads", series[0]).
+		AddSeries("Writes", series[1])
+	return graph
+}
+
+func (c *Charts) newSplitView() components.Charter {
+	graph := c.newBasicView(splitView)
+	graph.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Latency Split"}),
+		charts.WithYAxisOpts(opts.YAxis{Max: 100, AxisLabel: &opts.AxisLabel{Formatter: "{value} %"}}),
+		charts.WithLegendOpts(opts.Legend{Show: true}),
+	)
+	series := c.historySeries(splitView, 4)
+	graph.AddSeries("DNS", series[0]).
+		AddSeries("Connect", series[1]).
+		AddSeries("TLS", series[2]).
+		AddSeries("Request", series[3])
+	return graph
+}
+
+func (c *Charts) newTTFBView() components.Charter {
+	graph := c.newBasicView(ttfbView)
+	graph.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "TTFB / TTLB"}),
+		charts.WithYAxisOpts(opts.YAxis{Scale: true, AxisLabel: &opts.AxisLabel{Formatter: "{value} ms"}}),
+		charts.WithLegendOpts(opts.Legend{Show: true, Selected: map[string]bool{"TTFB Max": false, "TTLB Max": false}}),
+	)
+	series := c.historySeries(ttfbView, 4)
+	graph.AddSeries("TTFB Mean", series[0]).
+		AddSeries("TTFB Max", series[1]).
+		AddSeries("TTLB Mean", series[2]).
+		AddSeries("TTLB Max", series[3])
+	return graph
+}
+
+func (c *Charts) newEndpointView() components.Charter {
+	graph := c.newBasicView(endpointView)
+	graph.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: percentileName(endpointQuantile) + " Latency by Endpoint"}),
+		charts.WithYAxisOpts(opts.YAxis{Scale: true, AxisLabel: &opts.AxisLabel{Formatter: "{value} ms"}}),
+		charts.WithLegendOpts(opts.Legend{Show: true}),
+	)
+	series := c.historySeries(endpointView, len(c.endpoints))
+	for i, endpoint := range c.endpoints {
+		graph.AddSeries(endpoint, series[i])
+	}
+	return graph
+}
+
+func (c *Charts) newConcurrencyView() components.Charter {
+	graph := c.newBasicView(concurrencyView)
+	graph.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Concurrency"}),
+		charts.WithYAxisOpts(opts.YAxis{Scale: true}),
 	)
-	graph.AddSeries("RPS", []opts.LineData{})
+	graph.AddSeries("Concurrency", c.historySeries(concurrencyView, 1)[0], charts.WithLineChartOpts(opts.LineChart{Step: true}))
 	return graph
 }
 
@@ -149,51 +430,352 @@ type Charts struct {
 	page     *components.Page
 	ln       net.Listener
 	dataFunc func() *ChartsReport
+	history  []*ChartsReport
+	// historyFunc gives the history of the run going on, the page being
+	// laid out again with it on every load
+	historyFunc func() []*ChartsReport
+	pageLock    sync.Mutex
+
+	// schedule adds the load aimed at by '--schedule' to the charts
+	schedule bool
+	// split adds the shares of the latency of SplitLatency
+	split bool
+	// ttfb adds the time to the first and to the last byte of ReportTTFB
+	ttfb bool
+	// heatmap adds the heatmap of the latency, missing from the reports
+	// saved before it was charted
+	heatmap bool
+	// endpoints are those charted apart, of '--group-by' or 'replay'
+	endpoints []string
+
+	statusFunc func() *RunStatus
+	control    RunControl
+	stream     *chartsStream
+
+	// user and pass are required by '--listen-auth'
+	user, pass string
+
+	// runs are those of '--history' to compare with, the run of runID aside
+	runs  *RunHistory
+	runID string
+
+	// summary is shown above the charts of '--report'
+	summary string
+
+	desc string
+	// pageTpl is the template of the page, rendered by pageRender
+	pageTpl string
 }
 
-func NewCharts(ln net.Listener, dataFunc func() *ChartsReport, desc string) (*Charts, error) {
-	templates.PageTpl = fmt.Sprintf(PageTpl, desc)
+func NewCharts(ln net.Listener, dataFunc func() *ChartsReport, desc string, schedule, split, ttfb bool) (*Charts, error) {
+	c := &Charts{ln: ln, dataFunc: dataFunc, schedule: schedule, split: split, ttfb: ttfb, heatmap: true, stream: newChartsStream()}
+	c.initPage(desc)
+	return c, nil
+}
+
+// NewStaticCharts serves the charts of a finished run from its saved history.
+func NewStaticCharts(ln net.Listener, history []*ChartsReport, desc string) (*Charts, error) {
+	c := &Charts{ln: ln, history: history}
+	endpoints := make(map[string]bool)
+	for _, cr := range history {
+		if cr.Target != nil {
+			c.schedule = true
+		}
+		if cr.LatencySplit != nil {
+			c.split = true
+		}
+		if cr.TTFB != nil {
+			c.ttfb = true
+		}
+		if cr.Heatmap != nil {
+			c.heatmap = true
+		}
+		for endpoint := range cr.Endpoints {
+			endpoints[endpoint] = true
+		}
+	}
+	for endpoint := range endpoints {
+		c.endpoints = append(c.endpoints, endpoint)
+	}
+	sort.Strings(c.endpoints)
+	c.initPage(desc)
+	return c, nil
+}
+
+// SetEndpoints charts the latency of the endpoints apart.
+func (c *Charts) SetEndpoints(endpoints []string) {
+	c.endpoints = endpoints
+	c.initPage(c.desc)
+}
+
+// SetHistory makes the page show the charts of the run so far once loaded,
+// rather than from then on only, and serves them on chartsPath.
+func (c *Charts) SetHistory(historyFunc func() []*ChartsReport) {
+	c.historyFunc = historyFunc
+}
+
+// chartsHistory returns the history of the charts, saved or so far.
+func (c *Charts) chartsHistory() []*ChartsReport {
+	if c.historyFunc != nil {
+		return c.historyFunc()
+	}
+	return c.history
+}
+
+// SetStatus serves the state of the run on '/api/status'.
+func (c *Charts) SetStatus(statusFunc func() *RunStatus) {
+	c.statusFunc = statusFunc
+}
+
+// SetAuth requires the requests to the charts to authenticate as user.
+func (c *Charts) SetAuth(user, pass string) {
+	c.user, c.pass = user, pass
+}
+
+// SetRuns lets the page overlay the charts of a run of the history on its
+// own, runID being the one of the charts.
+func (c *Charts) SetRuns(runs *RunHistory, runID string) {
+	c.runs, c.runID = runs, runID
+	c.setPageTpl()
+}
+
+// SetControl lets the run be adjusted through the control endpoints, and from
+// the page once its status is served as well.
+func (c *Charts) SetControl(control RunControl) {
+	c.control = control
+	c.setPageTpl()
+}
+
+// setPageTpl adds the scripts the page needs to the template of the page.
+func (c *Charts) setPageTpl() {
+	scripts := SeriesTpl
+	if c.summary != "" {
+		scripts += fmt.Sprintf(SummaryTpl, escapePageText(c.summary))
+	}
+	if c.dataFunc != nil {
+		scripts += fmt.Sprintf(StreamTpl, streamPath)
+	}
+	if c.control != nil && c.statusFunc != nil {
+		scripts += fmt.Sprintf(ControlsTpl, statusPath, refreshInterval.Milliseconds(),
+			concurrencyPath, ratePath, pausePath, resumePath, stopPath)
+	}
+	if c.runs != nil {
+		scripts += fmt.Sprintf(RunsTpl, runsPath)
+	}
+	// the urls may hold the actions of '--template'
+	c.pageTpl = fmt.Sprintf(PageTpl, escapePageText(c.desc), scripts)
+}
+
+var funcMarks = regexp.MustCompile(`(__f__")|("__f__)|(__f__)`)
+
+// pageRender renders the page like go-echarts does, but with the template of
+// its charts rather than the one shared by all pages.
+type pageRender struct {
+	c *Charts
+}
+
+func (r pageRender) Render(w io.Writer) error {
+	r.c.page.Validate()
+	tpl := render.MustTemplate(render.ModPage, []string{templates.HeaderTpl, templates.BaseTpl, r.c.pageTpl})
+	var buf bytes.Buffer
+	if err := tpl.ExecuteTemplate(&buf, render.ModPage, r.c.page); err != nil {
+		return err
+	}
+	_, err := w.Write(funcMarks.ReplaceAll(buf.Bytes(), nil))
+	return err
+}
+
+func (c *Charts) initPage(desc string) {
+	c.desc = desc
+	c.setPageTpl()
 
-	c := &Charts{ln: ln, dataFunc: dataFunc}
 	c.page = components.NewPage()
+	c.page.Renderer = pageRender{c}
 	c.page.PageTitle = "plow"
 	c.page.AssetsHost = assetsPath
 	c.page.Assets.JSAssets.Add("jquery.min.js")
 	c.page.AddCharts(c.newLatencyView(), c.newRPSView())
+	if len(c.endpoints) > 0 {
+		c.page.AddCharts(c.newEndpointView())
+	}
+	if c.heatmap {
+		c.page.AddCharts(c.newHeatmapView())
+	}
+	if c.ttfb {
+		c.page.AddCharts(c.newTTFBView())
+	}
+	if c.schedule {
+		c.page.AddCharts(c.newConcurrencyView())
+	}
+	c.page.AddCharts(c.newThroughputView())
+	if c.split {
+		c.page.AddCharts(c.newSplitView())
+	}
+}
 
-	return c, nil
+// views are the views of the page.
+func (c *Charts) views() []string {
+	views := []string{latencyView, rpsView}
+	if len(c.endpoints) > 0 {
+		views = append(views, endpointView)
+	}
+	if c.heatmap {
+		views = append(views, heatmapView)
+	}
+	if c.ttfb {
+		views = append(views, ttfbView)
+	}
+	if c.schedule {
+		views = append(views, concurrencyView)
+	}
+	views = append(views, throughputView)
+	if c.split {
+		views = append(views, splitView)
+	}
+	return views
 }
 
-func (c *Charts) Handler(ctx *fasthttp.RequestCtx) {
-	path := string(ctx.Path())
-	if strings.HasPrefix(path, apiPath) {
-		view := path[len(apiPath):]
-		var values []interface{}
-		reportData := c.dataFunc()
-		switch view {
-		case latencyView:
-			if reportData != nil {
-				values = append(values, reportData.Latency.min/1e6)
-				values = append(values, reportData.Latency.Mean()/1e6)
-				values = append(values, reportData.Latency.max/1e6)
+// chartValues returns the values of the series of view, in their order.
+func (c *Charts) chartValues(view string, reportData *ChartsReport) []interface{} {
+	var values []interface{}
+	switch view {
+	case latencyView:
+		if reportData != nil {
+			values = append(values, reportData.Latency.min/1e6)
+			values = append(values, reportData.Latency.Mean()/1e6)
+			values = append(values, reportData.Latency.max/1e6)
+		} else {
+			values = append(values, nil, nil, nil)
+		}
+		for i := range chartPercentiles {
+			// missing from the reports saved before they were charted
+			if reportData != nil && i < len(reportData.Percentiles) {
+				values = append(values, reportData.Percentiles[i]/1e6)
+			} else {
+				values = append(values, nil)
+			}
+		}
+	case rpsView:
+		if reportData != nil {
+			values = append(values, reportData.RPS)
+		} else {
+			values = append(values, nil)
+		}
+		if c.schedule {
+			if reportData != nil && reportData.Target != nil && reportData.Target.Rate > 0 {
+				values = append(values, reportData.Target.Rate)
+			} else {
+				values = append(values, nil)
+			}
+		}
+		if reportData != nil {
+			values = append(values, reportData.Errors)
+		} else {
+			values = append(values, nil)
+		}
+		for _, code := range chartCodes {
+			// missing from the reports saved before they were charted
+			if reportData != nil && reportData.Codes != nil {
+				values = append(values, reportData.Codes[code])
+			} else {
+				values = append(values, nil)
+			}
+		}
+	case throughputView:
+		if reportData != nil {
+			values = append(values, reportData.ReadThroughput, reportData.WriteThroughput)
+		} else {
+			values = append(values, nil, nil)
+		}
+	case splitView:
+		if reportData != nil && reportData.LatencySplit != nil {
+			ls := reportData.LatencySplit
+			values = append(values, ls.DNS*100, ls.Connect*100, ls.TLS*100, ls.Request*100)
+		} else {
+			values = append(values, nil, nil, nil, nil)
+		}
+	case ttfbView:
+		if reportData != nil && reportData.TTFB != nil && reportData.TTLB != nil {
+			values = append(values, reportData.TTFB.Mean()/1e6, reportData.TTFB.max/1e6,
+				reportData.TTLB.Mean()/1e6, reportData.TTLB.max/1e6)
+		} else {
+			values = append(values, nil, nil, nil, nil)
+		}
+	case heatmapView:
+		for i := 0; i <= len(heatmapBounds); i++ {
+			if reportData != nil && i < len(reportData.Heatmap) {
+				values = append(values, reportData.Heatmap[i])
 			} else {
-				values = append(values, nil, nil, nil)
+				values = append(values, nil)
 			}
-		case rpsView:
-			if reportData != nil {
-				values = append(values, reportData.RPS)
+		}
+	case endpointView:
+		for _, endpoint := range c.endpoints {
+			// nil for the seconds the endpoint wasn't requested within
+			if v, ok := reportData.endpoint(endpoint); ok {
+				values = append(values, v/1e6)
 			} else {
 				values = append(values, nil)
 			}
 		}
+	case concurrencyView:
+		if reportData != nil && reportData.Target != nil {
+			values = append(values, reportData.Target.Concurrency)
+		} else {
+			values = append(values, nil)
+		}
+	}
+	return values
+}
+
+func (c *Charts) Handler(ctx *fasthttp.RequestCtx) {
+	path := string(ctx.Path())
+	if isControlPath(path) && c.control != nil {
+		if !ctx.IsPost() {
+			ctx.Error("MethodNotAllowed", fasthttp.StatusMethodNotAllowed)
+			return
+		}
+		if !sameOrigin(ctx) {
+			ctx.Error("Forbidden", fasthttp.StatusForbidden)
+			return
+		}
+		if err := control(c.control, path, ctx); err != nil {
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+			return
+		}
+		if c.statusFunc == nil {
+			ctx.SetStatusCode(fasthttp.StatusNoContent)
+			return
+		}
+		ctx.SetContentType("application/json")
+		_ = json.NewEncoder(ctx).Encode(c.statusFunc())
+	} else if path == statusPath && c.statusFunc != nil {
+		ctx.SetContentType("application/json")
+		_ = json.NewEncoder(ctx).Encode(c.statusFunc())
+	} else if (path == runsPath || strings.HasPrefix(path, runsPath+"/")) && c.runs != nil {
+		c.serveRuns(ctx, path)
+	} else if path == streamPath && c.dataFunc != nil {
+		c.serveStream(ctx)
+	} else if strings.HasPrefix(path, apiPath) && c.dataFunc != nil {
+		view := path[len(apiPath):]
 		metrics := &Metrics{
 			Time:   time.Now().Format(timeFormat),
-			Values: values,
+			Values: c.chartValues(view, c.dataFunc()),
 		}
 		_ = json.NewEncoder(ctx).Encode(metrics)
+	} else if path == chartsPath {
+		c.serveChartsFeed(ctx)
+	} else if path == grafanaPath || strings.HasPrefix(path, grafanaPath+"/") {
+		c.serveGrafana(ctx, path)
 	} else if path == "/" {
 		ctx.SetContentType("text/html")
+		c.pageLock.Lock()
+		if c.historyFunc != nil {
+			c.history = c.historyFunc()
+			c.initPage(c.desc)
+		}
 		_ = c.page.Render(ctx)
+		c.pageLock.Unlock()
 	} else if strings.HasPrefix(path, assetsPath) {
 		ap := path[len(assetsPath):]
 		f, err := assetsFS.Open(ap)
@@ -208,11 +790,27 @@ func (c *Charts) Handler(ctx *fasthttp.RequestCtx) {
 }
 
 func (c *Charts) Serve(open bool) {
+	handler := c.Handler
+	if c.user != "" {
+		handler = basicAuth(handler, c.user, c.pass)
+	}
+	shared := cors.DefaultHandler().CorsMiddleware(handler)
 	server := fasthttp.Server{
-		Handler: cors.DefaultHandler().CorsMiddleware(c.Handler),
+		Handler: func(ctx *fasthttp.RequestCtx) {
+			// the endpoints of /api/ hold the options, urls and headers of
+			// the run, not to be read by the pages of other origins
+			if strings.HasPrefix(string(ctx.Path()), "/api/") {
+				handler(ctx)
+				return
+			}
+			shared(ctx)
+		},
 	}
 	if open {
-		go openBrowser("http://" + c.ln.Addr().String())
+		go openBrowser(listenURL(c.ln))
+	}
+	if c.dataFunc != nil {
+		go c.stream.run(c.dataFunc, c.views(), c.chartValues)
 	}
 	_ = server.Serve(c.ln)
 }
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// maxChecks is the number of checks a run can have, as they are tracked by
+// the bits of a record.
+const maxChecks = 64
+
+// Check is a named assertion of '--check', or of the checks block of
+// '--config', which a response passes if it meets all of its conditions.
+type Check struct {
+	Name string
+	// Status is a status code like 200, or a class like 2xx
+	Status string
+	// Headers are like K:V, the header K having to contain V
+	Headers []string
+	// Body is what the body has to contain
+	Body string
+	// Latency is the latency not to exceed, 0 for any
+	Latency time.Duration
+	// URLs are the URLs the check is attached to, all of them if none
+	URLs []string
+}
+
+// ParseCheck parses a check like
+// 'ok?status=2xx&header=Content-Type:json&body=id&latency=100ms&url=URL', the
+// conditions being URL query encoded.
+func ParseCheck(s string) (*Check, error) {
+	i := strings.IndexByte(s, '?')
+	if i <= 0 {
+		return nil, fmt.Errorf("invalid check %q, expected like 'ok?status=2xx&latency=100ms'", s)
+	}
+	c := &Check{Name: s[:i]}
+	values, err := url.ParseQuery(s[i+1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid check %q: %v", c.Name, err)
+	}
+	for k, vs := range values {
+		if k != "header" && k != "url" && len(vs) > 1 {
+			return nil, fmt.Errorf("check %s: %s given more than once", c.Name, k)
+		}
+		switch k {
+		case "status":
+			c.Status = strings.ToLower(vs[0])
+			if !validCheckStatus(c.Status) {
+				return nil, fmt.Errorf("check %s: invalid status %q, expected a code like 200 or a class like 2xx", c.Name, vs[0])
+			}
+		case "header":
+			for _, h := range vs {
+				if !strings.Contains(h, ":") {
+					return nil, fmt.Errorf("check %s: invalid header %q, expected like K:V", c.Name, h)
+				}
+			}
+			c.Headers = vs
+		case "body":
+			c.Body = vs[0]
+		case "latency":
+			c.Latency, err = time.ParseDuration(vs[0])
+			if err != nil || c.Latency <= 0 {
+				return nil, fmt.Errorf("check %s: invalid latency %q", c.Name, vs[0])
+			}
+		case "url":
+			c.URLs = vs
+		default:
+			return nil, fmt.Errorf("check %s: unknown condition %q, expected status, header, body, latency or url", c.Name, k)
+		}
+	}
+	sort.Strings(c.Headers)
+	return c, nil
+}
+
+func validCheckStatus(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	if strings.HasSuffix(s, "xx") {
+		return s[0] >= '1' && s[0] <= '5'
+	}
+	code, err := strconv.Atoi(s)
+	return err == nil && code >= 100 && code <= 599
+}
+
+// ParseChecks parses the checks of '--check', which must have distinct names
+// and be attached to the URLs of the run.
+func ParseChecks(specs []string, urls []string) ([]*Check, error) {
+	if len(specs) > maxChecks {
+		return nil, fmt.Errorf("too many checks, %d at most", maxChecks)
+	}
+	var checks []*Check
+	names := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		c, err := ParseCheck(spec)
+		if err != nil {
+			return nil, err
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("check %s given more than once", c.Name)
+		}
+		names[c.Name] = true
+		for _, u := range c.URLs {
+			if !containsString(urls, u) {
+				return nil, fmt.Errorf("check %s: %s is not one of the URLs", c.Name, u)
+			}
+		}
+		checks = append(checks, c)
+	}
+	return checks, nil
+}
+
+func containsString(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// attachedTo tells whether the check applies to the requests of u.
+func (c *Check) attachedTo(u string) bool {
+	return len(c.URLs) == 0 || containsString(c.URLs, u)
+}
+
+func (c *Check) pass(resp *fasthttp.Response, body []byte, cost time.Duration) bool {
+	if c.Status != "" {
+		status := strconv.Itoa(resp.StatusCode())
+		if c.Status != status && c.Status != statusClass(resp.StatusCode()) {
+			return false
+		}
+	}
+	for _, h := range c.Headers {
+		i := strings.IndexByte(h, ':')
+		if !bytes.Contains(resp.Header.Peek(h[:i]), []byte(h[i+1:])) {
+			return false
+		}
+	}
+	if c.Body != "" && !bytes.Contains(body, []byte(c.Body)) {
+		return false
+	}
+	return c.Latency <= 0 || cost <= c.Latency
+}
+
+// checkMask is the bits of the checks attached to u.
+func checkMask(checks []*Check, u string) uint64 {
+	var mask uint64
+	for i, c := range checks {
+		if c.attachedTo(u) {
+			mask |= 1 << uint(i)
+		}
+	}
+	return mask
+}
+
+// failedChecks returns the bits of the checks of mask failed by the response.
+func failedChecks(checks []*Check, mask uint64, resp *fasthttp.Response, body []byte, cost time.Duration) uint64 {
+	var failed uint64
+	for i, c := range checks {
+		bit := uint64(1) << uint(i)
+		if mask&bit != 0 && !c.pass(resp, body, cost) {
+			failed |= bit
+		}
+	}
+	return failed
+}
+
+// CheckReport counts the responses which passed and failed a check.
+type CheckReport struct {
+	Name   string
+	Passes int64
+	Fails  int64
+}
+
+func configMap(v interface{}) (map[string]interface{}, bool) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = e
+		}
+		return m, true
+	case map[string]interface{}:
+		return v, true
+	}
+	return nil, false
+}
+
+// configChecks turns the checks block of '--config', the conditions of the
+// checks by their names, into the values of '--check'. The headers and urls
+// conditions may be given as lists, the headers as a map as well.
+func configChecks(v interface{}) ([]string, error) {
+	checks, ok := configMap(v)
+	if !ok {
+		return nil, fmt.Errorf("expected the checks by their names")
+	}
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var out []string
+	for _, name := range names {
+		conds, ok := configMap(checks[name])
+		if !ok {
+			return nil, fmt.Errorf("check %s: expected its conditions by their names", name)
+		}
+		values := url.Values{}
+		for k, cond := range conds {
+			vs, err := configStrings(cond)
+			if err != nil {
+				return nil, fmt.Errorf("check %s: %s: %v", name, k, err)
+			}
+			switch k {
+			case "headers":
+				k = "header"
+			case "urls":
+				k = "url"
+			}
+			values[k] = append(values[k], vs...)
+		}
+		out = append(out, name+"?"+values.Encode())
+	}
+	return out, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The bounds of the memory of the soak tests of '--checkpoint-interval'.
+const (
+	// checkpointMaxErrors is the number of distinct errors reported
+	checkpointMaxErrors = 1000
+	// checkpointMaxHistory is the seconds of history of the charts, an hour
+	checkpointMaxHistory = 3600
+)
+
+// checkpointLoop prints the cumulative report of the run so far to stderr
+// every interval until done, also writing it as the JSON of '--json' to dir
+// if given.
+func checkpointLoop(interval time.Duration, report *StreamReport, desc, dir string, useSeconds bool, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for n := 1; ; n++ {
+		select {
+		case <-ticker.C:
+			saved := report.saved(desc)
+			var buf bytes.Buffer
+			fmt.Fprintf(&buf, "\n%s\nCheckpoint %d after %s, %s\n\n", time.Now().Format(time.RFC3339),
+				n, saved.Summary.Elapsed.Round(time.Second), desc)
+			NewPrinter(0, 0, false, true).formatTableReports(&buf, saved.Summary, true, useSeconds)
+			os.Stderr.WriteString(colorSeq.ReplaceAllString(buf.String(), ""))
+			if dir == "" {
+				continue
+			}
+			fileName := filepath.Join(dir, fmt.Sprintf("plow-checkpoint-%03d.json", n))
+			if err := writeReport(fileName, saved); err != nil {
+				fmt.Fprintf(os.Stderr, "plow: checkpoint: %v\n", err)
+			}
+		case <-done:
+			return
+		}
+	}
+}
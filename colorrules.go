@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// colorNames are the colors of '--color-rules'.
+var colorNames = map[string]int{
+	"red":     FgRedColor,
+	"green":   FgGreenColor,
+	"yellow":  FgYellowColor,
+	"blue":    FgBlueColor,
+	"magenta": FgMagentaColor,
+	"cyan":    FgCyanColor,
+}
+
+// colorRule colors a metric of the realtime table above a limit, like
+// p99>500ms:red.
+type colorRule struct {
+	*sloCondition
+	color int
+}
+
+// colorThresholds are the rules of '--color-rules', none by default.
+var colorThresholds []*colorRule
+
+// ParseColorRules parses the comma-separated rules of '--color-rules', a
+// condition on the percentiles, mean or max latency or percent of errors and
+// a color, like p99>200ms:yellow,p99>500ms:red,errors>1%:red.
+func ParseColorRules(s string) ([]*colorRule, error) {
+	var rules []*colorRule
+	for _, f := range strings.Split(s, ",") {
+		i := strings.LastIndex(f, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid color rule %q, expected a condition and a color like p99>500ms:red", f)
+		}
+		color, ok := colorNames[strings.ToLower(strings.TrimSpace(f[i+1:]))]
+		if !ok {
+			return nil, fmt.Errorf("invalid color rule %q, expected red, green, yellow, blue, magenta or cyan", f)
+		}
+		c, err := parseCondition(f[:i], ">", "color rule")
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, &colorRule{sloCondition: c, color: color})
+	}
+	return rules, nil
+}
+
+// setColorRules colors the realtime table by the rules, tracking the
+// percentiles they bound.
+func setColorRules(rules []*colorRule) {
+	conds := make([]*sloCondition, len(rules))
+	for i, r := range rules {
+		conds[i] = r.sloCondition
+	}
+	trackSLOPercentiles(conds)
+	colorThresholds = rules
+}
+
+// colorByRules colors s, showing the value v of the metric named, or of the
+// percentile of quantile if not 0, in the color of the rule of the highest
+// limit it is above, leaving it as is below them all.
+func colorByRules(s, name string, quantile, v float64) string {
+	var rule *colorRule
+	for _, r := range colorThresholds {
+		if r.quantile != quantile || (quantile == 0 && r.name != name) || v <= r.limit {
+			continue
+		}
+		if rule == nil || r.limit > rule.limit {
+			rule = r
+		}
+	}
+	if rule == nil {
+		return s
+	}
+	return colorize(s, rule.color)
+}
+
+// hasColorRule tells whether a rule bounds the metric named.
+func hasColorRule(name string) bool {
+	for _, r := range colorThresholds {
+		if r.name == name {
+			return true
+		}
+	}
+	return false
+}
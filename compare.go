@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+)
+
+// compareMetric is a metric of 'compare', lower being better unless higher.
+type compareMetric struct {
+	name   string
+	a, b   float64
+	higher bool
+	// neutral shows the change uncolored, as neither better nor worse
+	neutral bool
+	// gated metrics fail the run once worse than '--baseline-tolerance'
+	gated  bool
+	format func(v float64) string
+}
+
+func savedPercentile(rs *SnapshotReport, q float64) (time.Duration, bool) {
+	for _, p := range rs.Percentiles {
+		if p.Percentile == q {
+			return p.Latency, true
+		}
+	}
+	return 0, false
+}
+
+func countErrors(rs *SnapshotReport) int64 {
+	var n int64
+	for _, c := range rs.Errors {
+		n += c
+	}
+	return n
+}
+
+// compareMetrics are the metrics of the reports a and b side by side.
+func compareMetrics(a, b *SnapshotReport, useSeconds bool) []*compareMetric {
+	count := func(v float64) string { return fmt.Sprintf("%d", int64(v)) }
+	duration := func(v float64) string { return durationToString(time.Duration(v), useSeconds) }
+	metrics := []*compareMetric{
+		{name: "Count", a: float64(a.Count), b: float64(b.Count), higher: true, format: count},
+		{name: "Errors", a: float64(countErrors(a)), b: float64(countErrors(b)), format: count},
+		{name: "RPS", a: a.RPS, b: b.RPS, higher: true, gated: true, format: rateToString},
+		{name: "Mean", a: float64(a.Stats.Mean), b: float64(b.Stats.Mean), format: duration},
+	}
+	for _, q := range quantiles {
+		pa, okA := savedPercentile(a, q)
+		pb, okB := savedPercentile(b, q)
+		if okA && okB {
+			metrics = append(metrics, &compareMetric{name: percentileName(q), a: float64(pa), b: float64(pb), gated: true, format: duration})
+		}
+	}
+	return append(metrics, &compareMetric{name: "Max", a: float64(a.Stats.Max), b: float64(b.Stats.Max), format: duration})
+}
+
+// buildCompareTable lays out the metrics with the change from a to b, green
+// if it's for the better.
+func buildCompareTable(metrics []*compareMetric, nameA, nameB string) [][]string {
+	bulk := [][]string{{"", nameA, nameB, "Change"}}
+	for _, m := range metrics {
+		change := "-"
+		if m.a != 0 {
+			pct := (m.b/m.a - 1) * 100
+			change = fmt.Sprintf("%+.2f%%", pct)
+			if pct != 0 && !m.neutral {
+				color := FgGreenColor
+				if (pct > 0) != m.higher {
+					color = FgMagentaColor
+				}
+				change = colorize(change, color)
+			}
+		}
+		bulk = append(bulk, []string{"  " + m.name, m.format(m.a), m.format(m.b), change})
+	}
+	alignBulk(bulk, AlignLeft, AlignRight, AlignRight, AlignRight)
+	return bulk
+}
+
+// runCompare prints the metrics of two reports saved by '--json' side by side.
+func runCompare(fileA, fileB string) {
+	a, err := LoadReport(fileA)
+	if err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	b, err := LoadReport(fileB)
+	if err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	fmt.Printf("A: %s %s\nB: %s %s\n\n", fileA, a.Description, fileB, b.Description)
+	var buf bytes.Buffer
+	writeBulk(&buf, buildCompareTable(compareMetrics(a.Summary, b.Summary, *seconds), "A", "B"))
+	os.Stdout.Write(buf.Bytes())
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/andybalholm/brotli"
+	"github.com/valyala/fasthttp"
+)
+
+var compressEncodings = []string{"gzip", "deflate", "br"}
+
+func newCompressWriter(encoding string, w io.Writer) (io.WriteCloser, error) {
+	switch encoding {
+	case "gzip":
+		return gzip.NewWriter(w), nil
+	case "deflate":
+		return flate.NewWriter(w, flate.DefaultCompression)
+	case "br":
+		return brotli.NewWriter(w), nil
+	}
+	return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+}
+
+func compressBytes(encoding string, b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := newCompressWriter(encoding, &buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(b); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// compressStream wraps a body stream opener so that each stream is compressed
+// on the fly, adding the number of uncompressed bytes to rawBytes.
+func compressStream(encoding string, open func() (io.Reader, error), rawBytes *int64) func() (io.Reader, error) {
+	return func() (io.Reader, error) {
+		src, err := open()
+		if err != nil {
+			return nil, err
+		}
+		pr, pw := io.Pipe()
+		go func() {
+			w, err := newCompressWriter(encoding, pw)
+			if err == nil {
+				var n int64
+				n, err = io.Copy(w, src)
+				atomic.AddInt64(rawBytes, n)
+				if cerr := w.Close(); err == nil {
+					err = cerr
+				}
+			}
+			if c, ok := src.(io.Closer); ok {
+				c.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		return pr, nil
+	}
+}
+
+// decodeBody returns the response body decoded according to its Content-Encoding.
+func decodeBody(resp *fasthttp.Response) ([]byte, error) {
+	switch string(resp.Header.Peek(fasthttp.HeaderContentEncoding)) {
+	case "gzip":
+		return resp.BodyGunzip()
+	case "deflate":
+		return resp.BodyInflate()
+	case "br":
+		return resp.BodyUnbrotli()
+	}
+	return resp.Body(), nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/alecthomas/kingpin.v3-unstable"
+	"gopkg.in/yaml.v2"
+)
+
+// configValues are the options of '--config' by the names of the flags and of
+// the url argument, which the command line overrides.
+var configValues map[string][]string
+
+// configClause makes clause load the file of '--config', from which the
+// options missing from the command line are taken.
+func configClause(app *kingpin.Application, clause *kingpin.Clause) *string {
+	app.Resolver(kingpin.ResolverFunc(func(clause *kingpin.ClauseModel, _ *kingpin.ParseContext) ([]string, error) {
+		return configValues[clause.Name], nil
+	}))
+	return clause.PreAction(func(element *kingpin.ParseElement, _ *kingpin.ParseContext) error {
+		values, positions, err := loadConfig(*element.Value)
+		if err != nil {
+			return err
+		}
+		if name, err := checkConfig(app, values); err != nil {
+			return configError(*element.Value, positions, name, err)
+		}
+		configValues = values
+		return nil
+	}).ExistingFile()
+}
+
+// loadConfig reads a TOML file if named *.toml, a YAML one otherwise. Lists
+// are the values of the repeatable options, and maps the K:V values of the
+// like of '--header'. The checks block holds the named checks of '--check'.
+// The positions are the line:col of the options in the file.
+func loadConfig(fileName string) (map[string][]string, map[string]string, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	isTOML := strings.ToLower(filepath.Ext(fileName)) == ".toml"
+	var raw map[string]interface{}
+	if isTOML {
+		_, err = toml.Decode(string(data), &raw)
+	} else {
+		err = yaml.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	positions := configPositions(string(data), isTOML)
+	values := make(map[string][]string, len(raw))
+	for name, v := range raw {
+		if name == "checks" {
+			continue
+		}
+		if values[name], err = configStrings(v); err != nil {
+			return nil, nil, configError(fileName, positions, name, err)
+		}
+	}
+	// the named checks go along those of '--check'
+	if v, ok := raw["checks"]; ok {
+		checks, err := configChecks(v)
+		if err != nil {
+			return nil, nil, configError(fileName, positions, "checks", err)
+		}
+		values["check"] = append(values["check"], checks...)
+		if _, ok := positions["check"]; !ok {
+			positions["check"] = positions["checks"]
+		}
+	}
+	return values, positions, nil
+}
+
+// configPositions returns the line:col of the top-level keys of a YAML file,
+// or of the keys of a TOML one before its first table but that of the checks.
+func configPositions(data string, isTOML bool) map[string]string {
+	positions := make(map[string]string)
+	for i, line := range strings.Split(data, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+		col := strings.Index(line, trimmed) + 1
+		var key string
+		if isTOML {
+			if trimmed[0] == '[' {
+				if key = strings.Trim(trimmed, "[] \t"); key != "checks" {
+					break
+				}
+			} else if j := strings.Index(trimmed, "="); j > 0 {
+				key = trimmed[:j]
+			}
+		} else if col == 1 && trimmed[0] != '-' {
+			if j := strings.Index(trimmed, ":"); j > 0 {
+				key = trimmed[:j]
+			}
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"'`)
+		if _, ok := positions[key]; key != "" && !ok {
+			positions[key] = fmt.Sprintf("%d:%d", i+1, col)
+		}
+	}
+	return positions
+}
+
+// configError reports err of the option name at its position in fileName.
+func configError(fileName string, positions map[string]string, name string, err error) error {
+	if pos, ok := positions[name]; ok {
+		return fmt.Errorf("%s:%s: %s: %v", fileName, pos, name, err)
+	}
+	return fmt.Errorf("%s: %s: %v", fileName, name, err)
+}
+
+func configStrings(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case []interface{}:
+		var out []string
+		for _, e := range v {
+			s, err := configStrings(e)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, s...)
+		}
+		return out, nil
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = e
+		}
+		return configStrings(m)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var out []string
+		for _, k := range keys {
+			s, err := configStrings(v[k])
+			if err != nil || len(s) != 1 {
+				return nil, fmt.Errorf("invalid value of %s", k)
+			}
+			out = append(out, k+":"+s[0])
+		}
+		return out, nil
+	case string:
+		return []string{v}, nil
+	case bool:
+		return []string{strconv.FormatBool(v)}, nil
+	case int:
+		return []string{strconv.Itoa(v)}, nil
+	case int64:
+		return []string{strconv.FormatInt(v, 10)}, nil
+	case float64:
+		return []string{formatFloat64(v)}, nil
+	}
+	return nil, fmt.Errorf("unsupported value %v", v)
+}
+
+// checkConfig fails on the names which are not those of an option and on the
+// values their options don't parse, returning the name at fault. kingpin sets
+// the options again after, from the file unless overridden.
+func checkConfig(app *kingpin.Application, values map[string][]string) (string, error) {
+	known := map[string][]kingpin.Value{}
+	var add func(flags *kingpin.FlagGroupModel, args *kingpin.ArgGroupModel, cmds *kingpin.CmdGroupModel)
+	add = func(flags *kingpin.FlagGroupModel, args *kingpin.ArgGroupModel, cmds *kingpin.CmdGroupModel) {
+		for _, f := range flags.Flags {
+			known[f.Name] = append(known[f.Name], f.Value)
+		}
+		for _, a := range args.Args {
+			known[a.Name] = append(known[a.Name], a.Value)
+		}
+		for _, c := range cmds.Commands {
+			add(c.FlagGroupModel, c.ArgGroupModel, c.CmdGroupModel)
+		}
+	}
+	model := app.Model()
+	add(model.FlagGroupModel, model.ArgGroupModel, model.CmdGroupModel)
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if len(known[name]) == 0 || name == "config" {
+			return name, fmt.Errorf("unknown option %q", name)
+		}
+		// the options of the same name in several commands may differ
+		var err error
+		for _, value := range known[name] {
+			if err = setConfigValue(value, values[name]); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return name, err
+		}
+	}
+	return "", nil
+}
+
+func setConfigValue(value kingpin.Value, values []string) error {
+	for _, v := range values {
+		if err := value.Set(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stripConfigArg removes '--config' from args, for the workers which are sent
+// its values instead.
+func stripConfigArg(args []string) []string {
+	return stripArgs(args, []string{"config"})
+}
+
+// stripArgs removes the flags of names from args, along with their values.
+func stripArgs(args []string, names []string) []string {
+	var out []string
+next:
+	for i := 0; i < len(args); i++ {
+		for _, name := range names {
+			if args[i] == "--"+name {
+				i++
+				continue next
+			}
+			if strings.HasPrefix(args[i], "--"+name+"=") {
+				continue next
+			}
+		}
+		out = append(out, args[i])
+	}
+	return out
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// PhaseTimeouts are the timeouts of the phases of a request. Zero means no
+// timeout for the phase.
+type PhaseTimeouts struct {
+	connect time.Duration
+	tls     time.Duration
+	ttfb    time.Duration
+	body    time.Duration
+}
+
+// phaseError marks the error of a connection phase that timed out.
+type phaseError struct {
+	phase string
+	err   error
+}
+
+func (e *phaseError) Error() string   { return e.phase + " timeout: " + e.err.Error() }
+func (e *phaseError) Unwrap() error   { return e.err }
+func (e *phaseError) Timeout() bool   { return true }
+func (e *phaseError) Temporary() bool { return true }
+
+func isTimeout(err error) bool {
+	var ne net.Error
+	return err == fasthttp.ErrDialTimeout || (errors.As(err, &ne) && ne.Timeout())
+}
+
+// ConnectDial times dial as the connect phase, marking its timeout errors.
+func ConnectDial(dial fasthttp.DialFunc, t *connTracker) fasthttp.DialFunc {
+	return func(addr string) (net.Conn, error) {
+		start := time.Now()
+		conn, err := dial(addr)
+		if err != nil {
+			if isTimeout(err) {
+				return nil, &phaseError{"connect", err}
+			}
+			return nil, err
+		}
+		t.addTiming("connect", time.Since(start))
+		return conn, nil
+	}
+}
+
+// ConnTimings are the durations of the phases establishing a connection,
+// attributed to the request which had to open it.
+type ConnTimings struct {
+	New bool
+	// DNS is the time taken to pick the address, resolving it if it expired
+	DNS     time.Duration
+	Connect time.Duration
+	Proxy   time.Duration
+	TLS     time.Duration
+	// Resumed is set if the TLS handshake resumed a session
+	Resumed bool
+}
+
+// connIDs numbers the connections opened by the run.
+var connIDs int64
+
+// connTracker follows the connection of a worker to a target.
+type connTracker struct {
+	lock sync.Mutex
+	// id is the number of the connection, 0 before the first one
+	id           int64
+	timeoutPhase string
+	timings      ConnTimings
+	ip           string
+	violations   []string
+	// wroteAt is when the request in flight started to be written
+	wroteAt time.Time
+	// firstByteAt is when the first byte of the last response was read
+	firstByteAt time.Time
+}
+
+func (t *connTracker) addTiming(phase string, d time.Duration) {
+	t.lock.Lock()
+	switch phase {
+	case "dns":
+		t.timings.DNS += d
+	case "connect":
+		t.timings.Connect += d
+	case "proxy":
+		t.timings.Proxy += d
+	case "tls":
+		t.timings.TLS += d
+	}
+	t.lock.Unlock()
+}
+
+// takeTimings returns the timings of the connection opened since the last
+// call, which are all zero if the connection was reused.
+func (t *connTracker) takeTimings() ConnTimings {
+	t.lock.Lock()
+	timings := t.timings
+	t.timings = ConnTimings{}
+	t.lock.Unlock()
+	return timings
+}
+
+func (t *connTracker) setResumed() {
+	t.lock.Lock()
+	t.timings.Resumed = true
+	t.lock.Unlock()
+}
+
+// takeWroteAt returns when the request was first written to the connection
+// since the last call, zero if it wasn't.
+func (t *connTracker) takeWroteAt() time.Time {
+	t.lock.Lock()
+	at := t.wroteAt
+	t.wroteAt = time.Time{}
+	t.lock.Unlock()
+	return at
+}
+
+// takeFirstByteAt returns when the first byte of the last response was read
+// since the last call, zero if none was.
+func (t *connTracker) takeFirstByteAt() time.Time {
+	t.lock.Lock()
+	at := t.firstByteAt
+	t.firstByteAt = time.Time{}
+	t.lock.Unlock()
+	return at
+}
+
+// setRemoteIP records the address connected to, unless through a proxy.
+func (t *connTracker) setRemoteIP(ip string) {
+	t.lock.Lock()
+	t.ip = ip
+	t.lock.Unlock()
+}
+
+func (t *connTracker) remoteIP() string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.ip
+}
+
+// addViolation records a protocol violation of '--strict', once per request.
+func (t *connTracker) addViolation(kind string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, v := range t.violations {
+		if v == kind {
+			return
+		}
+	}
+	t.violations = append(t.violations, kind)
+}
+
+// takeViolations returns the violations found since the last call.
+func (t *connTracker) takeViolations() []string {
+	t.lock.Lock()
+	violations := t.violations
+	t.violations = nil
+	t.lock.Unlock()
+	return violations
+}
+
+// isNew reports whether the connection was opened by the request in flight.
+func (t *connTracker) isNew() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.timings.New
+}
+
+func (t *connTracker) setTimeoutPhase(phase string) {
+	t.lock.Lock()
+	t.timeoutPhase = phase
+	t.lock.Unlock()
+}
+
+// takeTimeoutPhase returns the phase timed out since the last call, if any.
+func (t *connTracker) takeTimeoutPhase() string {
+	t.lock.Lock()
+	phase := t.timeoutPhase
+	t.timeoutPhase = ""
+	t.lock.Unlock()
+	return phase
+}
+
+func (t *connTracker) Dial(dial fasthttp.DialFunc, timeouts *PhaseTimeouts) fasthttp.DialFunc {
+	return func(addr string) (net.Conn, error) {
+		t.lock.Lock()
+		t.timings = ConnTimings{New: true}
+		t.ip = ""
+		t.lock.Unlock()
+		conn, err := dial(addr)
+		if err != nil {
+			var pe *phaseError
+			if errors.As(err, &pe) {
+				t.setTimeoutPhase(pe.phase)
+			}
+			return nil, err
+		}
+		t.lock.Lock()
+		t.id = atomic.AddInt64(&connIDs, 1)
+		t.lock.Unlock()
+		return &phaseConn{Conn: conn, tracker: t, timeouts: timeouts}, nil
+	}
+}
+
+// connID returns the number of the last connection opened.
+func (t *connTracker) connID() int64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.id
+}
+
+// phaseConn enforces the ttfb and body timeouts, which start once the request
+// has been written and once the first byte of the response is read. The read
+// deadline set by fasthttp for '--resp-timeout' still applies if it's earlier.
+type phaseConn struct {
+	net.Conn
+	tracker  *connTracker
+	timeouts *PhaseTimeouts
+
+	phase         string
+	phaseDeadline time.Time
+	readDeadline  time.Time
+}
+
+func (c *phaseConn) setPhase(phase string, timeout time.Duration) {
+	c.phase = phase
+	if timeout > 0 {
+		c.phaseDeadline = time.Now().Add(timeout)
+	} else {
+		c.phaseDeadline = time.Time{}
+	}
+	_ = c.applyReadDeadline()
+}
+
+// phaseDeadlineFirst reports whether the phase deadline is the effective one.
+func (c *phaseConn) phaseDeadlineFirst() bool {
+	return !c.phaseDeadline.IsZero() && (c.readDeadline.IsZero() || c.phaseDeadline.Before(c.readDeadline))
+}
+
+func (c *phaseConn) applyReadDeadline() error {
+	if c.phaseDeadlineFirst() {
+		return c.Conn.SetReadDeadline(c.phaseDeadline)
+	}
+	return c.Conn.SetReadDeadline(c.readDeadline)
+}
+
+func (c *phaseConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline = t
+	return c.applyReadDeadline()
+}
+
+func (c *phaseConn) SetDeadline(t time.Time) error {
+	c.readDeadline = t
+	if err := c.Conn.SetWriteDeadline(t); err != nil {
+		return err
+	}
+	return c.applyReadDeadline()
+}
+
+func (c *phaseConn) Write(b []byte) (int, error) {
+	c.tracker.lock.Lock()
+	if c.tracker.wroteAt.IsZero() {
+		c.tracker.wroteAt = time.Now()
+	}
+	c.tracker.lock.Unlock()
+	n, err := c.Conn.Write(b)
+	if err != nil {
+		if isTimeout(err) {
+			c.tracker.setTimeoutPhase("write")
+		}
+		return n, err
+	}
+	// the ttfb phase starts over with every write, until the request is done
+	c.setPhase("ttfb", c.timeouts.ttfb)
+	return n, err
+}
+
+func (c *phaseConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if err != nil && isTimeout(err) {
+		if c.phaseDeadlineFirst() {
+			c.tracker.setTimeoutPhase(c.phase)
+		} else {
+			c.tracker.setTimeoutPhase("read")
+		}
+	}
+	if n > 0 && c.phase == "ttfb" {
+		c.tracker.lock.Lock()
+		c.tracker.firstByteAt = time.Now()
+		c.tracker.lock.Unlock()
+		c.setPhase("body", c.timeouts.body)
+	}
+	return n, err
+}
+
+// hostPool caps the connections busy with a request to a host, which may be
+// shared by several targets, and counts its open connections.
+type hostPool struct {
+	addr  string
+	slots chan struct{}
+	busy  int64
+	conns int64
+}
+
+func newHostPool(addr string, maxConns int) *hostPool {
+	h := &hostPool{addr: addr}
+	if maxConns > 0 {
+		h.slots = make(chan struct{}, maxConns)
+	}
+	return h
+}
+
+func (h *hostPool) tryAcquire() bool {
+	if h.slots != nil {
+		select {
+		case h.slots <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	atomic.AddInt64(&h.busy, 1)
+	return true
+}
+
+func (h *hostPool) acquire(ctx context.Context) bool {
+	if h.slots != nil {
+		select {
+		case h.slots <- struct{}{}:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	atomic.AddInt64(&h.busy, 1)
+	return true
+}
+
+func (h *hostPool) release() {
+	atomic.AddInt64(&h.busy, -1)
+	if h.slots != nil {
+		<-h.slots
+	}
+}
+
+// Dial counts the connections returned by dial until they are closed.
+func (h *hostPool) Dial(dial fasthttp.DialFunc) fasthttp.DialFunc {
+	return func(addr string) (net.Conn, error) {
+		conn, err := dial(addr)
+		if err != nil {
+			return nil, err
+		}
+		atomic.AddInt64(&h.conns, 1)
+		return &countedConn{Conn: conn, conns: &h.conns}, nil
+	}
+}
+
+type countedConn struct {
+	net.Conn
+	conns  *int64
+	closed int32
+}
+
+func (c *countedConn) Close() error {
+	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		atomic.AddInt64(c.conns, -1)
+	}
+	return c.Conn.Close()
+}
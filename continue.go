@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// The ways the servers take the Expect: 100-continue of '--expect-continue'.
+const (
+	// expectContinued requests got their 100 Continue before the body
+	expectContinued = "continued"
+	// expectRejected requests got a final response to the headers alone, the
+	// body never being sent
+	expectRejected = "rejected"
+	// expectTimedOut requests had their body sent after '--expect-timeout'
+	// without a 100 Continue, as clients do
+	expectTimedOut = "timed out"
+)
+
+var headerEnd = []byte("\r\n\r\n")
+
+// continueClient sends the requests of a worker target with the
+// Expect: 100-continue of '--expect-continue', writing the headers first and
+// the body once the server answers 100 Continue, which fasthttp doesn't. The
+// connection is kept alive between the requests like a HostClient does.
+type continueClient struct {
+	addr    string
+	dial    fasthttp.DialFunc
+	tracker *connTracker
+
+	readTimeout   time.Duration
+	writeTimeout  time.Duration
+	expectTimeout time.Duration
+	// maxIdle is how long the connection is kept idle between the requests
+	maxIdle time.Duration
+
+	lock sync.Mutex
+	conn net.Conn
+	br   *bufio.Reader
+	// idleSince is when the last request on the connection was done
+	idleSince time.Time
+	// outcome and wait are those of the last request, see takeContinue
+	outcome string
+	wait    time.Duration
+}
+
+func (c *continueClient) Do(req *fasthttp.Request, resp *fasthttp.Response) error {
+	return c.do(req, resp, time.Time{})
+}
+
+func (c *continueClient) DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	return c.do(req, resp, time.Now().Add(timeout))
+}
+
+// takeContinue returns how the server took the Expect of the last request,
+// "" if it had no body to hold back, and the time from its headers written to
+// the 100 Continue, -1 if none came.
+func (c *continueClient) takeContinue() (string, time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.outcome, c.wait
+}
+
+func (c *continueClient) CloseIdleConnections() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.closeConn()
+}
+
+func (c *continueClient) closeConn() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn, c.br = nil, nil
+	}
+}
+
+func (c *continueClient) do(req *fasthttp.Request, resp *fasthttp.Response, deadline time.Time) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.outcome, c.wait = "", -1
+
+	// the requests without a body have nothing to hold back
+	expect := req.IsBodyStream() || len(req.Body()) > 0
+	if expect {
+		req.Header.Set(fasthttp.HeaderExpect, "100-continue")
+	} else {
+		req.Header.Del(fasthttp.HeaderExpect)
+	}
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	if err := req.Write(bw); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	raw := buf.Bytes()
+	split := len(raw)
+	if expect {
+		split = bytes.Index(raw, headerEnd) + len(headerEnd)
+	}
+
+	resp.SkipBody = req.Header.IsHead()
+	if c.conn != nil && c.maxIdle > 0 && time.Since(c.idleSince) >= c.maxIdle {
+		c.closeConn()
+	}
+	for {
+		reused := c.conn != nil
+		if !reused {
+			conn, err := c.dial(c.addr)
+			if err != nil {
+				return err
+			}
+			c.conn, c.br = conn, bufio.NewReader(conn)
+		}
+		err := c.exchange(raw, split, resp, deadline)
+		c.idleSince = time.Now()
+		if err != nil || c.outcome == expectRejected || resp.ConnectionClose() || req.Header.ConnectionClose() {
+			// the server may still be waiting for the body of a rejected request
+			c.closeConn()
+		}
+		if err == io.EOF && reused {
+			// the server closed the idle connection, the request is sent
+			// again over a new one
+			c.outcome, c.wait = "", -1
+			continue
+		}
+		return err
+	}
+}
+
+// exchange writes the headers in raw[:split], waits for the 100 Continue if
+// the body in raw[split:] is held back, then writes it and reads the response.
+func (c *continueClient) exchange(raw []byte, split int, resp *fasthttp.Response, deadline time.Time) error {
+	if err := c.write(raw[:split], deadline); err != nil {
+		return err
+	}
+	if split < len(raw) {
+		sent := time.Now()
+		c.setReadDeadline(deadline, c.expectTimeout)
+		status, err := c.br.Peek(len("HTTP/1.1 100"))
+		switch {
+		case err == nil && bytes.Equal(status[len("HTTP/1.1 "):], []byte("100")):
+			c.outcome, c.wait = expectContinued, time.Since(sent)
+			var header fasthttp.ResponseHeader
+			c.setReadDeadline(deadline, c.readTimeout)
+			if err = header.Read(c.br); err != nil {
+				return err
+			}
+		case err == nil:
+			c.outcome = expectRejected
+			return c.read(resp, deadline)
+		case isTimeout(err):
+			// the expect timeout is a read timeout of its own, not to be
+			// confused with those of the phases or of the request
+			phase := c.tracker.takeTimeoutPhase()
+			if phase != "read" || (!deadline.IsZero() && !time.Now().Before(deadline)) {
+				c.tracker.setTimeoutPhase(phase)
+				return err
+			}
+			c.outcome = expectTimedOut
+		default:
+			return err
+		}
+		if err := c.write(raw[split:], deadline); err != nil {
+			return err
+		}
+	}
+	return c.read(resp, deadline)
+}
+
+func (c *continueClient) write(b []byte, deadline time.Time) error {
+	if c.writeTimeout > 0 {
+		if t := time.Now().Add(c.writeTimeout); deadline.IsZero() || t.Before(deadline) {
+			deadline = t
+		}
+	}
+	if err := c.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(b)
+	return err
+}
+
+func (c *continueClient) read(resp *fasthttp.Response, deadline time.Time) error {
+	c.setReadDeadline(deadline, c.readTimeout)
+	return resp.Read(c.br)
+}
+
+// setReadDeadline sets the earliest of deadline and timeout from now, if any.
+func (c *continueClient) setReadDeadline(deadline time.Time, timeout time.Duration) {
+	if timeout > 0 {
+		if t := time.Now().Add(timeout); deadline.IsZero() || t.Before(deadline) {
+			deadline = t
+		}
+	}
+	_ = c.conn.SetReadDeadline(deadline)
+}
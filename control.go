@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/valyala/fasthttp"
+)
+
+// The endpoints of the charts listener adjusting the run, they all answer
+// with the state of the run as '/api/status'.
+const (
+	ratePath        = "/api/rate"
+	concurrencyPath = "/api/concurrency"
+	pausePath       = "/api/pause"
+	resumePath      = "/api/resume"
+	stopPath        = "/api/stop"
+)
+
+// RunControl adjusts the run while running.
+type RunControl interface {
+	SetRate(rate float64) error
+	SetConcurrency(n int) error
+	Pause(paused bool) error
+	Cancel()
+}
+
+// isControlPath reports whether path is one of the control endpoints.
+func isControlPath(path string) bool {
+	switch path {
+	case ratePath, concurrencyPath, pausePath, resumePath, stopPath:
+		return true
+	}
+	return false
+}
+
+// sameOrigin tells whether the request comes from a page of the Web UI, or
+// from a client sending no Origin like curl, rather than from a page of
+// another site the browser of a user of the Web UI has open.
+func sameOrigin(ctx *fasthttp.RequestCtx) bool {
+	origin := ctx.Request.Header.Peek("Origin")
+	if len(origin) == 0 {
+		return true
+	}
+	u, err := url.Parse(string(origin))
+	return err == nil && u.Host == string(ctx.Host())
+}
+
+// control applies the request of a control endpoint, like a POST of
+// rate=100 to '/api/rate'.
+func control(ctl RunControl, path string, ctx *fasthttp.RequestCtx) error {
+	switch path {
+	case ratePath:
+		rate, err := strconv.ParseFloat(string(ctx.FormValue("rate")), 64)
+		if err != nil {
+			return err
+		}
+		return ctl.SetRate(rate)
+	case concurrencyPath:
+		n, err := strconv.Atoi(string(ctx.FormValue("concurrency")))
+		if err != nil {
+			return err
+		}
+		return ctl.SetConcurrency(n)
+	case pausePath:
+		return ctl.Pause(true)
+	case resumePath:
+		return ctl.Pause(false)
+	case stopPath:
+		ctl.Cancel()
+	}
+	return nil
+}
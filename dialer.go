@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	url2 "net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/proxy"
+)
+
+// DialBuilder builds the dial func of a worker, so that the phases of its
+// new connections are timed on its connTracker.
+type DialBuilder func(t *connTracker) fasthttp.DialFunc
+
+// parseResolve parses curl style HOST:PORT:ADDR entries into a map from the
+// address to dial to the one to dial instead.
+func parseResolve(entries []string) (map[string]string, error) {
+	resolve := make(map[string]string, len(entries))
+	for _, e := range entries {
+		n := strings.SplitN(e, ":", 3)
+		if len(n) != 3 || n[0] == "" || n[1] == "" || n[2] == "" {
+			return nil, fmt.Errorf("invalid resolve entry: %s", e)
+		}
+		if _, err := strconv.ParseUint(n[1], 10, 16); err != nil {
+			return nil, fmt.Errorf("invalid resolve port: %s", e)
+		}
+		ip := net.ParseIP(strings.Trim(n[2], "[]"))
+		if ip == nil {
+			return nil, fmt.Errorf("invalid resolve address: %s", e)
+		}
+		resolve[net.JoinHostPort(strings.ToLower(n[0]), n[1])] = net.JoinHostPort(ip.String(), n[1])
+	}
+	return resolve, nil
+}
+
+// ResolveDial dials the address given by '--resolve' in place of addr, if any.
+func ResolveDial(dial fasthttp.DialFunc, resolve map[string]string) fasthttp.DialFunc {
+	return func(addr string) (net.Conn, error) {
+		if to, ok := resolve[strings.ToLower(addr)]; ok {
+			addr = to
+		}
+		return dial(addr)
+	}
+}
+
+// LocalAddrs are the local IPs given by '--local-addr', connections are bound
+// to each of them in turn.
+type LocalAddrs struct {
+	ips  []net.IP
+	next uint32
+}
+
+func parseLocalAddrs(addrs []string) (*LocalAddrs, error) {
+	if len(addrs) == 0 {
+		return nil, nil
+	}
+	l := &LocalAddrs{}
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid local address: %s", addr)
+		}
+		l.ips = append(l.ips, ip)
+	}
+	return l, nil
+}
+
+func tcpNetwork(ipVersion int) string {
+	if ipVersion == 6 {
+		return "tcp6"
+	}
+	return "tcp4"
+}
+
+// Dialer returns a dialer bound to the next local IP, or an unbound one if l
+// is nil.
+func (l *LocalAddrs) Dialer(timeout time.Duration) *net.Dialer {
+	d := &net.Dialer{Timeout: timeout}
+	if l != nil {
+		n := atomic.AddUint32(&l.next, 1) - 1
+		d.LocalAddr = &net.TCPAddr{IP: l.ips[int(n)%len(l.ips)]}
+	}
+	return d
+}
+
+func (l *LocalAddrs) Dial(network, addr string) (net.Conn, error) {
+	return l.Dialer(0).Dial(network, addr)
+}
+
+// DirectDial connects to addr over TCP with the IP version, from the local
+// IPs in turn if any.
+func DirectDial(timeout time.Duration, local *LocalAddrs, ipVersion int) fasthttp.DialFunc {
+	if local != nil || ipVersion == 6 {
+		network := tcpNetwork(ipVersion)
+		return func(addr string) (net.Conn, error) {
+			return local.Dialer(timeout).Dial(network, addr)
+		}
+	}
+	if timeout > 0 {
+		return func(addr string) (net.Conn, error) {
+			return fasthttp.DialTimeout(addr, timeout)
+		}
+	}
+	return fasthttp.Dial
+}
+
+// UnixDial connects to the unix domain socket at path whatever the addr is,
+// the url is then only used for the Host header and the request path.
+func UnixDial(path string, timeout time.Duration) fasthttp.DialFunc {
+	return func(string) (net.Conn, error) {
+		return net.DialTimeout("unix", path, timeout)
+	}
+}
+
+// SocksDial connects through the SOCKS5 proxy, authenticating with the user
+// info of the proxy url if any.
+func SocksDial(proxyURL *url2.URL, timeout time.Duration, local *LocalAddrs) (fasthttp.DialFunc, error) {
+	var forward proxy.Dialer = &net.Dialer{Timeout: timeout}
+	if local != nil {
+		forward = local
+	}
+	dialer, err := proxy.FromURL(proxyURL, forward)
+	if err != nil {
+		return nil, err
+	}
+	return func(addr string) (net.Conn, error) {
+		return dialer.Dial("tcp", addr)
+	}, nil
+}
+
+// ProxyDial tunnels the connections through the HTTP(S) proxy with CONNECT,
+// timing the connection to the proxy and the setup of the tunnel apart.
+func ProxyDial(proxy *url2.URL, timeout time.Duration, local *LocalAddrs, ipVersion int, t *connTracker) fasthttp.DialFunc {
+	proxyAddr := addMissingPort(proxy.Host, proxy.Scheme == "https")
+	var auth string
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		auth = base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + password))
+	}
+	dial := ConnectDial(DirectDial(timeout, local, ipVersion), t)
+	return func(addr string) (net.Conn, error) {
+		conn, err := dial(proxyAddr)
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		if timeout > 0 {
+			_ = conn.SetDeadline(start.Add(timeout))
+		}
+		if proxy.Scheme == "https" {
+			tlsConn := tls.Client(conn, &tls.Config{ServerName: proxy.Hostname()})
+			if err = tlsConn.Handshake(); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			conn = tlsConn
+		}
+		req := "CONNECT " + addr + " HTTP/1.1\r\nHost: " + addr + "\r\n"
+		if auth != "" {
+			req += "Proxy-Authorization: Basic " + auth + "\r\n"
+		}
+		req += "\r\n"
+		if _, err = conn.Write([]byte(req)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		var resp fasthttp.ResponseHeader
+		br := bufio.NewReader(conn)
+		if err = resp.Read(br); err != nil {
+			conn.Close()
+			if isTimeout(err) {
+				return nil, &phaseError{"proxy", err}
+			}
+			return nil, err
+		}
+		if resp.StatusCode() != fasthttp.StatusOK {
+			conn.Close()
+			return nil, fmt.Errorf("proxy %s refused to connect: %d %s", proxy.Host, resp.StatusCode(), fasthttp.StatusMessage(resp.StatusCode()))
+		}
+		if timeout > 0 {
+			_ = conn.SetDeadline(time.Time{})
+		}
+		t.addTiming("proxy", time.Since(start))
+		if br.Buffered() > 0 {
+			return &bufferedConn{Conn: conn, r: br}, nil
+		}
+		return conn, nil
+	}
+}
+
+// bufferedConn reads what was buffered past the proxy response first.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// TLSDial does the TLS handshake on the connections returned by dial instead
+// of leaving it to fasthttp, so that connections can be instrumented both
+// below TLS (wire bytes) and above it (plaintext).
+func TLSDial(dial fasthttp.DialFunc, tlsConfig *tls.Config, handshakeTimeout time.Duration, t *connTracker) fasthttp.DialFunc {
+	return func(addr string) (net.Conn, error) {
+		conn, err := dial(addr)
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		tlsConn := tls.Client(conn, tlsConfig)
+		if handshakeTimeout > 0 {
+			_ = conn.SetDeadline(start.Add(handshakeTimeout))
+		}
+		if err = tlsConn.Handshake(); err != nil {
+			conn.Close()
+			if isTimeout(err) {
+				return nil, &phaseError{"tls", err}
+			}
+			return nil, err
+		}
+		if handshakeTimeout > 0 {
+			_ = conn.SetDeadline(time.Time{})
+		}
+		t.addTiming("tls", time.Since(start))
+		if tlsConn.ConnectionState().DidResume {
+			t.setResumed()
+		}
+		return tlsConn, nil
+	}
+}
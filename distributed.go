@@ -0,0 +1,656 @@
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"gopkg.in/alecthomas/kingpin.v3-unstable"
+)
+
+const (
+	// workerFlushInterval is how often a worker sends its records at least
+	workerFlushInterval = 100 * time.Millisecond
+	maxWorkerBatch      = 1024
+	// workerJoinTimeout is how long a connection has to send the token
+	workerJoinTimeout = 10 * time.Second
+	// workerStartTimeout is how long the workers have to start past their
+	// warmup, their connections opened by '--prewarm' included
+	workerStartTimeout = time.Minute
+)
+
+// controllerSecrets are the options of the controller the workers have no use
+// for, left out of the options sent to them.
+var controllerSecrets = []string{"listen-auth", "workers-token"}
+
+// workerHello is sent by a worker joining its controller, which lets the ones
+// with its token only take the slots of the workers.
+type workerHello struct {
+	Token string
+}
+
+// controllerMessage is sent by the controller to its workers, first with the
+// options of the run, then to stop it early.
+type controllerMessage struct {
+	Args []string
+	// Config are the values of '--config', stripped from Args
+	Config map[string][]string
+	// RunID is the ID of the run of all workers, and WorkerIndex the index
+	// of the first connection of the worker among all of theirs, for
+	// '--template' to tell them apart
+	RunID       string
+	WorkerIndex int64
+	WorkerCount int64
+	Stop        bool
+}
+
+// workerMessage is sent by a worker to its controller, once started after the
+// warmup if any, then with its records until done or failed.
+type workerMessage struct {
+	Started bool
+	Records []wireRecord
+	Done    bool
+	Err     string
+}
+
+// wireRecord is a ReportRecord as sent by a worker.
+type wireRecord struct {
+	Cost       time.Duration
+	Code       string
+	Error      string
+	ReadBytes  int64
+	WriteBytes int64
+
+	DecodedReadBytes  int64
+	DecodedWriteBytes int64
+
+	Conn    ConnTimings
+	ConnID  int64
+	Variant string
+
+	Host      string
+	IP        string
+	HostBusy  int64
+	HostConns int64
+
+	AffinityChecked bool
+	AffinityBroken  bool
+
+	ServerTime     time.Duration
+	RedirectKind   string
+	RedirectTarget string
+	HSTS           string
+	Violations     []string
+	Queue          time.Duration
+	Body           string
+
+	Cache        string
+	Age          time.Duration
+	CacheControl string
+
+	Follow        time.Duration
+	FollowCode    string
+	FollowMissing bool
+
+	Size int64
+
+	ChecksRun    uint64
+	ChecksFailed uint64
+
+	FirstEvent bool
+
+	Expect       string
+	ContinueWait time.Duration
+
+	TTFB time.Duration
+	TTLB time.Duration
+
+	Endpoint string
+}
+
+func newWireRecord(r *ReportRecord) wireRecord {
+	return wireRecord{
+		Cost:              r.cost,
+		Code:              r.code,
+		Error:             r.error,
+		ReadBytes:         r.readBytes,
+		WriteBytes:        r.writeBytes,
+		DecodedReadBytes:  r.decodedReadBytes,
+		DecodedWriteBytes: r.decodedWriteBytes,
+		Conn:              r.conn,
+		ConnID:            r.connID,
+		Variant:           r.variant,
+		Host:              r.host,
+		IP:                r.ip,
+		HostBusy:          r.hostBusy,
+		HostConns:         r.hostConns,
+		AffinityChecked:   r.affinityChecked,
+		AffinityBroken:    r.affinityBroken,
+		ServerTime:        r.serverTime,
+		RedirectKind:      r.redirect.kind,
+		RedirectTarget:    r.redirect.target,
+		HSTS:              r.hsts,
+		Violations:        r.violations,
+		Queue:             r.queue,
+		Body:              r.body,
+		Cache:             r.cache,
+		Age:               r.age,
+		CacheControl:      r.cacheControl,
+		Follow:            r.follow,
+		FollowCode:        r.followCode,
+		FollowMissing:     r.followMissing,
+		Size:              r.size,
+		ChecksRun:         r.checksRun,
+		ChecksFailed:      r.checksFailed,
+		FirstEvent:        r.firstEvent,
+		Expect:            r.expect,
+		ContinueWait:      r.continueWait,
+		TTFB:              r.ttfb,
+		TTLB:              r.ttlb,
+		Endpoint:          r.endpoint,
+	}
+}
+
+// loadGenerator sends the requests of the run, the requester itself or the
+// workers of a controller.
+type loadGenerator interface {
+	Run()
+	RecordChan() <-chan *ReportRecord
+	Cancel()
+	TargetAt(at time.Time) *LoadTarget
+}
+
+// Controller drives the run of the workers joining it with '--workers' in
+// place of a local requester, and merges their records.
+type Controller struct {
+	ln         net.Listener
+	token      string
+	n          int
+	requester  *Requester
+	workers    []*remoteWorker
+	recordChan chan *ReportRecord
+
+	// the byte counters of all workers
+	readBytes         int64
+	writeBytes        int64
+	decodedReadBytes  int64
+	decodedWriteBytes int64
+
+	lock sync.Mutex
+	errs []string
+}
+
+// remoteWorker is a worker joined to the controller.
+type remoteWorker struct {
+	name string
+	conn net.Conn
+	dec  *gob.Decoder
+
+	lock sync.Mutex
+	enc  *gob.Encoder
+
+	// the last byte counters of the worker
+	readBytes         int64
+	writeBytes        int64
+	decodedReadBytes  int64
+	decodedWriteBytes int64
+}
+
+func (w *remoteWorker) send(m *controllerMessage) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.enc.Encode(m)
+}
+
+// NewController takes the n workers joining on ln with token, requester
+// being the one built from the options to check them, which the workers build
+// as well.
+func NewController(ln net.Listener, token string, n int, requester *Requester) (*Controller, error) {
+	if n <= 0 {
+		ln.Close()
+		return nil, fmt.Errorf("the number of workers must be positive")
+	}
+	return &Controller{
+		ln:         ln,
+		token:      token,
+		n:          n,
+		requester:  requester,
+		recordChan: make(chan *ReportRecord, 8192),
+	}, nil
+}
+
+func (c *Controller) Addr() net.Addr {
+	return c.ln.Addr()
+}
+
+// Start waits for the workers to join, sends them the options of the run as
+// given by args along with its ID, and returns once they all started sending
+// requests.
+func (c *Controller) Start(args []string, runID string) error {
+	joined := make(chan *remoteWorker)
+	failed := make(chan error, 1)
+	full := make(chan struct{})
+	go c.accept(joined, failed, full)
+	for len(c.workers) < c.n {
+		select {
+		case w := <-joined:
+			// the processes joining over a unix socket have no address
+			if w.name == "" || w.name == "@" {
+				w.name = fmt.Sprintf("process %d", len(c.workers)+1)
+			}
+			c.workers = append(c.workers, w)
+		case err := <-failed:
+			return err
+		}
+	}
+	close(full)
+	c.ln.Close()
+
+	args = stripArgs(stripConfigArg(args), controllerSecrets)
+	config := make(map[string][]string, len(configValues))
+	for name, values := range configValues {
+		config[name] = values
+	}
+	for _, name := range controllerSecrets {
+		delete(config, name)
+	}
+	// every worker runs the connections of the controller's options
+	perWorker := int64(c.requester.concurrency)
+	for i, w := range c.workers {
+		m := &controllerMessage{Args: args, Config: config, RunID: runID,
+			WorkerIndex: int64(i) * perWorker, WorkerCount: int64(c.n) * perWorker}
+		if err := w.send(m); err != nil {
+			return fmt.Errorf("worker %s: %v", w.name, err)
+		}
+	}
+	// the workers answer once started, which an interrupt stops waiting for
+	timeout := c.requester.clientOpt.warmup + workerStartTimeout
+	for _, w := range c.workers {
+		_ = w.conn.SetReadDeadline(time.Now().Add(timeout))
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	started := make(chan struct{})
+	defer close(started)
+	var interrupted int32
+	go func() {
+		select {
+		case <-sigs:
+			atomic.StoreInt32(&interrupted, 1)
+			for _, w := range c.workers {
+				_ = w.conn.SetReadDeadline(time.Now())
+			}
+		case <-started:
+		}
+	}()
+	for _, w := range c.workers {
+		var m workerMessage
+		if err := w.dec.Decode(&m); err != nil {
+			if atomic.LoadInt32(&interrupted) == 1 {
+				return fmt.Errorf("interrupted while waiting for worker %s to start", w.name)
+			}
+			if err, ok := err.(net.Error); ok && err.Timeout() {
+				return fmt.Errorf("worker %s did not start within %s", w.name, timeout)
+			}
+			return fmt.Errorf("worker %s: %v", w.name, err)
+		}
+		if m.Err != "" {
+			return fmt.Errorf("worker %s: %s", w.name, m.Err)
+		}
+	}
+	for _, w := range c.workers {
+		_ = w.conn.SetReadDeadline(time.Time{})
+	}
+	startTime = time.Now()
+	return nil
+}
+
+// accept hands the connections sending the token to joined until full is
+// closed, rejecting the others, and the error of the listener to failed.
+func (c *Controller) accept(joined chan<- *remoteWorker, failed chan<- error, full <-chan struct{}) {
+	for {
+		conn, err := c.ln.Accept()
+		if err != nil {
+			select {
+			case failed <- err:
+			default:
+			}
+			return
+		}
+		go func() {
+			w, err := c.join(conn)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Rejected worker %s: %v\n", conn.RemoteAddr(), err)
+				conn.Close()
+				return
+			}
+			select {
+			case joined <- w:
+			case <-full:
+				conn.Close()
+			}
+		}()
+	}
+}
+
+// join checks the token of a connection, which has workerJoinTimeout to send
+// it.
+func (c *Controller) join(conn net.Conn) (*remoteWorker, error) {
+	w := &remoteWorker{
+		name: conn.RemoteAddr().String(),
+		conn: conn,
+		dec:  gob.NewDecoder(conn),
+		enc:  gob.NewEncoder(conn),
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(workerJoinTimeout))
+	var hello workerHello
+	if err := w.dec.Decode(&hello); err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hello.Token), []byte(c.token)) != 1 {
+		return nil, fmt.Errorf("invalid token")
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	return w, nil
+}
+
+// Run receives the records of the workers until they are all done.
+func (c *Controller) Run() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	go func() {
+		if _, ok := <-sigs; ok {
+			c.Cancel()
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for _, w := range c.workers {
+		wg.Add(1)
+		go func(w *remoteWorker) {
+			defer wg.Done()
+			defer w.conn.Close()
+			c.receive(w)
+		}(w)
+	}
+	wg.Wait()
+	close(c.recordChan)
+}
+
+func (c *Controller) receive(w *remoteWorker) {
+	for {
+		var m workerMessage
+		if err := w.dec.Decode(&m); err != nil {
+			c.fail(w, err.Error())
+			return
+		}
+		if m.Err != "" {
+			c.fail(w, m.Err)
+			return
+		}
+		for i := range m.Records {
+			c.recordChan <- c.record(w, &m.Records[i])
+		}
+		if m.Done {
+			return
+		}
+	}
+}
+
+func (c *Controller) fail(w *remoteWorker, err string) {
+	c.lock.Lock()
+	c.errs = append(c.errs, fmt.Sprintf("worker %s: %s", w.name, err))
+	c.lock.Unlock()
+}
+
+// Errors returns the errors of the workers which failed to finish the run.
+func (c *Controller) Errors() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.errs
+}
+
+// sumBytes adds what a worker counted since its last record to the total.
+func sumBytes(total, last *int64, count int64) int64 {
+	delta := count - *last
+	*last = count
+	return atomic.AddInt64(total, delta)
+}
+
+func (c *Controller) record(w *remoteWorker, wr *wireRecord) *ReportRecord {
+	rr := recordPool.Get().(*ReportRecord)
+	*rr = ReportRecord{
+		cost:              wr.Cost,
+		code:              wr.Code,
+		error:             wr.Error,
+		readBytes:         sumBytes(&c.readBytes, &w.readBytes, wr.ReadBytes),
+		writeBytes:        sumBytes(&c.writeBytes, &w.writeBytes, wr.WriteBytes),
+		decodedReadBytes:  sumBytes(&c.decodedReadBytes, &w.decodedReadBytes, wr.DecodedReadBytes),
+		decodedWriteBytes: sumBytes(&c.decodedWriteBytes, &w.decodedWriteBytes, wr.DecodedWriteBytes),
+		conn:              wr.Conn,
+		connID:            wr.ConnID,
+		variant:           wr.Variant,
+		host:              wr.Host,
+		ip:                wr.IP,
+		hostBusy:          wr.HostBusy,
+		hostConns:         wr.HostConns,
+		affinityChecked:   wr.AffinityChecked,
+		affinityBroken:    wr.AffinityBroken,
+		serverTime:        wr.ServerTime,
+		redirect:          redirectKey{kind: wr.RedirectKind, target: wr.RedirectTarget},
+		hsts:              wr.HSTS,
+		violations:        wr.Violations,
+		queue:             wr.Queue,
+		body:              wr.Body,
+		worker:            w.name,
+		cache:             wr.Cache,
+		age:               wr.Age,
+		cacheControl:      wr.CacheControl,
+		follow:            wr.Follow,
+		followCode:        wr.FollowCode,
+		followMissing:     wr.FollowMissing,
+		size:              wr.Size,
+		checksRun:         wr.ChecksRun,
+		checksFailed:      wr.ChecksFailed,
+		firstEvent:        wr.FirstEvent,
+		expect:            wr.Expect,
+		continueWait:      wr.ContinueWait,
+		ttfb:              wr.TTFB,
+		ttlb:              wr.TTLB,
+		endpoint:          wr.Endpoint,
+	}
+	return rr
+}
+
+func (c *Controller) RecordChan() <-chan *ReportRecord {
+	return c.recordChan
+}
+
+// Cancel stops the run of all workers.
+func (c *Controller) Cancel() {
+	for _, w := range c.workers {
+		_ = w.send(&controllerMessage{Stop: true})
+	}
+}
+
+// TargetAt returns the load aimed at by all workers together.
+func (c *Controller) TargetAt(at time.Time) *LoadTarget {
+	t := c.requester.TargetAt(at)
+	if t == nil {
+		return nil
+	}
+	return &LoadTarget{Concurrency: t.Concurrency * c.n, Rate: t.Rate * float64(c.n)}
+}
+
+// listenProcesses listens for the workers of '--processes' on a unix socket
+// in a directory of its own, which only the user running plow can connect
+// to, to be removed once they joined.
+func listenProcesses() (net.Listener, string, error) {
+	dir, err := ioutil.TempDir("", "plow")
+	if err != nil {
+		return nil, "", err
+	}
+	ln, err := net.Listen("unix", filepath.Join(dir, "controller.sock"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, "", err
+	}
+	return ln, dir, nil
+}
+
+// spawnWorkers starts n worker processes of plow joining the controller at
+// addr with token, for '--processes', the token passed in their environment
+// rather than on their command line seen by all users. They stop along with
+// the controller.
+func spawnWorkers(addr, token string, n int) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		// their errors are sent to the controller
+		cmd := exec.Command(exe, "worker", "--join", addr)
+		cmd.Env = append(os.Environ(), "PLOW_TOKEN="+token)
+		if err = cmd.Start(); err != nil {
+			return fmt.Errorf("process %d: %v", i+1, err)
+		}
+		go func() { _ = cmd.Wait() }()
+	}
+	return nil
+}
+
+// dialController connects to the controller at addr, a unix socket if like
+// unix:PATH, over TLS if tlsJoin or given the CA its certificate is verified
+// against.
+func dialController(addr string, tlsJoin bool, caFile string) (net.Conn, error) {
+	if strings.HasPrefix(addr, "unix:") {
+		return net.Dial("unix", strings.TrimPrefix(addr, "unix:"))
+	}
+	if !tlsJoin && caFile == "" {
+		return net.Dial("tcp", addr)
+	}
+	config := &tls.Config{}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate in %s", caFile)
+		}
+	}
+	return tls.Dial("tcp", addr, config)
+}
+
+// runWorker joins the controller at addr with token and runs the benchmark it
+// sends, streaming back the records.
+func runWorker(addr, token string, tlsJoin bool, caFile string) {
+	conn, err := dialController(addr, tlsJoin, caFile)
+	if err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	defer conn.Close()
+	enc := gob.NewEncoder(conn)
+	dec := gob.NewDecoder(conn)
+
+	if err = enc.Encode(&workerHello{Token: token}); err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	var job controllerMessage
+	if err = dec.Decode(&job); err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	// the controller gets the error as well
+	errHook = func(msg string) {
+		_ = enc.Encode(&workerMessage{Err: msg})
+	}
+	configValues = job.Config
+	command, err := kingpin.CommandLine.Parse(job.Args)
+	if err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	if command == "controller" {
+		*urls = *controllerURLs
+	}
+	*runID = job.RunID
+	fmt.Fprintf(os.Stderr, "Joined %s to run: %s\n", addr, strings.Join(job.Args, " "))
+
+	// the schedule is followed by the requester itself
+	requester, _ := buildRequester()
+	if requester == nil {
+		// the options failed, reported to the controller by errHook
+		return
+	}
+	requester.workerIndex, requester.workerCount = job.WorkerIndex, job.WorkerCount
+	if *warmup > 0 {
+		requester.Warmup()
+	} else if *prewarm {
+		_, _, _ = requester.Prewarm()
+	}
+	if err = enc.Encode(&workerMessage{Started: true}); err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	go requester.Run()
+	go func() {
+		// stop once told to, or once the controller is gone
+		var m controllerMessage
+		for dec.Decode(&m) == nil && !m.Stop {
+		}
+		requester.Cancel()
+	}()
+
+	ticker := time.NewTicker(workerFlushInterval)
+	defer ticker.Stop()
+	batch := make([]wireRecord, 0, maxWorkerBatch)
+	flush := func() bool {
+		if len(batch) == 0 {
+			return true
+		}
+		err := enc.Encode(&workerMessage{Records: batch})
+		batch = batch[:0]
+		return err == nil
+	}
+	records := requester.RecordChan()
+	for {
+		select {
+		case r, ok := <-records:
+			if !ok {
+				if flush() {
+					_ = enc.Encode(&workerMessage{Done: true})
+				}
+				fmt.Fprintln(os.Stderr, "Done.")
+				return
+			}
+			batch = append(batch, newWireRecord(r))
+			recordPool.Put(r)
+			if len(batch) == maxWorkerBatch && !flush() {
+				requester.Cancel()
+			}
+		case <-ticker.C:
+			if !flush() {
+				requester.Cancel()
+			}
+		}
+	}
+}
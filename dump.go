@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// parseDumpSignal parses the signal of '--dump-on-signal' or of
+// '--print-on-signal' like QUIT or SIGUSR2, among dumpSignals.
+func parseDumpSignal(name string) (os.Signal, error) {
+	sig, ok := dumpSignals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		var names []string
+		for n := range dumpSignals {
+			names = append(names, n)
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("signals are not supported on this platform")
+		}
+		return nil, fmt.Errorf("invalid signal %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+	return sig, nil
+}
+
+// dumpOnSignal writes the report of the run so far to dir every time sig is
+// received, until done, leaving the run going.
+func dumpOnSignal(sig os.Signal, report *StreamReport, desc, dir string, useSeconds bool, done <-chan struct{}) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, sig)
+	defer signal.Stop(sigs)
+	for {
+		select {
+		case <-sigs:
+			base, err := dumpReport(report, desc, dir, useSeconds)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "plow: dump: %v\n", err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Dumped the report to %s.json and .txt\n", base)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// printOnSignal prints the report of the run so far to stderr every time sig
+// is received, until done, leaving the run going.
+func printOnSignal(sig os.Signal, report *StreamReport, desc string, useSeconds bool, done <-chan struct{}) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, sig)
+	defer signal.Stop(sigs)
+	for {
+		select {
+		case <-sigs:
+			var buf bytes.Buffer
+			fmt.Fprintf(&buf, "\n%s\nReport so far, %s\n\n", time.Now().Format(time.RFC3339), desc)
+			NewPrinter(0, 0, false, true).formatTableReports(&buf, report.Snapshot(), true, useSeconds)
+			os.Stderr.WriteString(colorSeq.ReplaceAllString(buf.String(), ""))
+		case <-done:
+			return
+		}
+	}
+}
+
+// dumpReport writes the report so far both as the JSON of '--json' and as
+// printed, with its histogram, to files of dir named after the time.
+func dumpReport(report *StreamReport, desc, dir string, useSeconds bool) (string, error) {
+	base := filepath.Join(dir, "plow-dump-"+time.Now().Format("20060102-150405.000"))
+	saved := report.saved(desc)
+	if err := writeReport(base+".json", saved); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(desc + "\n\n")
+	NewPrinter(0, 0, false, true).formatTableReports(&buf, saved.Summary, true, useSeconds)
+	text := colorSeq.ReplaceAllString(buf.String(), "")
+	return base, ioutil.WriteFile(base+".txt", []byte(text), 0644)
+}
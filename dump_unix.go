@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// defaultDumpSignal and defaultPrintSignal are the signals of
+// '--dump-on-signal' and '--print-on-signal' by default.
+const (
+	defaultDumpSignal  = "QUIT"
+	defaultPrintSignal = "USR1"
+)
+
+var dumpSignals = map[string]os.Signal{
+	"QUIT": syscall.SIGQUIT,
+	"HUP":  syscall.SIGHUP,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
@@ -0,0 +1,13 @@
+package main
+
+import "os"
+
+// defaultDumpSignal and defaultPrintSignal are the signals of
+// '--dump-on-signal' and '--print-on-signal' by default, none as Windows has
+// no signal to spare.
+const (
+	defaultDumpSignal  = ""
+	defaultPrintSignal = ""
+)
+
+var dumpSignals = map[string]os.Signal{}
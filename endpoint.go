@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+)
+
+const otherEndpoints = "other endpoints"
+
+// endpointPattern groups the requests whose path matches pattern under name.
+type endpointPattern struct {
+	name    string
+	pattern string
+}
+
+// EndpointGrouper groups the requests of '--group-by' by endpoint, reporting
+// their latencies apart: by the url of their target, by their path, or by the
+// first of the path patterns they match.
+type EndpointGrouper struct {
+	by       string
+	patterns []*endpointPattern
+
+	// paths are those reported apart by path, the requests to the others
+	// reported together as otherPaths
+	lock  sync.Mutex
+	paths map[string]bool
+}
+
+// ParseGroupBy parses '--group-by': url, path, or comma-separated path
+// patterns like /users/*, each optionally named like users=/users/*.
+func ParseGroupBy(s string) (*EndpointGrouper, error) {
+	switch s {
+	case "url":
+		return &EndpointGrouper{by: s}, nil
+	case "path":
+		return &EndpointGrouper{by: s, paths: make(map[string]bool)}, nil
+	}
+	g := &EndpointGrouper{by: "pattern"}
+	for _, f := range strings.Split(s, ",") {
+		name, pattern := strings.TrimSpace(f), strings.TrimSpace(f)
+		if i := strings.Index(f, "="); i >= 0 {
+			name, pattern = strings.TrimSpace(f[:i]), strings.TrimSpace(f[i+1:])
+		}
+		if name == "" || !strings.HasPrefix(pattern, "/") {
+			return nil, fmt.Errorf("invalid group %q, expected url, path or path patterns like users=/users/*", f)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid group %q: %v", f, err)
+		}
+		g.patterns = append(g.patterns, &endpointPattern{name: name, pattern: pattern})
+	}
+	return g, nil
+}
+
+// endpoint returns the endpoint the request to t is reported under.
+func (g *EndpointGrouper) endpoint(t *target, req *fasthttp.Request) string {
+	switch g.by {
+	case "url":
+		return t.url
+	case "path":
+		p := string(req.URI().Path())
+		g.lock.Lock()
+		defer g.lock.Unlock()
+		if !g.paths[p] {
+			if len(g.paths) >= maxPaths {
+				return otherPaths
+			}
+			g.paths[p] = true
+		}
+		return p
+	}
+	p := string(req.URI().Path())
+	for _, e := range g.patterns {
+		if ok, _ := path.Match(e.pattern, p); ok {
+			return e.name
+		}
+	}
+	return otherEndpoints
+}
+
+// Endpoints returns the endpoints the requests are reported under, known
+// before the run unless grouped by path.
+func (r *Requester) Endpoints() []string {
+	var endpoints []string
+	seen := make(map[string]bool)
+	add := func(endpoint string) {
+		if !seen[endpoint] {
+			seen[endpoint] = true
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	switch g := r.clientOpt.groupBy; {
+	case g == nil:
+		if p := r.clientOpt.replay; p != nil {
+			for _, e := range p.entries {
+				add(e.group)
+			}
+			sort.Strings(endpoints)
+		}
+	case g.by == "url":
+		for _, t := range r.targets {
+			add(t.url)
+		}
+	case g.by == "pattern":
+		for _, e := range g.patterns {
+			add(e.name)
+		}
+		add(otherEndpoints)
+	}
+	return endpoints
+}
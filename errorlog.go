@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// maxErrorLogKeys bounds the distinct statuses and errors sampled by the
+// error log, as errors may embed addresses and the like.
+const maxErrorLogKeys = 1000
+
+// ErrorLogEntry is a line of '--error-log', a failed request with what the
+// server returned, if anything.
+type ErrorLogEntry struct {
+	Time   time.Time
+	Method string
+	URL    string
+	// RequestID is the one sent in the header of '--request-id-header'
+	RequestID string `json:",omitempty"`
+	Latency   string
+	Error     string `json:",omitempty"`
+	// Status is the status line, Headers the header lines which follow it
+	Status  string   `json:",omitempty"`
+	Headers []string `json:",omitempty"`
+	Body    string   `json:",omitempty"`
+	// BodySize is the size of the whole body when Body is truncated
+	BodySize     int      `json:",omitempty"`
+	FailedChecks []string `json:",omitempty"`
+}
+
+// ErrorLog writes the failed requests, the ones with an error, a status other
+// than 2xx or failed checks, to a file as JSON lines. Only the first ones of
+// every status or error are written, not to grow unbounded.
+type ErrorLog struct {
+	lock    sync.Mutex
+	file    *os.File
+	checks  []*Check
+	max     int
+	maxBody int
+	logged  map[string]int
+}
+
+// NewErrorLog creates the log at path, keeping max requests of every status
+// or error with their bodies truncated to maxBody bytes.
+func NewErrorLog(path string, max, maxBody int, checks []*Check) (*ErrorLog, error) {
+	// appended to, as the processes of '--processes' share it
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &ErrorLog{file: file, checks: checks, max: max, maxBody: maxBody, logged: make(map[string]int)}, nil
+}
+
+// failed tells whether the request of the record is one to log.
+func (l *ErrorLog) failed(rr *ReportRecord) bool {
+	return rr.error != "" || rr.code != "2xx" || rr.checksFailed != 0
+}
+
+// sample counts the request under key, telling whether it is to be written.
+func (l *ErrorLog) sample(key string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	n, ok := l.logged[key]
+	if n >= l.max || (!ok && len(l.logged) >= maxErrorLogKeys) {
+		return false
+	}
+	l.logged[key] = n + 1
+	return true
+}
+
+// Log writes the failed request of the record, along with the header and the
+// body of its response if given, nil for the requests which got none.
+func (l *ErrorLog) Log(req *fasthttp.Request, header *fasthttp.ResponseHeader, body []byte, rr *ReportRecord) {
+	if !l.failed(rr) {
+		return
+	}
+	key := rr.error
+	if key == "" {
+		key = strconv.Itoa(header.StatusCode())
+		if rr.checksFailed != 0 {
+			key += " " + strconv.FormatUint(rr.checksFailed, 16)
+		}
+	}
+	if !l.sample(key) {
+		return
+	}
+
+	e := &ErrorLogEntry{
+		Time:      time.Now(),
+		Method:    string(req.Header.Method()),
+		URL:       req.URI().String(),
+		RequestID: rr.requestID,
+		Latency:   rr.cost.String(),
+		Error:     rr.error,
+	}
+	if header != nil {
+		lines := strings.Split(strings.TrimRight(string(header.Header()), "\r\n"), "\r\n")
+		e.Status, e.Headers = lines[0], lines[1:]
+		e.Body = string(body)
+		if len(body) > l.maxBody {
+			e.Body, e.BodySize = string(body[:l.maxBody]), len(body)
+		}
+	}
+	for i, c := range l.checks {
+		if rr.checksFailed&(1<<uint(i)) != 0 {
+			e.FailedChecks = append(e.FailedChecks, c.Name)
+		}
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	// written at once, not to mix with the lines of other requests
+	_, _ = l.file.Write(append(line, '\n'))
+}
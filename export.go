@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// SummaryTpl shows the summary printed at the end of the run above the
+// charts of the HTML report.
+const SummaryTpl = `
+<style> .summary { display:flex; justify-content:center } </style>
+<div class="summary"><pre>%s</pre></div>
+`
+
+var colorSeq = regexp.MustCompile("\033\\[[0-9;]*m")
+
+// WriteHTMLReport writes the summary and the charts of a run to fileName as a
+// single page of '--report', the scripts inlined, to be opened without plow.
+func WriteHTMLReport(fileName string, saved *SavedReport, useSeconds bool) error {
+	var summary bytes.Buffer
+	NewPrinter(0, 0, false, true).formatTableReports(&summary, saved.Summary, true, useSeconds)
+
+	c, err := NewStaticCharts(nil, saved.Charts, saved.Description)
+	if err != nil {
+		return err
+	}
+	c.summary = colorSeq.ReplaceAllString(summary.String(), "")
+	c.setPageTpl()
+
+	var page bytes.Buffer
+	if err = c.page.Render(&page); err != nil {
+		return err
+	}
+	out := page.String()
+	for _, asset := range []string{"echarts.min.js", "jquery.min.js"} {
+		script, err := assetsFS.ReadFile(asset)
+		if err != nil {
+			return err
+		}
+		tag := fmt.Sprintf(`<script src="%s%s"></script>`, assetsPath, asset)
+		out = strings.Replace(out, tag, "<script>"+string(script)+"</script>", 1)
+	}
+	return ioutil.WriteFile(fileName, []byte(out), 0644)
+}
+
+// escapePageText escapes text for the template of the page, which is parsed
+// once the text is in.
+func escapePageText(text string) string {
+	return strings.NewReplacer("{", "&#123;", "}", "&#125;").Replace(html.EscapeString(text))
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// fakeLocale is the data the faker functions of '--template' pick from.
+type fakeLocale struct {
+	firstNames   []string
+	lastNames    []string
+	streets      []string
+	cities       []string
+	emailDomains []string
+	// phoneFormat has a random digit in place of every #
+	phoneFormat string
+	address     func(number int, street, postcode, city string) string
+}
+
+var fakeLocales = map[string]*fakeLocale{
+	"en": {
+		firstNames:   []string{"James", "Mary", "John", "Patricia", "Robert", "Jennifer", "Michael", "Linda", "David", "Elizabeth", "William", "Susan", "Richard", "Jessica", "Joseph", "Sarah"},
+		lastNames:    []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Wilson", "Anderson", "Taylor", "Thomas", "Moore", "Martin", "Jackson", "White"},
+		streets:      []string{"Main Street", "Oak Avenue", "Maple Drive", "Cedar Lane", "Park Road", "Elm Street", "Washington Avenue", "Lake View Drive"},
+		cities:       []string{"Springfield", "Riverside", "Franklin", "Greenville", "Fairview", "Madison", "Georgetown", "Salem"},
+		emailDomains: []string{"example.com", "example.net", "example.org"},
+		phoneFormat:  "(###) ###-####",
+		address: func(number int, street, postcode, city string) string {
+			return fmt.Sprintf("%d %s, %s %s", number, street, city, postcode)
+		},
+	},
+	"de": {
+		firstNames:   []string{"Lukas", "Anna", "Leon", "Lena", "Finn", "Marie", "Jonas", "Sophie", "Paul", "Hannah", "Felix", "Lea", "Jürgen", "Jana", "Maximilian", "Katrin"},
+		lastNames:    []string{"Müller", "Schmidt", "Schneider", "Fischer", "Weber", "Meyer", "Wagner", "Becker", "Schulz", "Hoffmann", "Schäfer", "Koch", "Bauer", "Richter", "Klein", "Wolf"},
+		streets:      []string{"Hauptstraße", "Schulstraße", "Gartenstraße", "Bahnhofstraße", "Dorfstraße", "Bergstraße", "Lindenweg", "Am Markt"},
+		cities:       []string{"Berlin", "Hamburg", "München", "Köln", "Frankfurt am Main", "Stuttgart", "Leipzig", "Dresden"},
+		emailDomains: []string{"example.de", "example.com", "example.net"},
+		phoneFormat:  "+49 ### #######",
+		address: func(number int, street, postcode, city string) string {
+			return fmt.Sprintf("%s %d, %s %s", street, number, postcode, city)
+		},
+	},
+	"fr": {
+		firstNames:   []string{"Gabriel", "Louise", "Léo", "Jade", "Raphaël", "Emma", "Louis", "Alice", "Hugo", "Chloé", "Jules", "Léa", "Arthur", "Manon", "Lucas", "Hélène"},
+		lastNames:    []string{"Martin", "Bernard", "Dubois", "Thomas", "Robert", "Richard", "Petit", "Durand", "Leroy", "Moreau", "Simon", "Laurent", "Lefèvre", "Michel", "Garcia", "Fournier"},
+		streets:      []string{"rue de la Paix", "rue Victor Hugo", "avenue de la République", "boulevard Saint-Michel", "rue des Écoles", "place de la Mairie", "rue Pasteur", "allée des Tilleuls"},
+		cities:       []string{"Paris", "Lyon", "Marseille", "Toulouse", "Nantes", "Bordeaux", "Lille", "Strasbourg"},
+		emailDomains: []string{"example.fr", "example.com", "example.net"},
+		phoneFormat:  "+33 # ## ## ## ##",
+		address: func(number int, street, postcode, city string) string {
+			return fmt.Sprintf("%d %s, %s %s", number, street, postcode, city)
+		},
+	},
+}
+
+func fakeLocaleNames() []string {
+	names := make([]string, 0, len(fakeLocales))
+	for name := range fakeLocales {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// emailFolder spells the names of the locales in ASCII for the email addresses.
+var emailFolder = strings.NewReplacer(
+	"ä", "ae", "ö", "oe", "ü", "ue", "ß", "ss",
+	"à", "a", "â", "a", "ç", "c", "é", "e", "è", "e", "ê", "e", "ë", "e",
+	"î", "i", "ï", "i", "ô", "o", "ù", "u", "û", "u", "ÿ", "y",
+	" ", "",
+)
+
+// Faker generates semi-realistic values of a locale for '--template'.
+type Faker struct {
+	locale *fakeLocale
+}
+
+func NewFaker(locale string) (*Faker, error) {
+	l, ok := fakeLocales[locale]
+	if !ok {
+		return nil, fmt.Errorf("unknown fake locale %q, expected one of %s", locale, strings.Join(fakeLocaleNames(), ", "))
+	}
+	return &Faker{locale: l}, nil
+}
+
+func pick(values []string) string {
+	return values[rand.Intn(len(values))]
+}
+
+func (f *Faker) FirstName() string {
+	return pick(f.locale.firstNames)
+}
+
+func (f *Faker) LastName() string {
+	return pick(f.locale.lastNames)
+}
+
+func (f *Faker) Name() string {
+	return f.FirstName() + " " + f.LastName()
+}
+
+func (f *Faker) Email() string {
+	local := emailFolder.Replace(strings.ToLower(f.FirstName() + "." + f.LastName()))
+	return fmt.Sprintf("%s%d@%s", local, rand.Intn(100), pick(f.locale.emailDomains))
+}
+
+func (f *Faker) Phone() string {
+	var b strings.Builder
+	for _, c := range f.locale.phoneFormat {
+		if c == '#' {
+			b.WriteByte(byte('0' + rand.Intn(10)))
+		} else {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+func (f *Faker) Address() string {
+	postcode := fmt.Sprintf("%05d", 1000+rand.Intn(98000))
+	return f.locale.address(1+rand.Intn(200), pick(f.locale.streets), postcode, pick(f.locale.cities))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// newFollowClient returns the client of the follow-up requests of
+// '--follow-header', which may go to any host.
+func (r *Requester) newFollowClient() (*fasthttp.Client, error) {
+	tlsConfig, err := buildTLSConfig(r.clientOpt)
+	if err != nil {
+		return nil, err
+	}
+	return &fasthttp.Client{
+		Name:         "plow",
+		TLSConfig:    tlsConfig,
+		ReadTimeout:  r.clientOpt.readTimeout,
+		WriteTimeout: r.clientOpt.writeTimeout,
+		Dial:         r.resources.Dial(ThroughputInterceptorDial(fasthttp.Dial, &r.readBytes, &r.writeBytes)),
+	}, nil
+}
+
+// followUp sends the request of '--follow-header' to the URL the response
+// points to, resolved against the one of the target, and records its leg.
+func (r *Requester) followUp(t *target, resp *fasthttp.Response, rr *ReportRecord) error {
+	location := resp.Header.Peek(r.clientOpt.followHeader)
+	if len(location) == 0 {
+		rr.followMissing = true
+		return nil
+	}
+	u, err := t.base.Parse(string(location))
+	if err != nil {
+		return fmt.Errorf("follow-up: %v", err)
+	}
+	req := fasthttp.AcquireRequest()
+	followResp := fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(followResp)
+	}()
+	req.SetRequestURI(u.String())
+	req.Header.SetMethod(r.clientOpt.followMethod)
+
+	t1 := time.Now()
+	if r.clientOpt.doTimeout > 0 {
+		err = r.followClient.DoTimeout(req, followResp, r.clientOpt.doTimeout)
+	} else {
+		err = r.followClient.Do(req, followResp)
+	}
+	if err == nil {
+		err = followResp.BodyWriteTo(ioutil.Discard)
+	}
+	if err != nil {
+		return fmt.Errorf("follow-up: %v", err)
+	}
+	rr.follow = time.Since(t1)
+	rr.followCode = statusClass(followResp.StatusCode())
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type formField struct {
+	name  string
+	value string
+	file  bool
+}
+
+// FormBody builds multipart/form-data request bodies from '--form' fields,
+// where a value starting with @ is the name of a file to upload.
+type FormBody struct {
+	fields   []formField
+	boundary string
+}
+
+func NewFormBody(forms []string) (*FormBody, error) {
+	fb := &FormBody{boundary: multipart.NewWriter(nil).Boundary()}
+	for _, f := range forms {
+		n := strings.SplitN(f, "=", 2)
+		if len(n) != 2 || n[0] == "" {
+			return nil, fmt.Errorf("invalid form field: %s", f)
+		}
+		field := formField{name: n[0], value: n[1]}
+		if strings.HasPrefix(field.value, "@") {
+			field.value = field.value[1:]
+			field.file = true
+			if _, err := os.Stat(field.value); err != nil {
+				return nil, err
+			}
+		}
+		fb.fields = append(fb.fields, field)
+	}
+	return fb, nil
+}
+
+func (fb *FormBody) ContentType() string {
+	return "multipart/form-data; boundary=" + fb.boundary
+}
+
+func (fb *FormBody) writeTo(w io.Writer) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(fb.boundary); err != nil {
+		return err
+	}
+	for _, field := range fb.fields {
+		if !field.file {
+			if err := mw.WriteField(field.name, field.value); err != nil {
+				return err
+			}
+			continue
+		}
+		part, err := mw.CreateFormFile(field.name, filepath.Base(field.value))
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(field.value)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(part, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+func (fb *FormBody) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := fb.writeTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Stream returns a reader producing the body on the fly, so files are never
+// held in memory. fasthttp closes the reader once the request is written,
+// which also stops the writing goroutine.
+func (fb *FormBody) Stream() (io.Reader, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(fb.writeTo(pw))
+	}()
+	return pr, nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// grafanaPath is the root of the JSON datasource API of Grafana, the URL of a
+// datasource graphing the charts of the run next to the dashboards of the
+// servers.
+var grafanaPath = "/api/grafana"
+
+// grafanaMetric is a series of the charts a Grafana panel can query, ok being
+// false for the seconds it is missing from.
+type grafanaMetric struct {
+	name  string
+	value func(cr *ChartsReport) (v float64, ok bool)
+}
+
+// grafanaMetrics returns the series of the charts, the latencies in
+// milliseconds and the throughputs in MB/s as charted.
+func (c *Charts) grafanaMetrics() []*grafanaMetric {
+	metrics := []*grafanaMetric{
+		{"rps", func(cr *ChartsReport) (float64, bool) { return cr.RPS, true }},
+		{"errors", func(cr *ChartsReport) (float64, bool) { return cr.Errors, true }},
+		{"latency.min", func(cr *ChartsReport) (float64, bool) { return cr.Latency.min / 1e6, cr.Latency.count > 0 }},
+		{"latency.mean", func(cr *ChartsReport) (float64, bool) { return cr.Latency.Mean() / 1e6, cr.Latency.count > 0 }},
+		{"latency.max", func(cr *ChartsReport) (float64, bool) { return cr.Latency.max / 1e6, cr.Latency.count > 0 }},
+	}
+	for i, q := range chartPercentiles {
+		i := i
+		metrics = append(metrics, &grafanaMetric{"latency." + strings.ToLower(percentileName(q)), func(cr *ChartsReport) (float64, bool) {
+			// missing from the reports saved before they were charted
+			if i >= len(cr.Percentiles) || cr.Latency.count == 0 {
+				return 0, false
+			}
+			return cr.Percentiles[i] / 1e6, true
+		}})
+	}
+	for _, code := range chartCodes {
+		code := code
+		metrics = append(metrics, &grafanaMetric{"codes." + code, func(cr *ChartsReport) (float64, bool) {
+			return cr.Codes[code], cr.Codes != nil
+		}})
+	}
+	metrics = append(metrics,
+		&grafanaMetric{"throughput.read", func(cr *ChartsReport) (float64, bool) { return cr.ReadThroughput, true }},
+		&grafanaMetric{"throughput.write", func(cr *ChartsReport) (float64, bool) { return cr.WriteThroughput, true }},
+	)
+	for _, endpoint := range c.endpoints {
+		endpoint := endpoint
+		metrics = append(metrics, &grafanaMetric{"endpoint." + endpoint, func(cr *ChartsReport) (float64, bool) {
+			v, ok := cr.endpoint(endpoint)
+			return v / 1e6, ok
+		}})
+	}
+	if c.ttfb {
+		metrics = append(metrics,
+			&grafanaMetric{"ttfb.mean", func(cr *ChartsReport) (float64, bool) {
+				return grafanaStat(cr.TTFB, func(s *Stats) float64 { return s.Mean() })
+			}},
+			&grafanaMetric{"ttlb.mean", func(cr *ChartsReport) (float64, bool) {
+				return grafanaStat(cr.TTLB, func(s *Stats) float64 { return s.Mean() })
+			}},
+		)
+	}
+	if c.schedule {
+		metrics = append(metrics,
+			&grafanaMetric{"target.rate", func(cr *ChartsReport) (float64, bool) {
+				if cr.Target == nil {
+					return 0, false
+				}
+				return cr.Target.Rate, cr.Target.Rate > 0
+			}},
+			&grafanaMetric{"target.concurrency", func(cr *ChartsReport) (float64, bool) {
+				if cr.Target == nil {
+					return 0, false
+				}
+				return float64(cr.Target.Concurrency), true
+			}},
+		)
+	}
+	return metrics
+}
+
+func grafanaStat(s *Stats, value func(s *Stats) float64) (float64, bool) {
+	if s == nil {
+		return 0, false
+	}
+	return value(s) / 1e6, true
+}
+
+// grafanaQuery is the body of a query of Grafana, the datapoints of the
+// targets within the range.
+type grafanaQuery struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	Targets []struct {
+		Target string `json:"target"`
+	} `json:"targets"`
+	MaxDataPoints int `json:"maxDataPoints"`
+}
+
+// grafanaSeries is a series answered to a query, every datapoint a value and
+// its time in milliseconds.
+type grafanaSeries struct {
+	Target     string           `json:"target"`
+	Datapoints [][2]interface{} `json:"datapoints"`
+}
+
+// serveGrafana answers the endpoints of the JSON datasource: the root tests
+// the datasource, search lists the metrics and query returns their series,
+// there being no annotations.
+func (c *Charts) serveGrafana(ctx *fasthttp.RequestCtx, path string) {
+	ctx.SetContentType("application/json")
+	switch strings.TrimPrefix(path, grafanaPath) {
+	case "", "/":
+		ctx.SetBodyString(`{"status":"ok"}`)
+	case "/search":
+		var names []string
+		for _, m := range c.grafanaMetrics() {
+			names = append(names, m.name)
+		}
+		_ = json.NewEncoder(ctx).Encode(names)
+	case "/query":
+		var query grafanaQuery
+		if err := json.Unmarshal(ctx.PostBody(), &query); err != nil {
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(ctx).Encode(c.grafanaQuery(&query))
+	case "/annotations":
+		ctx.SetBodyString("[]")
+	default:
+		ctx.Error("NotFound", fasthttp.StatusNotFound)
+	}
+}
+
+// grafanaQuery returns the series of the targets of query, at most
+// MaxDataPoints of them, every so many seconds beyond.
+func (c *Charts) grafanaQuery(query *grafanaQuery) []*grafanaSeries {
+	var history []*ChartsReport
+	for _, cr := range c.chartsHistory() {
+		if (query.Range.From.IsZero() || !cr.Time.Before(query.Range.From)) &&
+			(query.Range.To.IsZero() || !cr.Time.After(query.Range.To)) {
+			history = append(history, cr)
+		}
+	}
+	step := 1
+	if query.MaxDataPoints > 0 && len(history) > query.MaxDataPoints {
+		step = (len(history) + query.MaxDataPoints - 1) / query.MaxDataPoints
+	}
+	metrics := make(map[string]*grafanaMetric)
+	for _, m := range c.grafanaMetrics() {
+		metrics[m.name] = m
+	}
+	series := make([]*grafanaSeries, 0, len(query.Targets))
+	for _, t := range query.Targets {
+		m, ok := metrics[t.Target]
+		if !ok {
+			continue
+		}
+		s := &grafanaSeries{Target: t.Target, Datapoints: [][2]interface{}{}}
+		for i := 0; i < len(history); i += step {
+			cr := history[i]
+			if v, ok := m.value(cr); ok {
+				s.Datapoints = append(s.Datapoints, [2]interface{}{v, cr.Time.UnixNano() / 1e6})
+			}
+		}
+		series = append(series, s)
+	}
+	return series
+}
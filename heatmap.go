@@ -0,0 +1,99 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/components"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// heatmapBounds are the upper bounds of the latency buckets of the heatmap,
+// the last bucket holding the latencies above them.
+var heatmapBounds = []time.Duration{
+	100 * time.Microsecond, 200 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2 * time.Second, 5 * time.Second,
+}
+
+// heatmapBucket returns the bucket of the heatmap of latency d.
+func heatmapBucket(d time.Duration) int {
+	return sort.Search(len(heatmapBounds), func(i int) bool { return d <= heatmapBounds[i] })
+}
+
+// heatmapLabels name the buckets of the heatmap, like ≤1ms.
+func heatmapLabels() []string {
+	labels := make([]string, 0, len(heatmapBounds)+1)
+	for _, b := range heatmapBounds {
+		labels = append(labels, "≤"+b.String())
+	}
+	return append(labels, ">"+heatmapBounds[len(heatmapBounds)-1].String())
+}
+
+// HeatmapTpl adds the counts of the buckets of a second to the heatmap,
+// raising the top of its colors along the busiest bucket.
+const HeatmapTpl = `
+$(function () { plowSubscribe("{{ .Route }}", {{ .ViewID }}_sync); });
+function {{ .ViewID }}_sync(result) {
+    let opt = goecharts_{{ .ViewID }}.getOption();
+    let x = opt.xAxis[0].data || [];
+    x.push(result.time);
+    let data = opt.series[0].data;
+    let max = opt.visualMap[0].max;
+    for (let y = 0; y < result.values.length; y++) {
+        if (result.values[y] > 0) {
+            data.push([x.length - 1, y, result.values[y]]);
+            max = Math.max(max, result.values[y]);
+        }
+    }
+    opt.xAxis[0].data = x;
+    opt.series[0].data = data;
+    opt.visualMap[0].max = max;
+    goecharts_{{ .ViewID }}.setOption(opt);
+}`
+
+// newHeatmapView charts the latencies of every second by bucket, showing
+// what the percentiles hide, like the two modes of a slow second backend.
+func (c *Charts) newHeatmapView() components.Charter {
+	graph := charts.NewHeatMap()
+	var data []opts.HeatMapData
+	var max int64 = 1
+	for x, cr := range c.history {
+		for y, n := range cr.Heatmap {
+			if n > 0 {
+				data = append(data, opts.HeatMapData{Value: [3]interface{}{x, y, n}})
+			}
+			if n > max {
+				max = n
+			}
+		}
+	}
+	graph.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Latency Heatmap"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: true}),
+		// the heatmap doesn't set the data of SetXAxis on its axis
+		charts.WithXAxisOpts(opts.XAxis{Name: "Time", Type: "category", Data: c.historyTimes()}),
+		charts.WithYAxisOpts(opts.YAxis{Type: "category", Data: heatmapLabels()}),
+		charts.WithVisualMapOpts(opts.VisualMap{
+			Calculable: true,
+			Max:        float32(max),
+			InRange:    &opts.VisualMapInRange{Color: []string{"#f6efa6", "#d88273", "#bf444c"}},
+		}),
+		charts.WithInitializationOpts(opts.Initialization{
+			Width:  "700px",
+			Height: "400px",
+		}),
+		charts.WithDataZoomOpts(opts.DataZoom{
+			Type:       "slider",
+			XAxisIndex: []int{0},
+		}),
+	)
+	graph.AddSeries("Requests", data)
+	if c.dataFunc != nil {
+		graph.AddJSFuncs(c.genViewTemplate(HeatmapTpl, graph.ChartID, heatmapView))
+	}
+	return graph
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// historySaveInterval is how often a run is saved to '--history' until done,
+// so that the charts outlive a run killed early.
+const historySaveInterval = 10 * time.Second
+
+var runsPath = "/api/runs"
+
+// RunHistory keeps the reports of the runs in the directory of '--history',
+// one file per run ID, for the Web UI to compare them.
+type RunHistory struct {
+	dir string
+}
+
+func OpenRunHistory(dir string) (*RunHistory, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &RunHistory{dir: dir}, nil
+}
+
+func (h *RunHistory) path(id string) (string, error) {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("invalid run ID %q", id)
+	}
+	return filepath.Join(h.dir, id+".json"), nil
+}
+
+// Save saves the report of the run so far, replacing the previous save.
+func (h *RunHistory) Save(report *StreamReport, id, desc string) error {
+	path, err := h.path(id)
+	if err != nil {
+		return err
+	}
+	saved := report.saved(desc)
+	saved.RunID = id
+	saved.Started = startTime
+	// written aside first, not to serve a partial file
+	tmp := path + ".tmp"
+	if err = writeReport(tmp, saved); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// SaveUntil saves the report of the run every historySaveInterval until done.
+func (h *RunHistory) SaveUntil(report *StreamReport, id, desc string, done <-chan struct{}) {
+	ticker := time.NewTicker(historySaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = h.Save(report, id, desc)
+		case <-done:
+			return
+		}
+	}
+}
+
+func (h *RunHistory) Load(id string) (*SavedReport, error) {
+	path, err := h.path(id)
+	if err != nil {
+		return nil, err
+	}
+	return LoadReport(path)
+}
+
+// RunInfo describes a run of the history.
+type RunInfo struct {
+	ID          string
+	Started     time.Time
+	Description string
+}
+
+// Runs lists the runs of the history, the latest first, skipping the files
+// which can't be read.
+func (h *RunHistory) Runs() ([]*RunInfo, error) {
+	files, err := ioutil.ReadDir(h.dir)
+	if err != nil {
+		return nil, err
+	}
+	runs := []*RunInfo{}
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		saved, err := LoadReport(filepath.Join(h.dir, name))
+		if err != nil {
+			continue
+		}
+		id := strings.TrimSuffix(name, ".json")
+		runs = append(runs, &RunInfo{ID: id, Started: saved.Started, Description: saved.Description})
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Started.After(runs[j].Started) })
+	return runs, nil
+}
+
+// runCharts are the series of the views of a saved run, as laid out on the
+// page to be overlaid on its charts.
+type runCharts struct {
+	ID    string                     `json:"id"`
+	Views map[string][][]interface{} `json:"views"`
+}
+
+// serveRuns answers the list of the runs of the history on runsPath, and the
+// charts of one of them on runsPath/ID.
+func (c *Charts) serveRuns(ctx *fasthttp.RequestCtx, path string) {
+	if path == runsPath {
+		runs, err := c.runs.Runs()
+		if err != nil {
+			ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
+			return
+		}
+		var others []*RunInfo
+		for _, run := range runs {
+			if run.ID != c.runID {
+				others = append(others, run)
+			}
+		}
+		ctx.SetContentType("application/json")
+		_ = json.NewEncoder(ctx).Encode(others)
+		return
+	}
+	id := path[len(runsPath)+1:]
+	saved, err := c.runs.Load(id)
+	if err != nil {
+		ctx.Error(err.Error(), fasthttp.StatusNotFound)
+		return
+	}
+	rc := &runCharts{ID: id, Views: make(map[string][][]interface{})}
+	for _, view := range c.views() {
+		var series [][]interface{}
+		for _, cr := range saved.Charts {
+			for i, v := range c.chartValues(view, cr) {
+				if i == len(series) {
+					series = append(series, []interface{}{})
+				}
+				series[i] = append(series[i], v)
+			}
+		}
+		rc.Views[view] = series
+	}
+	ctx.SetContentType("application/json")
+	_ = json.NewEncoder(ctx).Encode(rc)
+}
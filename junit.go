@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runCheck is a check of the run, reported as a test case of '--junit'.
+type runCheck struct {
+	name string
+	// failure tells why the check failed, empty if it passed
+	failure string
+}
+
+// checkOptions tells the checks asked for by the options of the run.
+type checkOptions struct {
+	strict     bool
+	stableCond *StableCondition
+	stabled    bool
+	workerErrs []string
+	abortConds []*AbortCondition
+	// aborted tells why '--abort-on' aborted the run, empty if it didn't
+	aborted string
+	// regressions are those of '--baseline-tolerance', nil without
+	regressions []string
+}
+
+// runChecks are the checks the final report passed or failed, the ones of the
+// options not given being left out.
+func runChecks(rs *SnapshotReport, opt *checkOptions) []*runCheck {
+	var checks []*runCheck
+
+	requests := &runCheck{name: "requests"}
+	var failed int64
+	for _, n := range rs.Errors {
+		failed += n
+	}
+	if rs.Count == 0 {
+		requests.failure = "no request was sent"
+	} else if failed > 0 {
+		requests.failure = fmt.Sprintf("%d of %d request(s) failed: %s", failed, rs.Count, countsString(rs.Errors))
+	}
+	checks = append(checks, requests)
+
+	codes := &runCheck{name: "status codes"}
+	bad := make(map[string]int64)
+	for code, n := range rs.Codes {
+		if strings.HasPrefix(code, "4") || strings.HasPrefix(code, "5") {
+			bad[code] = n
+		}
+	}
+	if len(bad) > 0 {
+		codes.failure = "error responses: " + countsString(bad)
+	}
+	checks = append(checks, codes)
+
+	for _, c := range rs.Checks {
+		check := &runCheck{name: "check " + c.Name}
+		if c.Fails > 0 {
+			check.failure = fmt.Sprintf("%d of %d response(s) failed", c.Fails, c.Passes+c.Fails)
+		} else if c.Passes == 0 {
+			check.failure = "no response was checked"
+		}
+		checks = append(checks, check)
+	}
+	if opt.strict {
+		check := &runCheck{name: "protocol violations"}
+		if len(rs.Violations) > 0 {
+			check.failure = countsString(rs.Violations)
+		}
+		checks = append(checks, check)
+	}
+	if rs.AffinityChecks > 0 {
+		check := &runCheck{name: "affinity"}
+		if rs.AffinityViolations > 0 {
+			check.failure = fmt.Sprintf("%d of %d request(s) hit another backend", rs.AffinityViolations, rs.AffinityChecks)
+		}
+		checks = append(checks, check)
+	}
+	if len(rs.HSTS) > 0 {
+		check := &runCheck{name: "hsts"}
+		notOK := make(map[string]int64)
+		for result, n := range rs.HSTS {
+			if result != "ok" {
+				notOK[result] = n
+			}
+		}
+		if len(notOK) > 0 {
+			check.failure = countsString(notOK)
+		}
+		checks = append(checks, check)
+	}
+	if fr := rs.FollowUps; fr != nil {
+		check := &runCheck{name: "follow-ups"}
+		failedFollows := make(map[string]int64)
+		for code, n := range fr.Codes {
+			if code != "2xx" {
+				failedFollows[code] = n
+			}
+		}
+		if fr.Missing > 0 {
+			failedFollows["missing"] = fr.Missing
+		}
+		if len(failedFollows) > 0 {
+			check.failure = countsString(failedFollows)
+		}
+		checks = append(checks, check)
+	}
+	if opt.stableCond != nil {
+		check := &runCheck{name: "stable latency"}
+		if !opt.stabled {
+			check.failure = fmt.Sprintf("the latency did not converge: %s", opt.stableCond)
+		}
+		checks = append(checks, check)
+	}
+	if opt.abortConds != nil {
+		checks = append(checks, &runCheck{name: "abort", failure: opt.aborted})
+	}
+	if opt.regressions != nil {
+		check := &runCheck{name: "baseline"}
+		if len(opt.regressions) > 0 {
+			check.failure = "regressed: " + strings.Join(opt.regressions, ", ")
+		}
+		checks = append(checks, check)
+	}
+	if opt.workerErrs != nil {
+		check := &runCheck{name: "workers"}
+		if len(opt.workerErrs) > 0 {
+			check.failure = strings.Join(opt.workerErrs, "; ")
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// countsString lists counts like "timeout=3, refused=1", the largest first.
+func countsString(counts map[string]int64) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%d", k, counts[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name          `xml:"testsuites"`
+	Suites  []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Time      string           `xml:"time,attr"`
+	Timestamp string           `xml:"timestamp,attr"`
+	Cases     []*junitTestCase `xml:"testcase"`
+	SystemOut *junitOutput     `xml:"system-out,omitempty"`
+}
+
+type junitOutput struct {
+	Text string `xml:",cdata"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes the checks of the run to fileName as JUnit XML for '--junit',
+// with the summary as the output of the suite.
+func WriteJUnit(fileName, desc string, rs *SnapshotReport, checks []*runCheck, useSeconds bool) error {
+	var summary bytes.Buffer
+	summary.WriteString(desc + "\n\n")
+	NewPrinter(0, 0, false, true).formatTableReports(&summary, rs, true, useSeconds)
+
+	suite := &junitTestSuite{
+		Name:      "plow",
+		Tests:     len(checks),
+		Time:      fmt.Sprintf("%.3f", rs.Elapsed.Seconds()),
+		Timestamp: startTime.Format(time.RFC3339),
+		SystemOut: &junitOutput{colorSeq.ReplaceAllString(summary.String(), "")},
+	}
+	for _, check := range checks {
+		tc := &junitTestCase{Name: check.name, ClassName: "plow"}
+		if check.failure != "" {
+			tc.Failure = &junitFailure{Message: check.failure}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	data, err := xml.MarshalIndent(&junitTestSuites{Suites: []*junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, append([]byte(xml.Header), data...), 0644)
+}
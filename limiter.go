@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// arrivals are the distributions of the gaps between requests sent at a rate.
+var arrivals = []string{"constant", "poisson", "uniform"}
+
+// RateLimiter paces requests at a rate which can be changed while running,
+// the gaps between them following the arrival distribution, evenly spaced
+// by default. A zero rate doesn't limit.
+type RateLimiter struct {
+	lock     sync.Mutex
+	arrival  string
+	interval time.Duration
+	next     time.Time
+	// due follows the schedule at the rate, behind next once requests could
+	// not be sent in time
+	due time.Time
+}
+
+func NewRateLimiter(arrival string) *RateLimiter {
+	return &RateLimiter{arrival: arrival}
+}
+
+// gap returns the time until the next request, the interval on average.
+func (l *RateLimiter) gap() time.Duration {
+	switch l.arrival {
+	case "poisson":
+		// the gaps between the events of a poisson process are exponential
+		return time.Duration(rand.ExpFloat64() * float64(l.interval))
+	case "uniform":
+		return time.Duration(rand.Int63n(2*int64(l.interval) + 1))
+	}
+	return l.interval
+}
+
+func (l *RateLimiter) SetRate(rate float64) {
+	l.lock.Lock()
+	if rate > 0 {
+		l.interval = time.Duration(float64(time.Second) / rate)
+	} else {
+		l.interval = 0
+		l.due = time.Time{}
+	}
+	l.lock.Unlock()
+}
+
+// Wait blocks until the next request may be sent, it returns false if ctx is
+// done first. Time left unused is not made up for with bursts. The time the
+// request was due at is returned, zero without a rate.
+func (l *RateLimiter) Wait(ctx context.Context) (time.Time, bool) {
+	l.lock.Lock()
+	if l.interval == 0 {
+		l.lock.Unlock()
+		return time.Time{}, true
+	}
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	if l.due.IsZero() {
+		l.due = now
+	}
+	at, due := l.next, l.due
+	gap := l.gap()
+	l.next = l.next.Add(gap)
+	l.due = l.due.Add(gap)
+	l.lock.Unlock()
+
+	d := time.Until(at)
+	if d <= 0 {
+		return due, true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return due, true
+	case <-ctx.Done():
+		return due, false
+	}
+}
+
+// ConcurrencyGate lets the first n workers run and parks the others, so the
+// concurrency can be changed while running. All are parked while paused.
+type ConcurrencyGate struct {
+	lock    sync.Mutex
+	n       int
+	paused  bool
+	changed chan struct{}
+}
+
+func NewConcurrencyGate(n int) *ConcurrencyGate {
+	return &ConcurrencyGate{n: n, changed: make(chan struct{})}
+}
+
+func (g *ConcurrencyGate) Set(n int) {
+	g.lock.Lock()
+	if n != g.n {
+		g.n = n
+		g.notify()
+	}
+	g.lock.Unlock()
+}
+
+func (g *ConcurrencyGate) Pause(paused bool) {
+	g.lock.Lock()
+	if paused != g.paused {
+		g.paused = paused
+		g.notify()
+	}
+	g.lock.Unlock()
+}
+
+func (g *ConcurrencyGate) Paused() bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.paused
+}
+
+// notify wakes up the parked workers, it must be called with the lock held.
+func (g *ConcurrencyGate) notify() {
+	close(g.changed)
+	g.changed = make(chan struct{})
+}
+
+// Wait blocks until the i-th worker may run, it returns false if ctx is done
+// first.
+func (g *ConcurrencyGate) Wait(ctx context.Context, i int) bool {
+	for {
+		g.lock.Lock()
+		if i < g.n && !g.paused {
+			g.lock.Unlock()
+			return true
+		}
+		changed := g.changed
+		g.lock.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// ThinkTime is the pause of a worker between its requests, like a user
+// reading a page, drawn evenly from Min to Max.
+type ThinkTime struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// ParseThinkTime parses a duration like 200ms, or a range of durations like
+// 100ms..500ms.
+func ParseThinkTime(s string) (ThinkTime, error) {
+	var t ThinkTime
+	var err error
+	n := strings.SplitN(s, "..", 2)
+	if t.Min, err = time.ParseDuration(n[0]); err != nil {
+		return t, fmt.Errorf("invalid think time %q: %v", s, err)
+	}
+	t.Max = t.Min
+	if len(n) == 2 {
+		if t.Max, err = time.ParseDuration(n[1]); err != nil {
+			return t, fmt.Errorf("invalid think time %q: %v", s, err)
+		}
+	}
+	if t.Min < 0 || t.Max < t.Min {
+		return t, fmt.Errorf("invalid think time %q, expected DURATION or MIN..MAX", s)
+	}
+	return t, nil
+}
+
+// Pause blocks for a think time, it returns false if ctx is done first.
+func (t ThinkTime) Pause(ctx context.Context) bool {
+	d := t.Min
+	if t.Max > t.Min {
+		d += time.Duration(rand.Int63n(int64(t.Max-t.Min) + 1))
+	}
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// tlsListener tells the listeners of the Web UI serving https apart.
+type tlsListener struct {
+	net.Listener
+}
+
+// ListenCharts listens on addr for the Web UI, over TLS if given the
+// certificate and key of '--listen-tls-cert' and '--listen-tls-key'.
+func ListenCharts(addr, certFile, keyFile string) (net.Listener, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("listen-tls-cert and listen-tls-key must be given together")
+	}
+	return listenTLS(addr, certFile, keyFile)
+}
+
+// listenTLS listens on addr, over TLS if given a certificate and its key.
+func listenTLS(addr, certFile, keyFile string) (net.Listener, error) {
+	var config *tls.Config
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return ln, nil
+	}
+	return &tlsListener{tls.NewListener(ln, config)}, nil
+}
+
+// listenURL is the URL of the Web UI served on ln.
+func listenURL(ln net.Listener) string {
+	if _, ok := ln.(*tlsListener); ok {
+		return "https://" + ln.Addr().String()
+	}
+	return "http://" + ln.Addr().String()
+}
+
+// isLoopbackListener tells whether ln only accepts the connections of the
+// local host.
+func isLoopbackListener(ln net.Listener) bool {
+	addr, ok := ln.Addr().(*net.TCPAddr)
+	return ok && addr.IP.IsLoopback()
+}
+
+// ParseListenAuth parses the USER:PASS of '--listen-auth'.
+func ParseListenAuth(s string) (string, string, error) {
+	n := strings.SplitN(s, ":", 2)
+	if len(n) != 2 || n[0] == "" || n[1] == "" {
+		return "", "", fmt.Errorf("invalid listen-auth %q, expected USER:PASS", s)
+	}
+	return n[0], n[1], nil
+}
+
+// basicAuth lets the requests with the credentials of auth through only.
+func basicAuth(handler fasthttp.RequestHandler, user, pass string) fasthttp.RequestHandler {
+	expected := []byte("Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass)))
+	return func(ctx *fasthttp.RequestCtx) {
+		if subtle.ConstantTimeCompare(ctx.Request.Header.Peek("Authorization"), expected) != 1 {
+			ctx.Response.Header.Set("WWW-Authenticate", `Basic realm="plow"`)
+			ctx.Error("Unauthorized", fasthttp.StatusUnauthorized)
+			return
+		}
+		handler(ctx)
+	}
+}
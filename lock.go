@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// errLocked is returned by LockRun when another run holds the lock.
+var errLocked = errors.New("locked by another run")
+
+// RunLock is the lock of '--lock' held for the run, so that the runs of a
+// host against the same target don't skew each other.
+type RunLock struct {
+	f *os.File
+}
+
+// lockPath returns the file of '--lock', one in the temporary directory named
+// after the hosts of the urls for "auto".
+func lockPath(spec string, urls []string) string {
+	if spec != "auto" {
+		return spec
+	}
+	var hosts []string
+	for _, u := range urls {
+		if parsed, err := url.Parse(u); err == nil {
+			hosts = append(hosts, parsed.Host)
+		} else {
+			hosts = append(hosts, u)
+		}
+	}
+	sort.Strings(hosts)
+	sum := sha1.Sum([]byte(strings.Join(hosts, " ")))
+	return filepath.Join(os.TempDir(), "plow-"+hex.EncodeToString(sum[:6])+".lock")
+}
+
+// LockRun takes the lock of path, waiting for the run holding it if wait,
+// failing with errLocked otherwise. The lock is held until the process exits,
+// along with the listener of the Web UI, as long as the RunLock is kept.
+func LockRun(path string, wait bool) (*RunLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err = lockFile(f, wait); err != nil {
+		f.Close()
+		return nil, err
+	}
+	// tell who holds it to the runs failing to take it
+	_ = f.Truncate(0)
+	_, _ = f.WriteAt([]byte(fmt.Sprintf("pid %d\n", os.Getpid())), 0)
+	return &RunLock{f: f}, nil
+}
+
+// lockHolder describes the run holding the lock of path, if it's known.
+func lockHolder(path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
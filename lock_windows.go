@@ -0,0 +1,10 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func lockFile(f *os.File, wait bool) error {
+	return fmt.Errorf("can not lock %s, '--lock' is not supported on Windows", f.Name())
+}
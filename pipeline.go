@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// httpDoer sends the requests of a worker target, a HostClient of its own or
+// the PipelineClient of '--pipeline' it shares.
+type httpDoer interface {
+	Do(req *fasthttp.Request, resp *fasthttp.Response) error
+	DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error
+}
+
+// idleCloser is a client whose idle connections are closed once the run is
+// over.
+type idleCloser interface {
+	CloseIdleConnections()
+}
+
+// pipelineKey is the connection of '--pipeline' of a group of workers to a
+// target.
+type pipelineKey struct {
+	group  int64
+	target int
+}
+
+// quietLogger silences the connection errors the PipelineClient logs, as they
+// are those of the requests reported.
+type quietLogger struct{}
+
+func (quietLogger) Printf(format string, args ...interface{}) {}
+
+// pipelineClient is the client of '--pipeline' shared by a group of workers,
+// along with the tracker of its connection.
+type pipelineClient struct {
+	*fasthttp.PipelineClient
+	conn *connTracker
+
+	lock sync.Mutex
+	// open is the connection last opened, which the PipelineClient only
+	// closes once idle for long
+	open net.Conn
+}
+
+func (c *pipelineClient) dial(dial fasthttp.DialFunc) fasthttp.DialFunc {
+	return func(addr string) (net.Conn, error) {
+		conn, err := dial(addr)
+		if err == nil {
+			c.lock.Lock()
+			c.open = conn
+			c.lock.Unlock()
+		}
+		return conn, err
+	}
+}
+
+// CloseIdleConnections closes the connection once the run is over, no
+// request being in flight anymore.
+func (c *pipelineClient) CloseIdleConnections() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.open != nil {
+		c.open.Close()
+		c.open = nil
+	}
+}
+
+// setPipelineClient sets the client of the group of workers of w to the i-th
+// target, the '--pipeline' workers of a group sending their requests over one
+// connection without waiting for the responses, which come back in order. The
+// workers of the group share the tracker of the connection, the timings of a
+// new one going to the first request done after it opened.
+func (r *Requester) setPipelineClient(w *worker, i int, wt *workerTarget) {
+	key := pipelineKey{group: (w.scope.Index - r.workerIndex) / int64(r.clientOpt.pipeline), target: i}
+	r.workersLock.Lock()
+	defer r.workersLock.Unlock()
+	if c, ok := r.pipelines[key]; ok {
+		wt.client, wt.conn = c, c.conn
+		return
+	}
+	t := r.targets[i]
+	wt.dial = t.host.Dial(r.resources.Dial(t.dial(wt.conn)))
+	c := &pipelineClient{conn: wt.conn}
+	c.PipelineClient = &fasthttp.PipelineClient{
+		Addr:                          t.host.addr,
+		Name:                          "plow",
+		MaxConns:                      1,
+		MaxPendingRequests:            r.clientOpt.pipeline,
+		MaxIdleConnDuration:           r.clientOpt.maxIdleConnDuration(),
+		ReadTimeout:                   r.clientOpt.readTimeout,
+		WriteTimeout:                  r.clientOpt.writeTimeout,
+		DisableHeaderNamesNormalizing: true,
+		Dial:                          c.dial(wt.dialConn),
+		Logger:                        quietLogger{},
+	}
+	if r.pipelines == nil {
+		r.pipelines = make(map[pipelineKey]*pipelineClient)
+	}
+	r.pipelines[key] = c
+	r.clients = append(r.clients, c)
+	wt.client = c
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	url2 "net/url"
+	"strconv"
+	"strings"
+)
+
+// ParsePortRange parses a range of ports like 8080-8090.
+func ParsePortRange(s string) (int, int, error) {
+	n := strings.SplitN(s, "-", 2)
+	if len(n) != 2 {
+		return 0, 0, fmt.Errorf("invalid port range %q, expected FIRST-LAST", s)
+	}
+	first, err1 := strconv.Atoi(n[0])
+	last, err2 := strconv.Atoi(n[1])
+	if err1 != nil || err2 != nil || first < 1 || last > 65535 || first > last {
+		return 0, 0, fmt.Errorf("invalid port range %q, expected FIRST-LAST within 1-65535", s)
+	}
+	return first, last, nil
+}
+
+// ExpandPortRange returns the urls with every port of the range in place of
+// their own one, the urls of a port following each other.
+func ExpandPortRange(urls []string, portRange string) ([]string, error) {
+	first, last, err := ParsePortRange(portRange)
+	if err != nil {
+		return nil, err
+	}
+	var expanded []string
+	for port := first; port <= last; port++ {
+		for _, rawURL := range urls {
+			u, err := withPort(rawURL, port)
+			if err != nil {
+				return nil, err
+			}
+			expanded = append(expanded, u)
+		}
+	}
+	return expanded, nil
+}
+
+// withPort replaces the port of rawURL in place, since formatting the parsed
+// URL again would escape the template actions of '--template'.
+func withPort(rawURL string, port int) (string, error) {
+	u, err := url2.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	i := strings.Index(rawURL, "://")
+	if i < 0 || u.Host == "" {
+		return "", fmt.Errorf("can not set the port of %s without a host", rawURL)
+	}
+	start := i + 3
+	end := len(rawURL)
+	if j := strings.IndexAny(rawURL[start:], "/?#"); j >= 0 {
+		end = start + j
+	}
+	hostport := net.JoinHostPort(u.Hostname(), strconv.Itoa(port))
+	if k := strings.LastIndexByte(rawURL[start:end], '@'); k >= 0 {
+		// keep the user info
+		start += k + 1
+	}
+	return rawURL[:start] + hostport + rawURL[end:], nil
+}
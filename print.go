@@ -22,6 +22,11 @@ var (
 	barSpinner = []string{"|", "/", "-", "\\"}
 	clearLine  = []byte("\r\033[K")
 	isTerminal = isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd())
+
+	// ciMode prints fixed width columns and fixed precision numbers, so that
+	// the reports of different runs can be diffed line by line
+	ciMode        = false
+	ciColumnWidth = 12
 )
 
 type Printer struct {
@@ -138,11 +143,35 @@ func colorize(s string, seq int) string {
 func durationToString(d time.Duration, useSeconds bool) string {
 	d = d.Truncate(time.Microsecond)
 	if useSeconds {
+		if ciMode {
+			return strconv.FormatFloat(d.Seconds(), 'f', 6, 64)
+		}
 		return formatFloat64(d.Seconds())
 	}
+	if ciMode {
+		return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64) + "ms"
+	}
 	return d.String()
 }
 
+// sizeToString formats a size in bytes like 1.5KB.
+func sizeToString(b float64) string {
+	switch {
+	case b >= 1<<20:
+		return rateToString(b/(1<<20)) + "MB"
+	case b >= 1<<10:
+		return rateToString(b/(1<<10)) + "KB"
+	}
+	return rateToString(b) + "B"
+}
+
+func rateToString(f float64) string {
+	if ciMode {
+		return strconv.FormatFloat(f, 'f', 2, 64)
+	}
+	return formatFloat64(math.Trunc(f*100) / 100.0)
+}
+
 func alignBulk(bulk [][]string, aligns ...int) {
 	maxLen := map[int]int{}
 	for _, b := range bulk {
@@ -153,6 +182,13 @@ func alignBulk(bulk [][]string, aligns ...int) {
 			}
 		}
 	}
+	if ciMode {
+		for i := range maxLen {
+			if maxLen[i] < ciColumnWidth {
+				maxLen[i] = ciColumnWidth
+			}
+		}
+	}
 	for _, b := range bulk {
 		for i, ali := range aligns {
 			if len(b) >= i+1 {
@@ -188,9 +224,10 @@ func formatFloat64(f float64) string {
 func (p *Printer) formatTableReports(writer *bytes.Buffer, snapshot *SnapshotReport, isFinal bool, useSeconds bool) {
 	summaryBulk := p.buildSummary(snapshot, isFinal)
 	errorsBulks := p.buildErrors(snapshot)
+	violationsBulk := p.buildViolations(snapshot)
 	statsBulk := p.buildStats(snapshot, useSeconds)
 	percBulk := p.buildPercentile(snapshot, useSeconds)
-	hisBulk := p.buildHistogram(snapshot, useSeconds, isFinal)
+	hisBulk := p.buildHistogram(snapshot.Histograms, useSeconds, isFinal)
 
 	writer.WriteString("Summary:\n")
 	writeBulk(writer, summaryBulk)
@@ -202,28 +239,393 @@ func (p *Printer) formatTableReports(writer *bytes.Buffer, snapshot *SnapshotRep
 		writer.WriteString("\n")
 	}
 
+	if violationsBulk != nil {
+		writer.WriteString("Protocol Violation:\n")
+		writeBulk(writer, violationsBulk)
+		writer.WriteString("\n")
+	}
+
+	if len(snapshot.Checks) > 0 {
+		writeBulkWith(writer, p.buildChecks(snapshot.Checks), "", "  ", "\n")
+		writer.WriteString("\n")
+	}
+
 	writeBulkWith(writer, statsBulk, "", "  ", "\n")
 	writer.WriteString("\n")
 
-	writer.WriteString("Latency Percentile:\n")
+	if len(snapshot.Variants) == 2 {
+		a, b := snapshot.Variants[0], snapshot.Variants[1]
+		writer.WriteString(fmt.Sprintf("A/B: A is %s, B is %s\n", a.URL, b.URL))
+		writeBulk(writer, buildCompareTable(variantMetrics(a, b, useSeconds), "A", "B"))
+		writer.WriteString("\n")
+	}
+
+	for _, dimension := range groupDimensions {
+		if groupsBulk := p.buildGroups(snapshot, dimension, useSeconds); groupsBulk != nil {
+			writeBulkWith(writer, groupsBulk, "", "  ", "\n")
+			writer.WriteString("\n")
+		}
+	}
+
+	if len(snapshot.Connections) > 0 {
+		writeBulkWith(writer, p.buildConnections(snapshot.Connections, useSeconds), "", "  ", "\n")
+		writer.WriteString("\n")
+	}
+
+	if redirectsBulk := p.buildRedirects(snapshot); redirectsBulk != nil {
+		writeBulkWith(writer, redirectsBulk, "", "  ", "\n")
+		writer.WriteString("\n")
+	}
+	if hstsBulk := p.buildHSTS(snapshot); hstsBulk != nil {
+		writeBulkWith(writer, hstsBulk, "", "  ", "\n")
+		writer.WriteString("\n")
+	}
+	if snapshot.FollowUps != nil {
+		writeBulkWith(writer, p.buildFollowUps(snapshot.FollowUps), "", "  ", "\n")
+		writer.WriteString("\n")
+	}
+	if snapshot.Continue != nil {
+		writeBulkWith(writer, p.buildContinue(snapshot.Continue), "", "  ", "\n")
+		writer.WriteString("\n")
+	}
+	if snapshot.LatencySplit != nil {
+		writeBulkWith(writer, p.buildLatencySplit(snapshot.LatencySplit), "", "  ", "\n")
+		writer.WriteString("\n")
+	}
+	if snapshot.Cache != nil {
+		writeBulkWith(writer, p.buildCache(snapshot.Cache, useSeconds), "", "  ", "\n")
+		writer.WriteString("\n")
+		writeBulkWith(writer, p.buildCacheControls(snapshot.Cache), "", "  ", "\n")
+		writer.WriteString("\n")
+	}
+
+	if snapshot.Window > 0 {
+		writer.WriteString("Latency Percentile (last " + snapshot.Window.String() + "):\n")
+	} else {
+		writer.WriteString("Latency Percentile:\n")
+	}
 	writeBulk(writer, percBulk)
 	writer.WriteString("\n")
 
+	if snapshot.TLSPercentiles != nil {
+		writer.WriteString("TLS Handshake Percentile:\n")
+		writeBulk(writer, buildPercentileBulk(snapshot.TLSPercentiles, useSeconds))
+		writer.WriteString("\n")
+	}
+
+	if snapshot.BurnIn != nil && snapshot.BurnIn.Percentiles != nil {
+		writer.WriteString("Burn-in Percentile:\n")
+		writeBulk(writer, buildPercentileBulk(snapshot.BurnIn.Percentiles, useSeconds))
+		writer.WriteString("\n")
+	}
+
+	if snapshot.PerKB != nil {
+		writer.WriteString("Latency Per KB Percentile:\n")
+		writeBulk(writer, buildPercentileBulk(snapshot.PerKB.Percentiles, useSeconds))
+		writer.WriteString("\n")
+	}
+
+	if snapshot.TTFB != nil && snapshot.TTLB != nil {
+		writer.WriteString("TTFB Percentile:\n")
+		writeBulk(writer, buildPercentileBulk(snapshot.TTFB.Percentiles, useSeconds))
+		writer.WriteString("\n")
+		writer.WriteString("TTLB Percentile:\n")
+		writeBulk(writer, buildPercentileBulk(snapshot.TTLB.Percentiles, useSeconds))
+		writer.WriteString("\n")
+	}
+
+	if snapshot.OverheadPercentiles != nil {
+		writer.WriteString("Overhead Percentile:\n")
+		writeBulk(writer, buildPercentileBulk(snapshot.OverheadPercentiles, useSeconds))
+		writer.WriteString("\n")
+	}
+
+	if snapshot.Cache != nil {
+		for _, sr := range snapshot.Cache.Statuses {
+			if sr.Histograms == nil {
+				continue
+			}
+			writer.WriteString("Cache " + strings.ToUpper(sr.Status[:1]) + sr.Status[1:] + " Latency Histogram:\n")
+			writeBulk(writer, p.buildHistogram(sr.Histograms, useSeconds, isFinal))
+			writer.WriteString("\n")
+		}
+	}
+
+	if snapshot.TTFB != nil && snapshot.TTLB != nil {
+		writer.WriteString("TTFB Histogram:\n")
+		writeBulk(writer, p.buildHistogram(snapshot.TTFB.Histograms, useSeconds, isFinal))
+		writer.WriteString("\n")
+		writer.WriteString("TTLB Histogram:\n")
+		writeBulk(writer, p.buildHistogram(snapshot.TTLB.Histograms, useSeconds, isFinal))
+		writer.WriteString("\n")
+	}
+
 	writer.WriteString("Latency Histogram:\n")
 	writeBulk(writer, hisBulk)
 }
 
-func (p *Printer) buildHistogram(snapshot *SnapshotReport, useSeconds bool, isFinal bool) [][]string {
+func groupTitle(dimension string) string {
+	switch dimension {
+	case "host":
+		return "Hosts"
+	case "ip":
+		return "IPs"
+	case "body":
+		return "Bodies"
+	case "worker":
+		return "Workers"
+	case "endpoint":
+		return "Endpoints"
+	}
+	return dimension
+}
+
+func (p *Printer) buildGroups(snapshot *SnapshotReport, dimension string, useSeconds bool) [][]string {
+	var bulk [][]string
+	hosts := dimension == "host"
+	aligns := []int{AlignLeft, AlignRight, AlignRight, AlignCenter, AlignCenter, AlignRight, AlignRight}
+	for _, g := range snapshot.Groups {
+		if g.Dimension != dimension {
+			continue
+		}
+		if bulk == nil {
+			header := []string{groupTitle(dimension), "Count", "Errors", "Mean", "Max"}
+			if hosts {
+				header = append(header, "Busy", "Conns")
+			}
+			// the endpoints are followed by their percentiles
+			if len(g.Percentiles) > 0 {
+				aligns = aligns[:len(header)]
+			}
+			for _, percentile := range g.Percentiles {
+				header = append(header, percentileName(percentile.Percentile))
+				aligns = append(aligns, AlignCenter)
+			}
+			bulk = append(bulk, header)
+		}
+		errs := strconv.FormatInt(g.Errors, 10)
+		if g.Errors > 0 {
+			errs = colorize(errs, FgMagentaColor)
+		}
+		row := []string{
+			"  " + g.Key,
+			strconv.FormatInt(g.Count, 10),
+			errs,
+			durationToString(g.Mean, useSeconds),
+			durationToString(g.Max, useSeconds),
+		}
+		if hosts {
+			row = append(row, strconv.FormatInt(g.PeakBusy, 10), strconv.FormatInt(g.PeakConns, 10))
+		}
+		for _, percentile := range g.Percentiles {
+			row = append(row, durationToString(percentile.Latency, useSeconds))
+		}
+		bulk = append(bulk, row)
+	}
+	if bulk != nil {
+		alignBulk(bulk, aligns...)
+	}
+	return bulk
+}
+
+func (p *Printer) buildConnections(connections []*ConnectionReport, useSeconds bool) [][]string {
+	bulk := [][]string{{"Worst Conns", "IP", "Count", "Errors", "Mean", "P99", "Max"}}
+	for _, c := range connections {
+		errs := strconv.FormatInt(c.Errors, 10)
+		if c.Errors > 0 {
+			errs = colorize(errs, FgMagentaColor)
+		}
+		ip := c.IP
+		if ip == "" {
+			ip = "-"
+		}
+		bulk = append(bulk, []string{
+			"  " + c.ID,
+			ip,
+			strconv.FormatInt(c.Count, 10),
+			errs,
+			durationToString(c.Mean, useSeconds),
+			durationToString(c.P99, useSeconds),
+			durationToString(c.Max, useSeconds),
+		})
+	}
+	alignBulk(bulk, AlignLeft, AlignLeft, AlignRight, AlignRight, AlignCenter, AlignCenter, AlignCenter)
+	return bulk
+}
+
+func (p *Printer) buildRedirects(snapshot *SnapshotReport) [][]string {
+	if len(snapshot.Redirects) == 0 {
+		return nil
+	}
+	bulk := [][]string{{"Redirects", "Target", "Count"}}
+	for _, rd := range snapshot.Redirects {
+		kind := rd.Kind
+		if kind == "scheme downgrade" || strings.HasPrefix(kind, "scheme downgrade,") ||
+			kind == "missing location" || kind == "invalid location" {
+			kind = colorize(kind, FgMagentaColor)
+		}
+		target := rd.Target
+		if target == "" {
+			target = "-"
+		}
+		bulk = append(bulk, []string{"  " + kind, target, strconv.FormatInt(rd.Count, 10)})
+	}
+	alignBulk(bulk, AlignLeft, AlignLeft, AlignRight)
+	return bulk
+}
+
+func (p *Printer) buildCache(cache *CacheReport, useSeconds bool) [][]string {
+	bulk := [][]string{{"Cache", "Count", "Mean", "Max"}}
+	for _, sr := range cache.Statuses {
+		bulk = append(bulk, []string{
+			"  " + sr.Status,
+			strconv.FormatInt(sr.Count, 10),
+			durationToString(sr.Mean, useSeconds),
+			durationToString(sr.Max, useSeconds),
+		})
+	}
+	bulk = append(bulk, []string{"  hit ratio", fmt.Sprintf("%.2f%%", cache.HitRatio*100), "", ""})
+	alignBulk(bulk, AlignLeft, AlignRight, AlignCenter, AlignCenter)
+	return bulk
+}
+
+func (p *Printer) buildFollowUps(fr *FollowUpReport) [][]string {
+	bulk := [][]string{{"Follow-up", "Count"}}
+	var codes [][]string
+	for code, count := range fr.Codes {
+		vs := strconv.FormatInt(count, 10)
+		if code != "2xx" {
+			vs = colorize(vs, FgMagentaColor)
+		}
+		codes = append(codes, []string{"  " + code, vs})
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i][0] < codes[j][0] })
+	bulk = append(bulk, codes...)
+	if fr.Missing > 0 {
+		bulk = append(bulk, []string{"  missing", colorize(strconv.FormatInt(fr.Missing, 10), FgMagentaColor)})
+	}
+	alignBulk(bulk, AlignLeft, AlignRight)
+	return bulk
+}
+
+func (p *Printer) buildContinue(cr *ContinueReport) [][]string {
+	bulk := [][]string{{"Expect 100-continue", "Count"}}
+	for _, outcome := range []string{expectContinued, expectRejected, expectTimedOut} {
+		count, ok := cr.Outcomes[outcome]
+		if !ok {
+			continue
+		}
+		vs := strconv.FormatInt(count, 10)
+		if outcome != expectContinued {
+			vs = colorize(vs, FgMagentaColor)
+		}
+		bulk = append(bulk, []string{"  " + outcome, vs})
+	}
+	alignBulk(bulk, AlignLeft, AlignRight)
+	return bulk
+}
+
+func (p *Printer) buildChecks(checks []*CheckReport) [][]string {
+	bulk := [][]string{{"Checks", "Passes", "Fails", "Pass Rate"}}
+	for _, c := range checks {
+		fails := strconv.FormatInt(c.Fails, 10)
+		if c.Fails > 0 {
+			fails = colorize(fails, FgMagentaColor)
+		}
+		rate := "-"
+		if total := c.Passes + c.Fails; total > 0 {
+			rate = fmt.Sprintf("%.2f%%", float64(c.Passes)/float64(total)*100)
+		}
+		bulk = append(bulk, []string{"  " + c.Name, strconv.FormatInt(c.Passes, 10), fails, rate})
+	}
+	alignBulk(bulk, AlignLeft, AlignRight, AlignRight, AlignRight)
+	return bulk
+}
+
+func (p *Printer) buildLatencySplit(ls *LatencySplit) [][]string {
+	bulk := [][]string{{"Latency Split", "Share", ""}}
+	for _, part := range []struct {
+		name  string
+		share float64
+	}{{"DNS", ls.DNS}, {"Connect", ls.Connect}, {"TLS", ls.TLS}, {"Request", ls.Request}} {
+		bulk = append(bulk, []string{
+			"  " + part.name,
+			fmt.Sprintf("%.2f%%", part.share*100),
+			strings.Repeat(barBody, int(part.share*float64(maxBarLen)+0.5)),
+		})
+	}
+	alignBulk(bulk, AlignLeft, AlignRight, AlignLeft)
+	return bulk
+}
+
+func (p *Printer) buildCacheControls(cache *CacheReport) [][]string {
+	bulk := [][]string{{"Cache-Control", "Count"}}
+	for _, cc := range cache.CacheControls {
+		bulk = append(bulk, []string{"  " + cc.Value, strconv.FormatInt(cc.Count, 10)})
+	}
+	alignBulk(bulk, AlignLeft, AlignRight)
+	return bulk
+}
+
+// hstsResults are the outcomes of the HSTS check, in the printed order.
+var hstsResults = []string{"ok", "short", "invalid", "missing"}
+
+func (p *Printer) buildHSTS(snapshot *SnapshotReport) [][]string {
+	if len(snapshot.HSTS) == 0 {
+		return nil
+	}
+	bulk := [][]string{{"HSTS", "Count"}}
+	for _, result := range hstsResults {
+		count, ok := snapshot.HSTS[result]
+		if !ok {
+			continue
+		}
+		label := result
+		if result != "ok" {
+			label = colorize(label, FgMagentaColor)
+		}
+		bulk = append(bulk, []string{"  " + label, strconv.FormatInt(count, 10)})
+	}
+	alignBulk(bulk, AlignLeft, AlignRight)
+	return bulk
+}
+
+func (p *Printer) PrintCooldown(report *CooldownReport, useSeconds bool) {
+	if len(report.Slices) == 0 {
+		return
+	}
+	var buf bytes.Buffer
+	bulk := [][]string{{"Since", "Count", "Min", "Mean", "Max", "Errors"}}
+	for _, cs := range report.Slices {
+		bulk = append(bulk, []string{
+			"+" + cs.Start.String(),
+			strconv.FormatInt(cs.Latency.count, 10),
+			durationToString(time.Duration(cs.Latency.min), useSeconds),
+			durationToString(time.Duration(cs.Latency.Mean()), useSeconds),
+			durationToString(time.Duration(cs.Latency.max), useSeconds),
+			strconv.FormatInt(cs.Errors, 10),
+		})
+	}
+	alignBulk(bulk, AlignLeft, AlignRight, AlignCenter, AlignCenter, AlignCenter, AlignRight)
+	buf.WriteString("\nCooldown Latency:\n")
+	writeBulk(&buf, bulk)
+	os.Stdout.Write(buf.Bytes())
+}
+
+func (p *Printer) buildHistogram(histograms []*struct {
+	Mean  time.Duration
+	Count int
+}, useSeconds bool, isFinal bool) [][]string {
 	hisBulk := make([][]string, 0, 8)
 	maxCount := 0
 	hisSum := 0
-	for _, bin := range snapshot.Histograms {
+	for _, bin := range histograms {
 		if maxCount < bin.Count {
 			maxCount = bin.Count
 		}
 		hisSum += bin.Count
 	}
-	for _, bin := range snapshot.Histograms {
+	for _, bin := range histograms {
 		row := []string{durationToString(bin.Mean, useSeconds), strconv.Itoa(bin.Count)}
 		if isFinal {
 			row = append(row, fmt.Sprintf("%.2f%%", math.Floor(float64(bin.Count)*1e4/float64(hisSum)+0.5)/100.0))
@@ -246,12 +648,19 @@ func (p *Printer) buildHistogram(snapshot *SnapshotReport, useSeconds bool, isFi
 }
 
 func (p *Printer) buildPercentile(snapshot *SnapshotReport, useSeconds bool) [][]string {
+	return buildPercentileBulk(snapshot.Percentiles, useSeconds)
+}
+
+func buildPercentileBulk(percentiles []*struct {
+	Percentile float64
+	Latency    time.Duration
+}, useSeconds bool) [][]string {
 	percBulk := make([][]string, 2)
-	percAligns := make([]int, 0, len(snapshot.Percentiles))
-	for _, percentile := range snapshot.Percentiles {
-		perc := formatFloat64(percentile.Percentile * 100)
-		percBulk[0] = append(percBulk[0], "P"+perc)
-		percBulk[1] = append(percBulk[1], durationToString(percentile.Latency, useSeconds))
+	percAligns := make([]int, 0, len(percentiles))
+	for _, percentile := range percentiles {
+		percBulk[0] = append(percBulk[0], percentileName(percentile.Percentile))
+		percBulk[1] = append(percBulk[1], colorByRules(durationToString(percentile.Latency, useSeconds),
+			"", percentile.Percentile, float64(percentile.Latency)))
 		percAligns = append(percAligns, AlignCenter)
 	}
 	percAligns[0] = AlignLeft
@@ -259,26 +668,170 @@ func (p *Printer) buildPercentile(snapshot *SnapshotReport, useSeconds bool) [][
 	return percBulk
 }
 
+func connPhaseTitle(phase string) string {
+	switch phase {
+	case "dns":
+		return "DNS"
+	case "tls":
+		return "TLS"
+	case "proxy":
+		return "Proxy"
+	}
+	return "Connect"
+}
+
 func (p *Printer) buildStats(snapshot *SnapshotReport, useSeconds bool) [][]string {
+	latency := "  Latency"
+	if snapshot.Window > 0 {
+		latency += " (last " + snapshot.Window.String() + ")"
+	}
 	var statsBulk [][]string
 	statsBulk = append(statsBulk,
 		[]string{"Statistics", "Min", "Mean", "StdDev", "Max"},
 		[]string{
-			"  Latency",
+			latency,
 			durationToString(snapshot.Stats.Min, useSeconds),
-			durationToString(snapshot.Stats.Mean, useSeconds),
+			colorByRules(durationToString(snapshot.Stats.Mean, useSeconds), "mean", 0, float64(snapshot.Stats.Mean)),
 			durationToString(snapshot.Stats.StdDev, useSeconds),
-			durationToString(snapshot.Stats.Max, useSeconds),
+			colorByRules(durationToString(snapshot.Stats.Max, useSeconds), "max", 0, float64(snapshot.Stats.Max)),
 		},
 	)
+	if snapshot.TTFB != nil && snapshot.TTLB != nil {
+		statsBulk = append(statsBulk,
+			[]string{
+				"  TTFB",
+				durationToString(snapshot.TTFB.Stats.Min, useSeconds),
+				durationToString(snapshot.TTFB.Stats.Mean, useSeconds),
+				durationToString(snapshot.TTFB.Stats.StdDev, useSeconds),
+				durationToString(snapshot.TTFB.Stats.Max, useSeconds),
+			},
+			[]string{
+				"  TTLB",
+				durationToString(snapshot.TTLB.Stats.Min, useSeconds),
+				durationToString(snapshot.TTLB.Stats.Mean, useSeconds),
+				durationToString(snapshot.TTLB.Stats.StdDev, useSeconds),
+				durationToString(snapshot.TTLB.Stats.Max, useSeconds),
+			},
+		)
+	}
 	if snapshot.RpsStats != nil {
 		statsBulk = append(statsBulk,
 			[]string{
 				"  RPS",
-				formatFloat64(math.Trunc(snapshot.RpsStats.Min*100) / 100.0),
-				formatFloat64(math.Trunc(snapshot.RpsStats.Mean*100) / 100.0),
-				formatFloat64(math.Trunc(snapshot.RpsStats.StdDev*100) / 100.0),
-				formatFloat64(math.Trunc(snapshot.RpsStats.Max*100) / 100.0),
+				rateToString(snapshot.RpsStats.Min),
+				rateToString(snapshot.RpsStats.Mean),
+				rateToString(snapshot.RpsStats.StdDev),
+				rateToString(snapshot.RpsStats.Max),
+			},
+		)
+	}
+	if snapshot.QueueStats != nil {
+		statsBulk = append(statsBulk, []string{
+			"  Queue",
+			durationToString(snapshot.QueueStats.Min, useSeconds),
+			durationToString(snapshot.QueueStats.Mean, useSeconds),
+			durationToString(snapshot.QueueStats.StdDev, useSeconds),
+			durationToString(snapshot.QueueStats.Max, useSeconds),
+		})
+	}
+	if snapshot.Cache != nil && snapshot.Cache.Age != nil {
+		statsBulk = append(statsBulk, []string{
+			"  Age",
+			durationToString(snapshot.Cache.Age.Min, useSeconds),
+			durationToString(snapshot.Cache.Age.Mean, useSeconds),
+			durationToString(snapshot.Cache.Age.StdDev, useSeconds),
+			durationToString(snapshot.Cache.Age.Max, useSeconds),
+		})
+	}
+	if fr := snapshot.FollowUps; fr != nil && fr.FollowUp != nil {
+		statsBulk = append(statsBulk,
+			[]string{
+				"  First leg",
+				durationToString(fr.FirstLeg.Min, useSeconds),
+				durationToString(fr.FirstLeg.Mean, useSeconds),
+				durationToString(fr.FirstLeg.StdDev, useSeconds),
+				durationToString(fr.FirstLeg.Max, useSeconds),
+			},
+			[]string{
+				"  Follow-up",
+				durationToString(fr.FollowUp.Min, useSeconds),
+				durationToString(fr.FollowUp.Mean, useSeconds),
+				durationToString(fr.FollowUp.StdDev, useSeconds),
+				durationToString(fr.FollowUp.Max, useSeconds),
+			},
+		)
+	}
+	if cr := snapshot.Continue; cr != nil && cr.Wait != nil {
+		statsBulk = append(statsBulk, []string{
+			"  100 Continue",
+			durationToString(cr.Wait.Min, useSeconds),
+			durationToString(cr.Wait.Mean, useSeconds),
+			durationToString(cr.Wait.StdDev, useSeconds),
+			durationToString(cr.Wait.Max, useSeconds),
+		})
+	}
+	if er := snapshot.Events; er != nil && er.Streams > 0 {
+		statsBulk = append(statsBulk, []string{
+			"  First Event",
+			durationToString(er.FirstEvent.Min, useSeconds),
+			durationToString(er.FirstEvent.Mean, useSeconds),
+			durationToString(er.FirstEvent.StdDev, useSeconds),
+			durationToString(er.FirstEvent.Max, useSeconds),
+		})
+	}
+	if br := snapshot.BurnIn; br != nil && br.Count > 0 {
+		statsBulk = append(statsBulk, []string{
+			"  Burn-in",
+			durationToString(br.Stats.Min, useSeconds),
+			durationToString(br.Stats.Mean, useSeconds),
+			durationToString(br.Stats.StdDev, useSeconds),
+			durationToString(br.Stats.Max, useSeconds),
+		})
+	}
+	if pr := snapshot.PerKB; pr != nil {
+		statsBulk = append(statsBulk,
+			[]string{
+				"  Body Size",
+				sizeToString(pr.Size.Min),
+				sizeToString(pr.Size.Mean),
+				sizeToString(pr.Size.StdDev),
+				sizeToString(pr.Size.Max),
+			},
+			[]string{
+				"  Per KB",
+				durationToString(pr.Latency.Min, useSeconds),
+				durationToString(pr.Latency.Mean, useSeconds),
+				durationToString(pr.Latency.StdDev, useSeconds),
+				durationToString(pr.Latency.Max, useSeconds),
+			},
+		)
+	}
+	if snapshot.ServerStats != nil {
+		statsBulk = append(statsBulk,
+			[]string{
+				"  Server",
+				durationToString(snapshot.ServerStats.Min, useSeconds),
+				durationToString(snapshot.ServerStats.Mean, useSeconds),
+				durationToString(snapshot.ServerStats.StdDev, useSeconds),
+				durationToString(snapshot.ServerStats.Max, useSeconds),
+			},
+			[]string{
+				"  Overhead",
+				durationToString(snapshot.OverheadStats.Min, useSeconds),
+				durationToString(snapshot.OverheadStats.Mean, useSeconds),
+				durationToString(snapshot.OverheadStats.StdDev, useSeconds),
+				durationToString(snapshot.OverheadStats.Max, useSeconds),
+			},
+		)
+	}
+	for _, cs := range snapshot.ConnStats {
+		statsBulk = append(statsBulk,
+			[]string{
+				"  " + connPhaseTitle(cs.Phase),
+				durationToString(cs.Min, useSeconds),
+				durationToString(cs.Mean, useSeconds),
+				durationToString(cs.StdDev, useSeconds),
+				durationToString(cs.Max, useSeconds),
 			},
 		)
 	}
@@ -299,6 +852,19 @@ func (p *Printer) buildErrors(snapshot *SnapshotReport) [][]string {
 	return errorsBulks
 }
 
+func (p *Printer) buildViolations(snapshot *SnapshotReport) [][]string {
+	var bulk [][]string
+	for k, v := range snapshot.Violations {
+		bulk = append(bulk, []string{colorize(strconv.FormatInt(v, 10), FgMagentaColor), k})
+	}
+	if bulk == nil {
+		return nil
+	}
+	sort.Slice(bulk, func(i, j int) bool { return bulk[i][1] < bulk[j][1] })
+	alignBulk(bulk, AlignLeft, AlignLeft)
+	return bulk
+}
+
 func (p *Printer) buildSummary(snapshot *SnapshotReport, isFinal bool) [][]string {
 	summarybulk := make([][]string, 0, 8)
 	elapsedLine := []string{"Elapsed", snapshot.Elapsed.Truncate(time.Millisecond).String()}
@@ -325,11 +891,63 @@ func (p *Printer) buildSummary(snapshot *SnapshotReport, isFinal bool) [][]strin
 	}
 	sort.Slice(codesBulks, func(i, j int) bool { return codesBulks[i][0] < codesBulks[j][0] })
 	summarybulk = append(summarybulk, codesBulks...)
+	if hasColorRule("errors") {
+		// the rate the rules bound, shown along with them only
+		rate := (&sloCondition{name: "errors"}).value(snapshot)
+		var errs int64
+		for _, n := range snapshot.Errors {
+			errs += n
+		}
+		summarybulk = append(summarybulk, []string{"Errors",
+			colorByRules(fmt.Sprintf("%d (%.2f%%)", errs, rate), "errors", 0, rate)})
+	}
+	if er := snapshot.Events; er != nil {
+		summarybulk = append(summarybulk,
+			[]string{"Streams", strconv.FormatInt(er.Streams, 10)},
+			[]string{"Per Conn", fmt.Sprintf("%.3f/s", er.PerConn)},
+		)
+	}
 	summarybulk = append(summarybulk,
 		[]string{"RPS", fmt.Sprintf("%.3f", snapshot.RPS)},
 		[]string{"Reads", fmt.Sprintf("%.3fMB/s", snapshot.ReadThroughput)},
+		[]string{"  total", sizeToString(float64(snapshot.ReadBytes))},
+	)
+	if snapshot.DecodedReadThroughput > 0 {
+		summarybulk = append(summarybulk, []string{"  decoded", fmt.Sprintf("%.3fMB/s", snapshot.DecodedReadThroughput)})
+	}
+	summarybulk = append(summarybulk,
 		[]string{"Writes", fmt.Sprintf("%.3fMB/s", snapshot.WriteThroughput)},
+		[]string{"  total", sizeToString(float64(snapshot.WriteBytes))},
 	)
+	if snapshot.DecodedWriteThroughput > 0 {
+		summarybulk = append(summarybulk, []string{"  decoded", fmt.Sprintf("%.3fMB/s", snapshot.DecodedWriteThroughput)})
+	}
+	if snapshot.AffinityChecks > 0 {
+		vs := fmt.Sprintf("%d/%d broken", snapshot.AffinityViolations, snapshot.AffinityChecks)
+		if snapshot.AffinityViolations > 0 {
+			vs = colorize(vs, FgMagentaColor)
+		}
+		summarybulk = append(summarybulk, []string{"Affinity", vs})
+	}
+	if snapshot.TLSHandshakes != nil {
+		summarybulk = append(summarybulk, []string{"Handshakes",
+			fmt.Sprintf("%d full, %d resumed", snapshot.TLSHandshakes.Full, snapshot.TLSHandshakes.Resumed)})
+	}
+	if res := snapshot.Resources; res != nil {
+		summarybulk = append(summarybulk, []string{"Peak Conns", strconv.FormatInt(res.PeakConns, 10)})
+		summarybulk = append(summarybulk,
+			[]string{"Opened", strconv.FormatInt(res.Opened, 10)},
+			[]string{"Reused", strconv.FormatInt(res.Reused, 10)},
+			[]string{"Closed by peer", strconv.FormatInt(res.ClosedByPeer, 10)},
+			[]string{"Idle evicted", strconv.FormatInt(res.IdleEvicted, 10)},
+		)
+		if res.PeakFDs > 0 {
+			summarybulk = append(summarybulk, []string{"Peak FDs", strconv.FormatInt(res.PeakFDs, 10)})
+		}
+		if isFinal && res.TimeWait >= 0 {
+			summarybulk = append(summarybulk, []string{"TIME_WAIT", strconv.FormatInt(res.TimeWait, 10)})
+		}
+	}
 	alignBulk(summarybulk, AlignLeft, AlignRight)
 	return summarybulk
 }
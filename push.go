@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// pushTimeout bounds a push, not to hold up the end of the run.
+const pushTimeout = 10 * time.Second
+
+// Pusher pushes the metrics of the run to a Prometheus Pushgateway every
+// interval and once done, as runs shorter than a scrape would go unseen.
+type Pusher struct {
+	url    string
+	client *fasthttp.Client
+	err    error
+	done   chan struct{}
+}
+
+// NewPusher pushes to the group of job on the Pushgateway at addr, like
+// http://pushgateway:9091.
+func NewPusher(addr, job string) (*Pusher, error) {
+	u, err := url.Parse(addr)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid push-url %q, expected like http://pushgateway:9091", addr)
+	}
+	if job == "" {
+		return nil, fmt.Errorf("push-job can not be empty")
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/metrics/job/" + url.PathEscape(job)
+	return &Pusher{url: u.String(), client: &fasthttp.Client{Name: "plow"}, done: make(chan struct{})}, nil
+}
+
+// push replaces the metrics of the group with those of the snapshot.
+func (p *Pusher) push(rs *SnapshotReport) error {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(resp)
+	}()
+	req.SetRequestURI(p.url)
+	req.Header.SetMethod(fasthttp.MethodPut)
+	req.Header.SetContentType("text/plain; version=0.0.4")
+	var buf bytes.Buffer
+	writeMetrics(&buf, rs)
+	req.SetBody(buf.Bytes())
+	if err := p.client.DoTimeout(req, resp, pushTimeout); err != nil {
+		return fmt.Errorf("push: %v", err)
+	}
+	if resp.StatusCode()/100 != 2 {
+		return fmt.Errorf("push: status %d: %s", resp.StatusCode(), bytes.TrimSpace(resp.Body()))
+	}
+	return nil
+}
+
+// Loop pushes a snapshot every interval until doneChan is closed, then the
+// final report. The failed pushes before the final one are ignored.
+func (p *Pusher) Loop(snapshot func() *SnapshotReport, interval time.Duration, doneChan <-chan struct{}) {
+	defer close(p.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+loop:
+	for {
+		select {
+		case <-ticker.C:
+			_ = p.push(snapshot())
+		case <-doneChan:
+			break loop
+		}
+	}
+	p.err = p.push(snapshot())
+}
+
+// Close waits for the final report to be pushed.
+func (p *Pusher) Close() error {
+	<-p.done
+	return p.err
+}
+
+// writeMetrics writes the snapshot in the text format of Prometheus.
+func writeMetrics(buf *bytes.Buffer, rs *SnapshotReport) {
+	metric := func(name, typ, help string) {
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	value := func(name, labels string, v float64) {
+		if labels != "" {
+			labels = "{" + labels + "}"
+		}
+		fmt.Fprintf(buf, "%s%s %s\n", name, labels, strconv.FormatFloat(v, 'g', -1, 64))
+	}
+
+	metric("plow_elapsed_seconds", "gauge", "Time since the start of the run.")
+	value("plow_elapsed_seconds", "", rs.Elapsed.Seconds())
+	metric("plow_requests_total", "counter", "Requests sent.")
+	value("plow_requests_total", "", float64(rs.Count))
+	metric("plow_requests_per_second", "gauge", "Requests per second over the run.")
+	value("plow_requests_per_second", "", rs.RPS)
+
+	metric("plow_responses_total", "counter", "Responses by status class.")
+	codes := make([]string, 0, len(rs.Codes))
+	for code := range rs.Codes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		value("plow_responses_total", labelPair("code", code), float64(rs.Codes[code]))
+	}
+	var errs int64
+	for _, n := range rs.Errors {
+		errs += n
+	}
+	metric("plow_errors_total", "counter", "Requests failed without a response.")
+	value("plow_errors_total", "", float64(errs))
+
+	metric("plow_latency_seconds", "gauge", "Latency percentiles, over '--window' if given.")
+	for _, p := range rs.Percentiles {
+		value("plow_latency_seconds", labelPair("quantile", strconv.FormatFloat(p.Percentile, 'f', -1, 64)), p.Latency.Seconds())
+	}
+	metric("plow_latency_mean_seconds", "gauge", "Mean latency, over '--window' if given.")
+	value("plow_latency_mean_seconds", "", rs.Stats.Mean.Seconds())
+	metric("plow_latency_max_seconds", "gauge", "Max latency, over '--window' if given.")
+	value("plow_latency_max_seconds", "", rs.Stats.Max.Seconds())
+
+	metric("plow_read_bytes_total", "counter", "Response bytes read.")
+	value("plow_read_bytes_total", "", float64(rs.ReadBytes))
+	metric("plow_written_bytes_total", "counter", "Request bytes written.")
+	value("plow_written_bytes_total", "", float64(rs.WriteBytes))
+
+	if len(rs.Checks) > 0 {
+		metric("plow_check_passes_total", "counter", "Responses which passed a check.")
+		for _, c := range rs.Checks {
+			value("plow_check_passes_total", labelPair("check", c.Name), float64(c.Passes))
+		}
+		metric("plow_check_failures_total", "counter", "Responses which failed a check.")
+		for _, c := range rs.Checks {
+			value("plow_check_failures_total", labelPair("check", c.Name), float64(c.Fails))
+		}
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labelPair(name, value string) string {
+	return name + `="` + labelEscaper.Replace(value) + `"`
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// readyPollInterval is how often '--wait-ready' asks the target.
+const readyPollInterval = 500 * time.Millisecond
+
+// WaitReady polls path, resolved against the url of the first target, until
+// it answers 2xx, for the service started along with plow to come up. It gives
+// up after timeout with the last answer.
+func (r *Requester) WaitReady(path string, timeout time.Duration) error {
+	u, err := r.targets[0].base.Parse(path)
+	if err != nil {
+		return fmt.Errorf("wait-ready: %v", err)
+	}
+	tlsConfig, err := buildTLSConfig(r.clientOpt)
+	if err != nil {
+		return err
+	}
+	dial := fasthttp.DialFunc(fasthttp.Dial)
+	if r.clientOpt.unixSocket != "" {
+		dial = UnixDial(r.clientOpt.unixSocket, r.clientOpt.phaseTimeouts.connect)
+	}
+	client := &fasthttp.Client{Name: "plow", TLSConfig: tlsConfig, Dial: dial}
+	defer client.CloseIdleConnections()
+
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(resp)
+	}()
+	req.SetRequestURI(u.String())
+
+	deadline := time.Now().Add(timeout)
+	for {
+		var last string
+		err = client.DoDeadline(req, resp, deadline)
+		switch {
+		case err != nil:
+			last = err.Error()
+		case resp.StatusCode() >= 200 && resp.StatusCode() < 300:
+			return nil
+		default:
+			last = fmt.Sprintf("status %d", resp.StatusCode())
+		}
+		if time.Now().Add(readyPollInterval).After(deadline) {
+			return fmt.Errorf("%s not ready after %s: %s", u, timeout, last)
+		}
+		time.Sleep(readyPollInterval)
+	}
+}
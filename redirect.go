@@ -0,0 +1,92 @@
+package main
+
+import (
+	url2 "net/url"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// hstsMinMaxAge is the max-age required by the HSTS preload list, a year.
+const hstsMinMaxAge = 31536000
+
+// redirectKey is what a redirect is counted by in the report.
+type redirectKey struct {
+	kind   string
+	target string
+}
+
+func isRedirect(code int) bool {
+	switch code {
+	case fasthttp.StatusMovedPermanently, fasthttp.StatusFound, fasthttp.StatusSeeOther,
+		fasthttp.StatusTemporaryRedirect, fasthttp.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// classifyRedirect returns the kind of the redirect answered to a request of
+// base, and its target without the query, or empty strings if the response
+// isn't a redirect.
+func classifyRedirect(base *url2.URL, resp *fasthttp.Response) (string, string) {
+	if !isRedirect(resp.StatusCode()) {
+		return "", ""
+	}
+	location := string(resp.Header.Peek(fasthttp.HeaderLocation))
+	if location == "" {
+		return "missing location", ""
+	}
+	to, err := base.Parse(location)
+	if err != nil {
+		return "invalid location", ""
+	}
+	target := to.Scheme + "://" + to.Host + to.EscapedPath()
+	var kind string
+	switch {
+	case to.Scheme != "http" && to.Scheme != "https":
+		return "other scheme", target
+	case base.Scheme == "http" && to.Scheme == "https":
+		kind = "scheme upgrade"
+	case base.Scheme == "https" && to.Scheme == "http":
+		kind = "scheme downgrade"
+	}
+	if !strings.EqualFold(base.Hostname(), to.Hostname()) {
+		if kind != "" {
+			kind += ", "
+		}
+		kind += "host change"
+	}
+	if kind == "" {
+		kind = "same host"
+	}
+	return kind, target
+}
+
+// checkHSTS returns how the Strict-Transport-Security header of the response
+// complies: "ok", "missing", "invalid" or "short" if max-age is below a year.
+func checkHSTS(resp *fasthttp.Response) string {
+	header := string(resp.Header.Peek("Strict-Transport-Security"))
+	if header == "" {
+		return "missing"
+	}
+	maxAge := -1
+	for _, directive := range strings.Split(header, ";") {
+		kv := strings.SplitN(strings.TrimSpace(directive), "=", 2)
+		if len(kv) != 2 || !strings.EqualFold(strings.TrimSpace(kv[0]), "max-age") {
+			continue
+		}
+		v, err := strconv.Atoi(strings.Trim(strings.TrimSpace(kv[1]), `"`))
+		if err != nil || v < 0 {
+			return "invalid"
+		}
+		maxAge = v
+	}
+	switch {
+	case maxAge < 0:
+		return "invalid"
+	case maxAge < hstsMinMaxAge:
+		return "short"
+	}
+	return "ok"
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxPaths bounds the paths of 'replay' and '--group-by path' reported apart,
+// the least requested or the last ones being reported together as otherPaths.
+const maxPaths = 100
+
+const otherPaths = "other paths"
+
+// accessLogFormats match the lines of the access logs 'replay' parses, the
+// time and the request line being the first groups.
+var accessLogFormats = map[string]*regexp.Regexp{
+	"common":   regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "([^"]*)" \S+ \S+`),
+	"combined": regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "([^"]*)" \S+ \S+ "[^"]*" "[^"]*"`),
+}
+
+const accessLogTime = "02/Jan/2006:15:04:05 -0700"
+
+// accessLogEntry is a request of an access log to replay.
+type accessLogEntry struct {
+	// at is the time of the request from the first one of the log
+	at     time.Duration
+	method string
+	path   string
+	// group is the path without the query the request is reported under
+	group string
+}
+
+// ParseAccessLog parses the requests of the access log at path in the common
+// or combined format, in the order of their times. The lines which are not
+// requests to a path, like those of bad requests, are skipped and counted.
+func ParseAccessLog(path, format string) ([]*accessLogEntry, int, error) {
+	re, ok := accessLogFormats[format]
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid access log format %q, expected common or combined", format)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var (
+		entries []*accessLogEntry
+		times   []time.Time
+		skipped int
+	)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		m := re.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			skipped++
+			continue
+		}
+		t, err := time.Parse(accessLogTime, m[1])
+		request := strings.Fields(m[2])
+		if err != nil || len(request) < 2 || !strings.HasPrefix(request[1], "/") {
+			skipped++
+			continue
+		}
+		group := request[1]
+		if i := strings.IndexByte(group, '?'); i >= 0 {
+			group = group[:i]
+		}
+		entries = append(entries, &accessLogEntry{method: request[0], path: request[1], group: group})
+		times = append(times, t)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(entries) == 0 {
+		return nil, skipped, fmt.Errorf("no request in the access log %s", path)
+	}
+	first := times[0]
+	for _, t := range times {
+		if t.Before(first) {
+			first = t
+		}
+	}
+	for i, e := range entries {
+		e.at = times[i].Sub(first)
+	}
+	// the lines are written once the requests are done, slightly out of order
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].at < entries[j].at })
+	groupPaths(entries)
+	return entries, skipped, nil
+}
+
+// groupPaths reports the least requested paths together, beyond the
+// maxPaths most requested ones.
+func groupPaths(entries []*accessLogEntry) {
+	counts := make(map[string]int)
+	for _, e := range entries {
+		counts[e.group]++
+	}
+	if len(counts) <= maxPaths {
+		return
+	}
+	paths := make([]string, 0, len(counts))
+	for p := range counts {
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if counts[paths[i]] != counts[paths[j]] {
+			return counts[paths[i]] > counts[paths[j]]
+		}
+		return paths[i] < paths[j]
+	})
+	kept := make(map[string]bool, maxPaths)
+	for _, p := range paths[:maxPaths] {
+		kept[p] = true
+	}
+	for _, e := range entries {
+		if !kept[e.group] {
+			e.group = otherPaths
+		}
+	}
+}
+
+// ParseSpeed parses the speed of 'replay', like 2x or 0.5x.
+func ParseSpeed(s string) (float64, error) {
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(s), "x"), 64)
+	if err != nil || speed <= 0 {
+		return 0, fmt.Errorf("invalid speed %q, expected a factor like 2x or 0.5x", s)
+	}
+	return speed, nil
+}
+
+// replayer hands the requests of an access log to the workers in order, each
+// once it is due at the pace of the log scaled by speed.
+type replayer struct {
+	entries []*accessLogEntry
+	speed   float64
+	// skipped are the lines of the log which are not requests
+	skipped int
+
+	lock  sync.Mutex
+	next  int
+	start time.Time
+}
+
+func newReplayer(entries []*accessLogEntry, speed float64) *replayer {
+	return &replayer{entries: entries, speed: speed}
+}
+
+// Next waits for the next request to be due and returns it with the time it
+// was due, false once the log is over or ctx done.
+func (p *replayer) Next(ctx context.Context) (*accessLogEntry, time.Time, bool) {
+	p.lock.Lock()
+	if p.next >= len(p.entries) {
+		p.lock.Unlock()
+		return nil, time.Time{}, false
+	}
+	if p.start.IsZero() {
+		p.start = time.Now()
+	}
+	e := p.entries[p.next]
+	p.next++
+	due := p.start.Add(time.Duration(float64(e.at) / p.speed))
+	p.lock.Unlock()
+
+	if wait := time.Until(due); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, time.Time{}, false
+		}
+	}
+	return e, due, true
+}
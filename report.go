@@ -1,15 +1,29 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/beorn7/perks/histogram"
 	"github.com/beorn7/perks/quantile"
+	"io/ioutil"
 	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+// defaultPercentiles are the percentiles of '--percentiles' by default.
+const defaultPercentiles = "50,75,90,95,99,99.9,99.99"
+
 var quantiles = []float64{0.50, 0.75, 0.90, 0.95, 0.99, 0.999, 0.9999}
 
+// connPhases are the phases of opening a connection reported apart from the
+// latency, in the order they happen.
+var connPhases = []string{"dns", "connect", "proxy", "tls"}
+
 var quantilesTarget = map[float64]float64{
 	0.50:   0.01,
 	0.75:   0.01,
@@ -20,6 +34,53 @@ var quantilesTarget = map[float64]float64{
 	0.9999: 0.00001,
 }
 
+// percentileQuantile is the quantile of percentile p, rounded so that 99.9
+// gives 0.999.
+func percentileQuantile(p float64) float64 {
+	return math.Round(p/100*1e9) / 1e9
+}
+
+// ParsePercentiles parses the increasing percentiles of '--percentiles', like
+// 50,90,99,99.9, as quantiles.
+func ParsePercentiles(s string) ([]float64, error) {
+	var qs []float64
+	for _, f := range strings.Split(s, ",") {
+		p, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil || p <= 0 || p >= 100 {
+			return nil, fmt.Errorf("invalid percentile %q, expected a number between 0 and 100", f)
+		}
+		q := percentileQuantile(p)
+		if len(qs) > 0 && q <= qs[len(qs)-1] {
+			return nil, fmt.Errorf("invalid percentiles %q, expected increasing ones", s)
+		}
+		qs = append(qs, q)
+	}
+	return qs, nil
+}
+
+// setPercentiles makes the reports and the charts track the quantiles qs
+// instead of the default ones, each within a tenth of what's above it.
+func setPercentiles(qs []float64) {
+	quantiles = qs
+	quantilesTarget = make(map[float64]float64, len(qs))
+	for _, q := range qs {
+		quantilesTarget[q] = math.Min(0.01, (1-q)/10)
+	}
+	chartPercentiles = qs
+}
+
+// percentileNames lists the percentiles tracked, like p50, p99 and p99.9.
+func percentileNames() string {
+	names := make([]string, len(quantiles))
+	for i, q := range quantiles {
+		names[i] = strings.ToLower(percentileName(q))
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
 type Stats struct {
 	count int64
 	sum   float64
@@ -56,6 +117,22 @@ func (s *Stats) Mean() float64 {
 	return s.sum / float64(s.count)
 }
 
+// merge adds the values of o.
+func (s *Stats) merge(o Stats) {
+	if o.count == 0 {
+		return
+	}
+	if o.min < s.min || s.count == 0 {
+		s.min = o.min
+	}
+	if o.max > s.max || s.count == 0 {
+		s.max = o.max
+	}
+	s.count += o.count
+	s.sum += o.sum
+	s.sumSq += o.sumSq
+}
+
 func (s *Stats) Reset() {
 	s.count = 0
 	s.sum = 0
@@ -64,39 +141,287 @@ func (s *Stats) Reset() {
 	s.max = 0
 }
 
+type statsJSON struct {
+	Count int64
+	Sum   float64
+	SumSq float64
+	Min   float64
+	Max   float64
+}
+
+func (s Stats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(statsJSON{s.count, s.sum, s.sumSq, s.min, s.max})
+}
+
+func (s *Stats) UnmarshalJSON(data []byte) error {
+	var v statsJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*s = Stats{count: v.Count, sum: v.Sum, sumSq: v.SumSq, min: v.Min, max: v.Max}
+	return nil
+}
+
 type StreamReport struct {
 	lock sync.Mutex
 
 	latencyStats     *Stats
 	rpsStats         *Stats
 	latencyQuantile  *quantile.Stream
+	serverStats      *Stats
+	overheadStats    *Stats
+	overheadQuantile *quantile.Stream
+	queueStats       *Stats
 	latencyHistogram *histogram.Histogram
 	codes            map[string]int64
 	errors           map[string]int64
+	connStats        map[string]*Stats
+	groups           map[string]map[string]*groupStats
+
+	affinityChecks     int64
+	affinityViolations int64
+
+	tlsFull     int64
+	tlsResumed  int64
+	tlsQuantile *quantile.Stream
+
+	redirects  map[redirectKey]int64
+	hsts       map[string]int64
+	violations map[string]int64
+
+	cacheCounts     map[string]int64
+	cacheLatency    map[string]*Stats
+	cacheHistograms map[string]*histogram.Histogram
+	cacheControls   map[string]int64
+	ageStats        *Stats
 
-	latencyWithinSec *Stats
-	rpsWithinSec     float64
-	noDateWithinSec  bool
+	firstLegStats *Stats
+	followStats   *Stats
+	followCodes   map[string]int64
+	followMissing int64
+
+	expects       map[string]int64
+	continueStats *Stats
+
+	sizeStats     *Stats
+	perKBStats    *Stats
+	perKBQuantile *quantile.Stream
+
+	// chartsWithinSec is the last second of the charts, nil before it
+	chartsWithinSec *ChartsReport
+	noDateWithinSec bool
+	chartsHistory   []*ChartsReport
+	targetFunc      func(at time.Time) *LoadTarget
+	resourcesFunc   func() *ResourceReport
+	// maxErrors and maxHistory bound the distinct errors and the seconds of
+	// chartsHistory kept, once asked by BoundMemory
+	maxErrors  int
+	maxHistory int
+	// split is only kept once asked by SplitLatency
+	split *latencySplit
+	// checkNames are the checks of SetChecks, counted by checkPasses and
+	// checkFails
+	checkNames  []string
+	checkPasses []int64
+	checkFails  []int64
+	// connections are only kept once asked by ReportConnections, keyed by
+	// the worker and the number of the connection
+	connections      map[connKey]*connectionStats
+	worstConnections int
+	// firstEventStats is only kept once asked by Events
+	firstEventStats *Stats
+	concurrency     int
+	// burnInStats and burnInQuantile are only kept once asked by BurnIn
+	burnInStats    *Stats
+	burnInQuantile *quantile.Stream
+	// ttfb and ttlb are only kept once asked by ReportTTFB
+	ttfb *timingStats
+	ttlb *timingStats
+	// variants are A and B of '--url-b', only kept once asked by
+	// CompareVariants
+	variants    map[string]*variantStats
+	variantURLs []string
+	// windowSeconds are the seconds of the latency kept for LiveSnapshot,
+	// once asked by Window
+	window        time.Duration
+	windowSeconds []*windowSecond
 
 	readBytes  int64
 	writeBytes int64
 
+	decodedReadBytes  int64
+	decodedWriteBytes int64
+
 	doneChan chan struct{}
 }
 
 func NewStreamReport() *StreamReport {
 	return &StreamReport{
 		latencyQuantile:  quantile.NewTargeted(quantilesTarget),
+		serverStats:      &Stats{},
+		overheadStats:    &Stats{},
+		queueStats:       &Stats{},
+		overheadQuantile: quantile.NewTargeted(quantilesTarget),
+		tlsQuantile:      quantile.NewTargeted(quantilesTarget),
 		latencyHistogram: histogram.New(8),
 		codes:            make(map[string]int64, 1),
 		errors:           make(map[string]int64, 1),
+		connStats:        make(map[string]*Stats, len(connPhases)),
+		groups:           make(map[string]map[string]*groupStats, len(groupDimensions)),
+		redirects:        make(map[redirectKey]int64),
+		hsts:             make(map[string]int64),
+		violations:       make(map[string]int64),
+		cacheCounts:      make(map[string]int64),
+		cacheLatency:     make(map[string]*Stats),
+		cacheHistograms:  make(map[string]*histogram.Histogram),
+		cacheControls:    make(map[string]int64),
+		ageStats:         &Stats{},
+		firstLegStats:    &Stats{},
+		followStats:      &Stats{},
+		followCodes:      make(map[string]int64),
+		expects:          make(map[string]int64),
+		continueStats:    &Stats{},
+		sizeStats:        &Stats{},
+		perKBStats:       &Stats{},
+		perKBQuantile:    quantile.NewTargeted(quantilesTarget),
 		doneChan:         make(chan struct{}, 1),
 		latencyStats:     &Stats{},
 		rpsStats:         &Stats{},
-		latencyWithinSec: &Stats{},
 	}
 }
 
+// SetTarget makes the charts follow the load aimed at by '--schedule'.
+func (s *StreamReport) SetTarget(targetFunc func(at time.Time) *LoadTarget) {
+	s.lock.Lock()
+	s.targetFunc = targetFunc
+	s.lock.Unlock()
+}
+
+// SplitLatency makes the snapshots and the charts report the parts of the
+// latency spent resolving, connecting and sending the requests.
+func (s *StreamReport) SplitLatency() {
+	s.lock.Lock()
+	s.split = &latencySplit{}
+	s.lock.Unlock()
+}
+
+// SetChecks makes the snapshots count the responses which passed and failed
+// the checks of '--check'.
+func (s *StreamReport) SetChecks(checks []*Check) {
+	s.lock.Lock()
+	s.checkNames = make([]string, len(checks))
+	for i, c := range checks {
+		s.checkNames[i] = c.Name
+	}
+	s.checkPasses = make([]int64, len(checks))
+	s.checkFails = make([]int64, len(checks))
+	s.lock.Unlock()
+}
+
+// BurnIn makes the first request of every new connection left out of the
+// latency stats, percentiles and charts, and reported apart instead.
+func (s *StreamReport) BurnIn() {
+	s.lock.Lock()
+	s.burnInStats = &Stats{}
+	s.burnInQuantile = quantile.NewTargeted(quantilesTarget)
+	s.lock.Unlock()
+}
+
+// ReportTTFB makes the snapshots and the charts report the time to the first
+// and to the last byte of the responses apart.
+func (s *StreamReport) ReportTTFB() {
+	s.lock.Lock()
+	s.ttfb = newTimingStats()
+	s.ttlb = newTimingStats()
+	s.lock.Unlock()
+}
+
+// ReportConnections makes the snapshots report the n connections which fared
+// the worst, with the most errors then the highest P99 latency.
+func (s *StreamReport) ReportConnections(n int) {
+	s.lock.Lock()
+	s.connections = make(map[connKey]*connectionStats)
+	s.worstConnections = n
+	s.lock.Unlock()
+}
+
+// otherErrors counts the errors past the distinct ones kept by BoundMemory.
+const otherErrors = "other errors"
+
+// BoundMemory caps what grows along the run for the soak tests of
+// '--checkpoint-interval': the distinct errors, the others counted together
+// as otherErrors, and the seconds of the history of the charts, the oldest
+// ones dropped.
+func (s *StreamReport) BoundMemory(maxErrors, maxHistory int) {
+	s.lock.Lock()
+	s.maxErrors, s.maxHistory = maxErrors, maxHistory
+	s.lock.Unlock()
+}
+
+// Window makes LiveSnapshot report the latency stats and percentiles of the
+// last d of the run only.
+func (s *StreamReport) Window(d time.Duration) {
+	s.lock.Lock()
+	s.window = d
+	s.lock.Unlock()
+}
+
+// windowSecond is a second of the latency kept for the window.
+type windowSecond struct {
+	at      time.Time
+	latency Stats
+	costs   []float64
+}
+
+// addWindowSecond keeps the second ending at, dropping the seconds ended
+// before the window. It must be called with the lock held.
+func (s *StreamReport) addWindowSecond(at time.Time, w *secondStats) {
+	s.windowSeconds = append(s.windowSeconds, &windowSecond{
+		at:      at,
+		latency: w.latency,
+		costs:   append([]float64(nil), w.costs...),
+	})
+	for len(s.windowSeconds) > 0 && !s.windowSeconds[0].at.After(at.Add(-s.window)) {
+		s.windowSeconds = s.windowSeconds[1:]
+	}
+}
+
+// Events makes the snapshots report the events of '--sse' sent over the
+// connections, the first event of every stream left out of the latency stats
+// and reported apart, the latency being the time between the events.
+func (s *StreamReport) Events(concurrency int) {
+	s.lock.Lock()
+	s.firstEventStats = &Stats{}
+	s.concurrency = concurrency
+	s.lock.Unlock()
+}
+
+// count is the number of requests done, must be called with the lock held.
+func (s *StreamReport) count() int64 {
+	n := s.latencyStats.count
+	if s.burnInStats != nil {
+		n += s.burnInStats.count
+	}
+	if s.firstEventStats != nil {
+		n += s.firstEventStats.count
+	}
+	return n
+}
+
+// SetResources makes the snapshots report the resources taken by the run.
+func (s *StreamReport) SetResources(resourcesFunc func() *ResourceReport) {
+	s.lock.Lock()
+	s.resourcesFunc = resourcesFunc
+	s.lock.Unlock()
+}
+
+func (s *StreamReport) target(at time.Time) *LoadTarget {
+	if s.targetFunc == nil {
+		return nil
+	}
+	return s.targetFunc(at)
+}
+
 func (s *StreamReport) insert(v float64) {
 	s.latencyQuantile.Insert(v)
 	s.latencyHistogram.Insert(v)
@@ -104,27 +429,304 @@ func (s *StreamReport) insert(v float64) {
 	s.latencyStats.Update(v)
 }
 
+func (s *StreamReport) insertConn(phase string, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	stats, ok := s.connStats[phase]
+	if !ok {
+		stats = &Stats{}
+		s.connStats[phase] = stats
+	}
+	stats.Update(float64(d))
+}
+
+func (s *StreamReport) insertCache(r *ReportRecord) {
+	s.cacheCounts[r.cache]++
+	s.cacheControls[r.cacheControl]++
+	if r.age >= 0 {
+		s.ageStats.Update(float64(r.age))
+	}
+	stats, ok := s.cacheLatency[r.cache]
+	if !ok {
+		stats = &Stats{}
+		s.cacheLatency[r.cache] = stats
+	}
+	stats.Update(float64(r.cost))
+	if r.cache == "unknown" {
+		return
+	}
+	h, ok := s.cacheHistograms[r.cache]
+	if !ok {
+		h = histogram.New(8)
+		s.cacheHistograms[r.cache] = h
+	}
+	h.Insert(float64(r.cost))
+}
+
+// connKey identifies a connection among those of every worker.
+type connKey struct {
+	worker string
+	id     int64
+}
+
+// connectionTarget is the quantile of the connections, their P99 latency.
+var connectionTarget = map[float64]float64{0.99: 0.001}
+
+// connectionStats are the stats of the requests of a connection.
+type connectionStats struct {
+	ip       string
+	count    int64
+	errors   int64
+	latency  Stats
+	quantile *quantile.Stream
+}
+
+func (s *StreamReport) insertConnection(r *ReportRecord) {
+	key := connKey{r.worker, r.connID}
+	c, ok := s.connections[key]
+	if !ok {
+		c = &connectionStats{quantile: quantile.NewTargeted(connectionTarget)}
+		s.connections[key] = c
+	}
+	if r.ip != "" {
+		c.ip = r.ip
+	}
+	c.count++
+	if r.error != "" {
+		c.errors++
+	}
+	c.latency.Update(float64(r.cost))
+	c.quantile.Insert(float64(r.cost))
+}
+
+// groupDimensions are the dimensions requests are grouped by in the report,
+// in the order they are printed.
+var groupDimensions = []string{"host", "ip", "body", "worker", "endpoint"}
+
+// groupStats are the stats of the requests sharing the value of a dimension,
+// like the host they were sent to.
+type groupStats struct {
+	count     int64
+	errors    int64
+	latency   Stats
+	peakBusy  int64
+	peakConns int64
+	// quantile is the latency of the endpoints, reported by their
+	// percentiles, nil for the other dimensions
+	quantile    *quantile.Stream
+	percentiles []float64
+}
+
+func (s *StreamReport) group(dimension, key string, r *ReportRecord) *groupStats {
+	groups, ok := s.groups[dimension]
+	if !ok {
+		groups = make(map[string]*groupStats)
+		s.groups[dimension] = groups
+	}
+	g, ok := groups[key]
+	if !ok {
+		g = &groupStats{}
+		if dimension == "endpoint" {
+			g.quantile = quantile.NewTargeted(quantilesTarget)
+		}
+		groups[key] = g
+	}
+	g.count++
+	if r.error != "" {
+		g.errors++
+	}
+	g.latency.Update(float64(r.cost))
+	if g.quantile != nil {
+		g.quantile.Insert(float64(r.cost))
+	}
+	return g
+}
+
+// chartPercentiles are the latency percentiles the charts may show, those of
+// '--percentiles' if given.
+var chartPercentiles = []float64{0.50, 0.90, 0.99, 0.999}
+
+// secondStats are the stats of the requests of the second going on, charted
+// once it's over.
+type secondStats struct {
+	latency  Stats
+	quantile *quantile.Stream
+	errors   int64
+	codes    map[string]int64
+	split    latencySplit
+	ttfb     Stats
+	ttlb     Stats
+	// heatmap counts the latencies by the buckets of heatmapBounds
+	heatmap []int64
+	// endpoints are the latencies of the endpoints, for their P99
+	endpoints map[string]*quantile.Stream
+	// costs are the latencies kept for the window of LiveSnapshot, at
+	// random past maxWindowCosts
+	costs []float64
+}
+
+// maxWindowCosts caps the latencies kept by second for the window.
+const maxWindowCosts = 10000
+
+// keepCost keeps the latency for the window, replacing one kept at random
+// once there are maxWindowCosts of them. It must follow the latency update.
+func (w *secondStats) keepCost(cost float64) {
+	if len(w.costs) < maxWindowCosts {
+		w.costs = append(w.costs, cost)
+	} else if i := rand.Int63n(w.latency.count); i < maxWindowCosts {
+		w.costs[i] = cost
+	}
+}
+
+// endpointQuantile is the percentile of the latency of the endpoints charted.
+const endpointQuantile = 0.99
+
+func newSecondStats() *secondStats {
+	return &secondStats{quantile: quantile.NewTargeted(quantilesTarget), codes: make(map[string]int64),
+		heatmap: make([]int64, len(heatmapBounds)+1), endpoints: make(map[string]*quantile.Stream)}
+}
+
+// endpointPercentiles returns the P99 latency of the endpoints requested
+// within the second, nil if none was.
+func (w *secondStats) endpointPercentiles() map[string]float64 {
+	var values map[string]float64
+	for endpoint, q := range w.endpoints {
+		if q.Count() == 0 {
+			continue
+		}
+		if values == nil {
+			values = make(map[string]float64, len(w.endpoints))
+		}
+		values[endpoint] = q.Query(endpointQuantile)
+	}
+	return values
+}
+
+// codeRates returns the rates of the status classes over elapsed.
+func (w *secondStats) codeRates(elapsed time.Duration) map[string]float64 {
+	rates := make(map[string]float64, len(w.codes))
+	for code, n := range w.codes {
+		rates[code] = float64(n) / elapsed.Seconds()
+	}
+	return rates
+}
+
+func (w *secondStats) percentiles() []float64 {
+	values := make([]float64, len(chartPercentiles))
+	for i, q := range chartPercentiles {
+		values[i] = w.quantile.Query(q)
+	}
+	return values
+}
+
+func (w *secondStats) reset() {
+	w.latency.Reset()
+	w.quantile.Reset()
+	w.errors = 0
+	for code := range w.codes {
+		delete(w.codes, code)
+	}
+	w.split = latencySplit{}
+	w.ttfb.Reset()
+	w.ttlb.Reset()
+	for i := range w.heatmap {
+		w.heatmap[i] = 0
+	}
+	for _, q := range w.endpoints {
+		q.Reset()
+	}
+	w.costs = w.costs[:0]
+}
+
+// latencySplit sums the latency of the requests along with the parts of it
+// spent opening their connections.
+type latencySplit struct {
+	total   time.Duration
+	dns     time.Duration
+	connect time.Duration
+	tls     time.Duration
+}
+
+func (l *latencySplit) add(r *ReportRecord) {
+	l.total += r.cost
+	if r.conn.New {
+		l.dns += r.conn.DNS
+		l.connect += r.conn.Connect + r.conn.Proxy
+		l.tls += r.conn.TLS
+	}
+}
+
+// report returns the shares of the latency, nil before any request.
+func (l *latencySplit) report() *LatencySplit {
+	if l.total <= 0 {
+		return nil
+	}
+	total := float64(l.total)
+	ls := &LatencySplit{
+		DNS:     float64(l.dns) / total,
+		Connect: float64(l.connect) / total,
+		TLS:     float64(l.tls) / total,
+	}
+	// the dial of a failed request may outlast it
+	if ls.Request = 1 - ls.DNS - ls.Connect - ls.TLS; ls.Request < 0 {
+		ls.Request = 0
+	}
+	return ls
+}
+
 func (s *StreamReport) Collect(records <-chan *ReportRecord) {
-	latencyWithinSecTemp := &Stats{}
+	withinSec := newSecondStats()
 	go func() {
 		ticker := time.NewTicker(time.Second)
 		lastCount := int64(0)
 		lastTime := startTime
+		var lastReadBytes, lastWriteBytes int64
 		for {
 			select {
 			case <-ticker.C:
 				s.lock.Lock()
-				dc := s.latencyStats.count - lastCount
+				dc := s.count() - lastCount
 				if dc > 0 {
-					rps := float64(dc) / time.Since(lastTime).Seconds()
+					elapsed := time.Since(lastTime)
+					rps := float64(dc) / elapsed.Seconds()
+					target := s.target(lastTime.Add(elapsed / 2))
 					s.rpsStats.Update(rps)
-					lastCount = s.latencyStats.count
+					lastCount = s.count()
 					lastTime = time.Now()
 
-					*s.latencyWithinSec = *latencyWithinSecTemp
-					s.rpsWithinSec = rps
-					latencyWithinSecTemp.Reset()
+					s.chartsWithinSec = &ChartsReport{
+						Time:            lastTime,
+						RPS:             rps,
+						Latency:         withinSec.latency,
+						Percentiles:     withinSec.percentiles(),
+						Errors:          float64(withinSec.errors) / elapsed.Seconds(),
+						Codes:           withinSec.codeRates(elapsed),
+						Heatmap:         append([]int64(nil), withinSec.heatmap...),
+						Endpoints:       withinSec.endpointPercentiles(),
+						ReadThroughput:  float64(s.readBytes-lastReadBytes) / 1024.0 / 1024.0 / elapsed.Seconds(),
+						WriteThroughput: float64(s.writeBytes-lastWriteBytes) / 1024.0 / 1024.0 / elapsed.Seconds(),
+						Target:          target,
+					}
+					if s.split != nil {
+						s.chartsWithinSec.LatencySplit = withinSec.split.report()
+					}
+					if s.ttfb != nil && withinSec.ttfb.count > 0 {
+						ttfb, ttlb := withinSec.ttfb, withinSec.ttlb
+						s.chartsWithinSec.TTFB, s.chartsWithinSec.TTLB = &ttfb, &ttlb
+					}
+					if s.window > 0 {
+						s.addWindowSecond(lastTime, withinSec)
+					}
+					lastReadBytes, lastWriteBytes = s.readBytes, s.writeBytes
+					withinSec.reset()
 					s.noDateWithinSec = false
+					s.chartsHistory = append(s.chartsHistory, s.chartsWithinSec)
+					if s.maxHistory > 0 && len(s.chartsHistory) > s.maxHistory {
+						n := copy(s.chartsHistory, s.chartsHistory[1:])
+						s.chartsHistory[n] = nil
+						s.chartsHistory = s.chartsHistory[:n]
+					}
 				} else {
 					s.noDateWithinSec = true
 				}
@@ -135,36 +737,276 @@ func (s *StreamReport) Collect(records <-chan *ReportRecord) {
 		}
 	}()
 
+	batch := make([]*ReportRecord, 0, maxCollectBatch)
 	for {
 		r, ok := <-records
 		if !ok {
 			close(s.doneChan)
 			break
 		}
+		// take the records already waiting along, so that the lock is taken
+		// once for all of them and snapshots don't slow down the requests
+		batch = append(batch[:0], r)
+	drain:
+		for len(batch) < maxCollectBatch {
+			select {
+			case r, ok := <-records:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, r)
+			default:
+				break drain
+			}
+		}
 		s.lock.Lock()
-		latencyWithinSecTemp.Update(float64(r.cost))
+		for _, r := range batch {
+			s.collect(r, withinSec)
+		}
+		s.lock.Unlock()
+		for i, r := range batch {
+			recordPool.Put(r)
+			batch[i] = nil
+		}
+	}
+}
+
+// maxCollectBatch caps the records collected at once under the lock.
+const maxCollectBatch = 256
+
+// collect must be called with the lock held.
+func (s *StreamReport) collect(r *ReportRecord, withinSec *secondStats) {
+	if r.firstEvent && s.firstEventStats != nil {
+		s.firstEventStats.Update(float64(r.cost))
+	} else if s.burnInStats != nil && r.conn.New {
+		s.burnInStats.Update(float64(r.cost))
+		s.burnInQuantile.Insert(float64(r.cost))
+	} else {
+		withinSec.latency.Update(float64(r.cost))
+		withinSec.heatmap[heatmapBucket(r.cost)]++
+		withinSec.quantile.Insert(float64(r.cost))
+		if s.window > 0 {
+			withinSec.keepCost(float64(r.cost))
+		}
 		s.insert(float64(r.cost))
-		if r.code != "" {
-			s.codes[r.code] ++
+	}
+	if s.ttfb != nil && r.ttfb >= 0 {
+		s.ttfb.add(r.ttfb)
+		s.ttlb.add(r.ttlb)
+		withinSec.ttfb.Update(float64(r.ttfb))
+		withinSec.ttlb.Update(float64(r.ttlb))
+	}
+	if r.code != "" {
+		s.codes[r.code]++
+		withinSec.codes[r.code]++
+	}
+	if r.error != "" {
+		key := r.error
+		if _, ok := s.errors[key]; !ok && s.maxErrors > 0 && len(s.errors) >= s.maxErrors {
+			key = otherErrors
 		}
-		if r.error != "" {
-			s.errors[r.error] ++
+		s.errors[key]++
+		withinSec.errors++
+	}
+	if r.affinityChecked {
+		s.affinityChecks++
+		if r.affinityBroken {
+			s.affinityViolations++
 		}
-		s.readBytes = r.readBytes
-		s.writeBytes = r.writeBytes
-		s.lock.Unlock()
-		recordPool.Put(r)
 	}
+	if r.host != "" {
+		g := s.group("host", r.host, r)
+		if r.hostBusy > g.peakBusy {
+			g.peakBusy = r.hostBusy
+		}
+		if r.hostConns > g.peakConns {
+			g.peakConns = r.hostConns
+		}
+	}
+	if r.ip != "" {
+		s.group("ip", r.ip, r)
+	}
+	if r.body != "" {
+		s.group("body", r.body, r)
+	}
+	if r.worker != "" {
+		s.group("worker", r.worker, r)
+	}
+	if r.endpoint != "" {
+		s.group("endpoint", r.endpoint, r)
+		q, ok := withinSec.endpoints[r.endpoint]
+		if !ok {
+			q = quantile.NewTargeted(map[float64]float64{endpointQuantile: 0.001})
+			withinSec.endpoints[r.endpoint] = q
+		}
+		q.Insert(float64(r.cost))
+	}
+	if r.redirect.kind != "" {
+		s.redirects[r.redirect]++
+	}
+	if r.hsts != "" {
+		s.hsts[r.hsts]++
+	}
+	for _, v := range r.violations {
+		s.violations[v]++
+	}
+	if s.connections != nil && r.connID > 0 {
+		s.insertConnection(r)
+	}
+	if v, ok := s.variants[r.variant]; ok {
+		v.add(r)
+	}
+	for i := range s.checkNames {
+		bit := uint64(1) << uint(i)
+		if r.checksRun&bit == 0 {
+			continue
+		}
+		if r.checksFailed&bit != 0 {
+			s.checkFails[i]++
+		} else {
+			s.checkPasses[i]++
+		}
+	}
+	if r.cache != "" {
+		s.insertCache(r)
+	}
+	if r.follow >= 0 {
+		s.firstLegStats.Update(float64(r.cost - r.follow))
+		s.followStats.Update(float64(r.follow))
+		s.followCodes[r.followCode]++
+	}
+	if r.followMissing {
+		s.followMissing++
+	}
+	if r.expect != "" {
+		s.expects[r.expect]++
+	}
+	if r.continueWait >= 0 {
+		s.continueStats.Update(float64(r.continueWait))
+	}
+	if r.size >= 0 && r.error == "" {
+		kb := float64(r.size) / 1024
+		if kb < 1 {
+			kb = 1
+		}
+		perKB := float64(r.cost) / kb
+		s.sizeStats.Update(float64(r.size))
+		s.perKBStats.Update(perKB)
+		s.perKBQuantile.Insert(perKB)
+	}
+	if r.queue >= 0 {
+		s.queueStats.Update(float64(r.queue))
+	}
+	if r.serverTime >= 0 && r.error == "" {
+		// what the client waited for beyond the server processing time
+		overhead := float64(r.cost - r.serverTime)
+		s.serverStats.Update(float64(r.serverTime))
+		s.overheadStats.Update(overhead)
+		s.overheadQuantile.Insert(overhead)
+	}
+	if s.split != nil {
+		s.split.add(r)
+		withinSec.split.add(r)
+	}
+	if r.conn.New {
+		s.insertConn("dns", r.conn.DNS)
+		s.insertConn("connect", r.conn.Connect)
+		s.insertConn("proxy", r.conn.Proxy)
+		s.insertConn("tls", r.conn.TLS)
+		if r.conn.TLS > 0 {
+			if r.conn.Resumed {
+				s.tlsResumed++
+			} else {
+				s.tlsFull++
+			}
+			s.tlsQuantile.Insert(float64(r.conn.TLS))
+		}
+	}
+	s.readBytes = r.readBytes
+	s.writeBytes = r.writeBytes
+	s.decodedReadBytes = r.decodedReadBytes
+	s.decodedWriteBytes = r.decodedWriteBytes
+}
+
+// GroupReport sums up the requests sharing the value of a dimension. Only the
+// dimensions with more than one value are reported.
+type GroupReport struct {
+	Dimension string
+	Key       string
+	Count     int64
+	Errors    int64
+	Mean      time.Duration
+	Max       time.Duration
+	PeakBusy  int64 `json:",omitempty"`
+	PeakConns int64 `json:",omitempty"`
+	// Percentiles are those of the latency of the endpoints
+	Percentiles []*struct {
+		Percentile float64
+		Latency    time.Duration
+	} `json:",omitempty"`
+}
+
+// ConnectionReport sums up the requests of a connection, ID being its number
+// in the run, prefixed by the worker of the controller if any.
+type ConnectionReport struct {
+	ID     string
+	IP     string `json:",omitempty"`
+	Count  int64
+	Errors int64
+	Mean   time.Duration
+	P99    time.Duration
+	Max    time.Duration
+}
+
+// RedirectReport counts the redirects of a kind to a target, with '--report-redirects'.
+type RedirectReport struct {
+	Kind   string
+	Target string
+	Count  int64
 }
 
 type SnapshotReport struct {
-	Elapsed         time.Duration
+	Elapsed time.Duration
+	// Window is set on the live snapshots of '--window', Stats and
+	// Percentiles covering the last Window of the run only
+	Window          time.Duration `json:",omitempty"`
 	Count           int64
 	Codes           map[string]int64
 	Errors          map[string]int64
 	RPS             float64
 	ReadThroughput  float64
 	WriteThroughput float64
+	// ReadBytes and WriteBytes are the totals of the response bytes read and
+	// of the request bytes written, on the wire
+	ReadBytes  int64
+	WriteBytes int64
+
+	DecodedReadThroughput  float64
+	DecodedWriteThroughput float64
+
+	AffinityChecks     int64
+	AffinityViolations int64
+
+	// Resources is not set on the controller of '--workers'
+	Resources *ResourceReport `json:",omitempty"`
+
+	// PerKB is only set with '--per-kb'
+	PerKB *PerKBReport `json:",omitempty"`
+
+	// BurnIn is only set with '--burn-in', Stats and Percentiles leaving out
+	// the requests it counts
+	BurnIn *BurnInReport `json:",omitempty"`
+
+	// TTFB and TTLB are only set with '--report-ttfb'
+	TTFB *TimingReport `json:",omitempty"`
+	TTLB *TimingReport `json:",omitempty"`
+
+	// Events is only set with '--sse', Count and RPS counting the events
+	Events *EventsReport `json:",omitempty"`
+
+	// LatencySplit is only set with '--disable-keepalive', '--max-conn-requests'
+	// or '--dns-refresh'
+	LatencySplit *LatencySplit `json:",omitempty"`
 
 	Stats *struct {
 		Min    time.Duration
@@ -180,52 +1022,714 @@ type SnapshotReport struct {
 		Max    float64
 	}
 
+	ConnStats []*struct {
+		Phase  string
+		Count  int64
+		Min    time.Duration
+		Mean   time.Duration
+		StdDev time.Duration
+		Max    time.Duration
+	}
+
 	Percentiles []*struct {
 		Percentile float64
 		Latency    time.Duration
 	}
 
-	Histograms []*struct {
-		Mean  time.Duration
-		Count int
-	}
-}
+	Groups []*GroupReport `json:",omitempty"`
 
-func (s *StreamReport) Snapshot() *SnapshotReport {
-	s.lock.Lock()
+	// Connections are the worst connections of '--report-conns'
+	Connections []*ConnectionReport `json:",omitempty"`
 
-	rs := &SnapshotReport{
-		Elapsed: time.Since(startTime),
-		Count:   s.latencyStats.count,
-		Stats: &struct {
-			Min    time.Duration
-			Mean   time.Duration
-			StdDev time.Duration
-			Max    time.Duration
-		}{time.Duration(s.latencyStats.min), time.Duration(s.latencyStats.Mean()),
-			time.Duration(s.latencyStats.Stddev()), time.Duration(s.latencyStats.max)},
-	}
-	if s.rpsStats.count > 0 {
-		rs.RpsStats = &struct {
-			Min    float64
-			Mean   float64
-			StdDev float64
-			Max    float64
-		}{s.rpsStats.min, s.rpsStats.Mean(),
-			s.rpsStats.Stddev(), s.rpsStats.max}
-	}
+	// Variants are A and B of '--url-b', only set with it
+	Variants []*VariantReport `json:",omitempty"`
 
-	elapseInSec := rs.Elapsed.Seconds()
-	rs.RPS = float64(rs.Count) / elapseInSec
-	rs.ReadThroughput = float64(s.readBytes) / 1024.0 / 1024.0 / elapseInSec
-	rs.WriteThroughput = float64(s.writeBytes) / 1024.0 / 1024.0 / elapseInSec
+	// QueueStats is only set with the rates of '--schedule'
+	QueueStats *struct {
+		Min    time.Duration
+		Mean   time.Duration
+		StdDev time.Duration
+		Max    time.Duration
+	} `json:",omitempty"`
 
-	rs.Codes = make(map[string]int64, len(s.codes))
-	for k, v := range s.codes {
-		rs.Codes[k] = v
+	// ServerStats and OverheadStats are only set with '--expect-latency-header'
+	ServerStats *struct {
+		Min    time.Duration
+		Mean   time.Duration
+		StdDev time.Duration
+		Max    time.Duration
+	} `json:",omitempty"`
+	OverheadStats *struct {
+		Min    time.Duration
+		Mean   time.Duration
+		StdDev time.Duration
+		Max    time.Duration
+	} `json:",omitempty"`
+	OverheadPercentiles []*struct {
+		Percentile float64
+		Latency    time.Duration
+	} `json:",omitempty"`
+
+	TLSHandshakes *struct {
+		Full    int64
+		Resumed int64
+	} `json:",omitempty"`
+	TLSPercentiles []*struct {
+		Percentile float64
+		Latency    time.Duration
+	} `json:",omitempty"`
+
+	Redirects []*RedirectReport `json:",omitempty"`
+	HSTS      map[string]int64  `json:",omitempty"`
+
+	Violations map[string]int64 `json:",omitempty"`
+
+	// Checks are those of '--check', in their order
+	Checks []*CheckReport `json:",omitempty"`
+
+	Cache *CacheReport `json:",omitempty"`
+
+	FollowUps *FollowUpReport `json:",omitempty"`
+
+	Continue *ContinueReport `json:",omitempty"`
+
+	Histograms []*struct {
+		Mean  time.Duration
+		Count int
+	}
+}
+
+// CacheReport sums up the cache statuses of the responses, with '--report-cache'.
+type CacheReport struct {
+	// HitRatio is the ratio of the hits among the hits and misses
+	HitRatio float64
+	Statuses []*CacheStatusReport
+	// Age is only set if the responses had an Age header
+	Age *struct {
+		Min    time.Duration
+		Mean   time.Duration
+		StdDev time.Duration
+		Max    time.Duration
+	} `json:",omitempty"`
+	CacheControls []*CacheControlReport
+}
+
+// PerKBReport is the latency divided by the size in KB of the response body,
+// bodies under 1KB counting as 1KB, along with the size of the bodies in bytes.
+type PerKBReport struct {
+	Size *struct {
+		Min    float64
+		Mean   float64
+		StdDev float64
+		Max    float64
+	}
+	Latency *struct {
+		Min    time.Duration
+		Mean   time.Duration
+		StdDev time.Duration
+		Max    time.Duration
+	}
+	Percentiles []*struct {
+		Percentile float64
+		Latency    time.Duration
+	}
+}
+
+// BurnInReport is the latency of the first requests of the connections, which
+// pay for the TLS warmup and the accept queue of the server.
+type BurnInReport struct {
+	Count int64
+	Stats *struct {
+		Min    time.Duration
+		Mean   time.Duration
+		StdDev time.Duration
+		Max    time.Duration
+	}
+	Percentiles []*struct {
+		Percentile float64
+		Latency    time.Duration
+	}
+}
+
+// EventsReport sums up the event streams of '--sse', Stats and Percentiles
+// being the time between the events of a stream, and FirstEvent the time to
+// the first event of the streams.
+type EventsReport struct {
+	Streams int64
+	// PerConn is the events per second over every connection
+	PerConn    float64
+	FirstEvent *struct {
+		Min    time.Duration
+		Mean   time.Duration
+		StdDev time.Duration
+		Max    time.Duration
+	}
+}
+
+// LatencySplit are the shares of the latency spent resolving the host,
+// connecting through the proxy if any, in TLS handshakes and the rest on the
+// requests themselves, so that churning connections can be told apart.
+type LatencySplit struct {
+	DNS     float64
+	Connect float64
+	TLS     float64
+	Request float64
+}
+
+// FollowUpReport is set with '--follow-header', the latency of the first leg
+// and of the follow-up of the requests followed up being split.
+type FollowUpReport struct {
+	// Codes are those of the follow-ups, Missing counts the responses which
+	// lacked the header to follow
+	Codes    map[string]int64
+	Missing  int64
+	FirstLeg *struct {
+		Min    time.Duration
+		Mean   time.Duration
+		StdDev time.Duration
+		Max    time.Duration
+	} `json:",omitempty"`
+	FollowUp *struct {
+		Min    time.Duration
+		Mean   time.Duration
+		StdDev time.Duration
+		Max    time.Duration
+	} `json:",omitempty"`
+}
+
+// ContinueReport is set with '--expect-continue', how the servers took the
+// Expect: 100-continue of the requests with a body and the wait for their
+// 100 Continue, apart from the latency.
+type ContinueReport struct {
+	// Outcomes count the requests continued, rejected by a final response to
+	// their headers, or whose body was sent after '--expect-timeout'
+	Outcomes map[string]int64
+	Wait     *struct {
+		Min    time.Duration
+		Mean   time.Duration
+		StdDev time.Duration
+		Max    time.Duration
+	} `json:",omitempty"`
+}
+
+// CacheStatusReport is the latency of the responses of a cache status, the
+// histogram being only kept for hits and misses.
+type CacheStatusReport struct {
+	Status     string
+	Count      int64
+	Mean       time.Duration
+	Max        time.Duration
+	Histograms []*struct {
+		Mean  time.Duration
+		Count int
+	} `json:",omitempty"`
+}
+
+type CacheControlReport struct {
+	Value string
+	Count int64
+}
+
+// Latency returns the running latency percentile q, or the mean for 0, and
+// false before any request succeeded.
+func (s *StreamReport) Latency(q float64) (float64, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.latencyStats.count == 0 {
+		return 0, false
+	}
+	if q == 0 {
+		return s.latencyStats.Mean(), true
+	}
+	return s.latencyQuantile.Query(q), true
+}
+
+func (s *StreamReport) Snapshot() *SnapshotReport {
+	s.lock.Lock()
+	c := s.copy()
+	resourcesFunc := s.resourcesFunc
+	s.lock.Unlock()
+	c.query()
+	rs := c.snapshot()
+	if resourcesFunc != nil {
+		rs.Resources = resourcesFunc()
+	}
+	return rs
+}
+
+// LiveSnapshot is the snapshot of the run going on, with the latency stats and
+// percentiles of the window only if asked by Window. Once the run is done, it
+// is the one of the whole run.
+func (s *StreamReport) LiveSnapshot() *SnapshotReport {
+	select {
+	case <-s.doneChan:
+		return s.Snapshot()
+	default:
+	}
+	s.lock.Lock()
+	if s.window <= 0 {
+		s.lock.Unlock()
+		return s.Snapshot()
+	}
+	c := s.copy()
+	c.latencyStats = Stats{}
+	var costs []float64
+	since := time.Now().Add(-s.window)
+	for _, ws := range s.windowSeconds {
+		if ws.at.After(since) {
+			c.latencyStats.merge(ws.latency)
+			costs = append(costs, ws.costs...)
+		}
+	}
+	c.queries = append(c.queries, func() {
+		stream := quantile.NewTargeted(quantilesTarget)
+		for _, cost := range costs {
+			stream.Insert(cost)
+		}
+		c.percentiles = queryQuantiles(stream)
+	})
+	c.window = s.window
+	resourcesFunc := s.resourcesFunc
+	s.lock.Unlock()
+	c.query()
+	rs := c.snapshot()
+	if resourcesFunc != nil {
+		rs.Resources = resourcesFunc()
+	}
+	return rs
+}
+
+// reportCopy is what a snapshot is made of, copied while holding the lock so
+// that making the snapshot doesn't hold up Collect.
+type reportCopy struct {
+	elapsed time.Duration
+	window  time.Duration
+
+	latencyStats  Stats
+	rpsStats      Stats
+	serverStats   Stats
+	overheadStats Stats
+	queueStats    Stats
+	connStats     map[string]Stats
+	groups        map[string]map[string]groupStats
+
+	percentiles         []float64
+	overheadPercentiles []float64
+	tlsPercentiles      []float64
+	histogram           []histogram.Bin
+
+	codes      map[string]int64
+	errors     map[string]int64
+	redirects  map[redirectKey]int64
+	hsts       map[string]int64
+	violations map[string]int64
+
+	cacheCounts     map[string]int64
+	cacheLatency    map[string]Stats
+	cacheHistograms map[string][]histogram.Bin
+	cacheControls   map[string]int64
+	ageStats        Stats
+
+	firstLegStats Stats
+	followStats   Stats
+	followCodes   map[string]int64
+	followMissing int64
+
+	expects       map[string]int64
+	continueStats Stats
+
+	sizeStats        Stats
+	perKBStats       Stats
+	perKBPercentiles []float64
+
+	split *latencySplit
+
+	checks []*CheckReport
+
+	connections []*ConnectionReport
+	variants    []*VariantReport
+
+	// firstEventStats is nil without '--sse'
+	firstEventStats *Stats
+	concurrency     int
+
+	// burnInStats is nil without '--burn-in'
+	burnInStats       *Stats
+	burnInPercentiles []float64
+
+	// ttfb and ttlb are nil without '--report-ttfb'
+	ttfb *timingCopy
+	ttlb *timingCopy
+
+	affinityChecks     int64
+	affinityViolations int64
+	tlsFull            int64
+	tlsResumed         int64
+
+	readBytes         int64
+	writeBytes        int64
+	decodedReadBytes  int64
+	decodedWriteBytes int64
+
+	// queries fill in the quantiles once the lock is released
+	queries []func()
+}
+
+// queryLater copies stream, for query to be given the copy by the query of c
+// once the lock is released.
+func (c *reportCopy) queryLater(stream *quantile.Stream, targets map[float64]float64, query func(*quantileCopy)) {
+	q := copyQuantile(stream, targets)
+	c.queries = append(c.queries, func() { query(q) })
+}
+
+// query fills in the quantiles of c, without the lock held.
+func (c *reportCopy) query() {
+	for _, query := range c.queries {
+		query()
+	}
+	c.queries = nil
+}
+
+func copyCounts(m map[string]int64) map[string]int64 {
+	c := make(map[string]int64, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func copyBins(h *histogram.Histogram) []histogram.Bin {
+	var bins []histogram.Bin
+	for _, b := range h.Bins() {
+		bins = append(bins, *b)
+	}
+	return bins
+}
+
+// quantileCopy is a quantile stream of targets copied under the lock of the
+// report, to be queried once it is released. Merging the samples into another
+// stream isn't exact, so they are queried the way the stream queries them.
+type quantileCopy struct {
+	targets map[float64]float64
+	samples quantile.Samples
+	// raw samples are those not flushed by the stream yet, queried exactly
+	raw bool
+	n   float64
+}
+
+func copyQuantile(stream *quantile.Stream, targets map[float64]float64) *quantileCopy {
+	q := &quantileCopy{targets: targets, samples: append(quantile.Samples(nil), stream.Samples()...)}
+	q.raw = len(q.samples) == stream.Count()
+	for _, s := range q.samples {
+		q.n += s.Width
+	}
+	return q
+}
+
+// Query returns the value of quantile like quantile.Stream does.
+func (q *quantileCopy) Query(quantile float64) float64 {
+	if len(q.samples) == 0 {
+		return 0
+	}
+	if q.raw {
+		sort.Sort(q.samples)
+		i := int(math.Ceil(float64(len(q.samples)) * quantile))
+		if i > 0 {
+			i--
+		}
+		return q.samples[i].Value
+	}
+	t := math.Ceil(quantile * q.n)
+	t += math.Ceil(q.invariant(t) / 2)
+	p := q.samples[0]
+	var r float64
+	for _, s := range q.samples[1:] {
+		r += p.Width
+		if r+s.Width+s.Delta > t {
+			return p.Value
+		}
+		p = s
+	}
+	return p.Value
+}
+
+// invariant is the error allowed at rank r, that of quantile.NewTargeted.
+func (q *quantileCopy) invariant(r float64) float64 {
+	m := math.MaxFloat64
+	for quantile, epsilon := range q.targets {
+		var f float64
+		if quantile*q.n <= r {
+			f = 2 * epsilon * r / quantile
+		} else {
+			f = 2 * epsilon * (q.n - r) / (1 - quantile)
+		}
+		m = math.Min(m, f)
+	}
+	return m
+}
+
+// quantileQuerier is a quantile.Stream or a quantileCopy.
+type quantileQuerier interface {
+	Query(q float64) float64
+}
+
+func queryQuantiles(stream quantileQuerier) []float64 {
+	values := make([]float64, len(quantiles))
+	for i, q := range quantiles {
+		values[i] = stream.Query(q)
+	}
+	return values
+}
+
+// copy must be called with the lock held, and the query of the copy without.
+func (s *StreamReport) copy() *reportCopy {
+	c := &reportCopy{
+		elapsed: time.Since(startTime),
+
+		latencyStats:  *s.latencyStats,
+		rpsStats:      *s.rpsStats,
+		serverStats:   *s.serverStats,
+		overheadStats: *s.overheadStats,
+		queueStats:    *s.queueStats,
+		connStats:     make(map[string]Stats, len(s.connStats)),
+		groups:        make(map[string]map[string]groupStats, len(s.groups)),
+
+		codes:      copyCounts(s.codes),
+		errors:     copyCounts(s.errors),
+		redirects:  make(map[redirectKey]int64, len(s.redirects)),
+		hsts:       copyCounts(s.hsts),
+		violations: copyCounts(s.violations),
+
+		cacheCounts:     copyCounts(s.cacheCounts),
+		cacheLatency:    make(map[string]Stats, len(s.cacheLatency)),
+		cacheHistograms: make(map[string][]histogram.Bin, len(s.cacheHistograms)),
+		cacheControls:   copyCounts(s.cacheControls),
+		ageStats:        *s.ageStats,
+
+		firstLegStats: *s.firstLegStats,
+		followStats:   *s.followStats,
+		followCodes:   copyCounts(s.followCodes),
+		followMissing: s.followMissing,
+
+		expects:       copyCounts(s.expects),
+		continueStats: *s.continueStats,
+
+		sizeStats:  *s.sizeStats,
+		perKBStats: *s.perKBStats,
+
+		affinityChecks:     s.affinityChecks,
+		affinityViolations: s.affinityViolations,
+		tlsFull:            s.tlsFull,
+		tlsResumed:         s.tlsResumed,
+
+		readBytes:         s.readBytes,
+		writeBytes:        s.writeBytes,
+		decodedReadBytes:  s.decodedReadBytes,
+		decodedWriteBytes: s.decodedWriteBytes,
+	}
+	c.queryLater(s.latencyQuantile, quantilesTarget, func(q *quantileCopy) {
+		c.percentiles = queryQuantiles(q)
+	})
+	if s.perKBStats.count > 0 {
+		c.queryLater(s.perKBQuantile, quantilesTarget, func(q *quantileCopy) {
+			c.perKBPercentiles = queryQuantiles(q)
+		})
+	}
+	if s.split != nil {
+		split := *s.split
+		c.split = &split
+	}
+	for i, name := range s.checkNames {
+		c.checks = append(c.checks, &CheckReport{Name: name, Passes: s.checkPasses[i], Fails: s.checkFails[i]})
+	}
+	if s.connections != nil {
+		s.worstConnectionReports(c)
+	}
+	if s.variants != nil {
+		c.variants = s.variantReports(c)
+	}
+	if s.firstEventStats != nil {
+		firstEvent := *s.firstEventStats
+		c.firstEventStats = &firstEvent
+		c.concurrency = s.concurrency
+	}
+	if s.burnInStats != nil {
+		burnIn := *s.burnInStats
+		c.burnInStats = &burnIn
+		if burnIn.count > 0 {
+			c.queryLater(s.burnInQuantile, quantilesTarget, func(q *quantileCopy) {
+				c.burnInPercentiles = queryQuantiles(q)
+			})
+		}
+	}
+	if s.ttfb != nil {
+		c.ttfb = s.ttfb.copy(c)
+		c.ttlb = s.ttlb.copy(c)
+	}
+	if s.serverStats.count > 0 {
+		c.queryLater(s.overheadQuantile, quantilesTarget, func(q *quantileCopy) {
+			c.overheadPercentiles = queryQuantiles(q)
+		})
+	}
+	if s.tlsFull+s.tlsResumed > 0 {
+		c.queryLater(s.tlsQuantile, quantilesTarget, func(q *quantileCopy) {
+			c.tlsPercentiles = queryQuantiles(q)
+		})
+	}
+	c.histogram = copyBins(s.latencyHistogram)
+	for status, stats := range s.cacheLatency {
+		c.cacheLatency[status] = *stats
+	}
+	for status, h := range s.cacheHistograms {
+		c.cacheHistograms[status] = copyBins(h)
+	}
+	for phase, stats := range s.connStats {
+		c.connStats[phase] = *stats
+	}
+	for dimension, groups := range s.groups {
+		gs := make(map[string]groupStats, len(groups))
+		for k, g := range groups {
+			gc := *g
+			gc.quantile = nil
+			gs[k] = gc
+			if g.quantile != nil {
+				k := k
+				c.queryLater(g.quantile, quantilesTarget, func(q *quantileCopy) {
+					gc := gs[k]
+					gc.percentiles = queryQuantiles(q)
+					gs[k] = gc
+				})
+			}
+		}
+		c.groups[dimension] = gs
+	}
+	for k, v := range s.redirects {
+		c.redirects[k] = v
+	}
+	return c
+}
+
+func (c *reportCopy) snapshot() *SnapshotReport {
+	count := c.latencyStats.count
+	if c.burnInStats != nil {
+		count += c.burnInStats.count
+	}
+	if c.firstEventStats != nil {
+		count += c.firstEventStats.count
 	}
-	rs.Errors = make(map[string]int64, len(s.errors))
-	for k, v := range s.errors {
+	rs := &SnapshotReport{
+		Elapsed: c.elapsed,
+		Window:  c.window,
+		Count:   count,
+
+		AffinityChecks:     c.affinityChecks,
+		AffinityViolations: c.affinityViolations,
+
+		Stats: &struct {
+			Min    time.Duration
+			Mean   time.Duration
+			StdDev time.Duration
+			Max    time.Duration
+		}{time.Duration(c.latencyStats.min), time.Duration(c.latencyStats.Mean()),
+			time.Duration(c.latencyStats.Stddev()), time.Duration(c.latencyStats.max)},
+	}
+	if c.rpsStats.count > 0 {
+		rs.RpsStats = &struct {
+			Min    float64
+			Mean   float64
+			StdDev float64
+			Max    float64
+		}{c.rpsStats.min, c.rpsStats.Mean(),
+			c.rpsStats.Stddev(), c.rpsStats.max}
+	}
+
+	for _, phase := range connPhases {
+		stats, ok := c.connStats[phase]
+		if !ok {
+			continue
+		}
+		rs.ConnStats = append(rs.ConnStats, &struct {
+			Phase  string
+			Count  int64
+			Min    time.Duration
+			Mean   time.Duration
+			StdDev time.Duration
+			Max    time.Duration
+		}{phase, stats.count, time.Duration(stats.min), time.Duration(stats.Mean()),
+			time.Duration(stats.Stddev()), time.Duration(stats.max)})
+	}
+
+	for _, dimension := range groupDimensions {
+		groups := c.groups[dimension]
+		if len(groups) < 2 {
+			continue
+		}
+		keys := make([]string, 0, len(groups))
+		for k := range groups {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			g := groups[k]
+			gr := &GroupReport{
+				Dimension: dimension,
+				Key:       k,
+				Count:     g.count,
+				Errors:    g.errors,
+				Mean:      time.Duration(g.latency.Mean()),
+				Max:       time.Duration(g.latency.max),
+				PeakBusy:  g.peakBusy,
+				PeakConns: g.peakConns,
+			}
+			for i, p := range g.percentiles {
+				gr.Percentiles = append(gr.Percentiles, &struct {
+					Percentile float64
+					Latency    time.Duration
+				}{quantiles[i], time.Duration(p)})
+			}
+			rs.Groups = append(rs.Groups, gr)
+		}
+	}
+
+	for k, v := range c.redirects {
+		rs.Redirects = append(rs.Redirects, &RedirectReport{Kind: k.kind, Target: k.target, Count: v})
+	}
+	sort.Slice(rs.Redirects, func(i, j int) bool {
+		a, b := rs.Redirects[i], rs.Redirects[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Kind+a.Target < b.Kind+b.Target
+	})
+	if len(c.hsts) > 0 {
+		rs.HSTS = make(map[string]int64, len(c.hsts))
+		for k, v := range c.hsts {
+			rs.HSTS[k] = v
+		}
+	}
+
+	if len(c.violations) > 0 {
+		rs.Violations = make(map[string]int64, len(c.violations))
+		for k, v := range c.violations {
+			rs.Violations[k] = v
+		}
+	}
+
+	elapseInSec := rs.Elapsed.Seconds()
+	rs.RPS = float64(rs.Count) / elapseInSec
+	rs.ReadThroughput = float64(c.readBytes) / 1024.0 / 1024.0 / elapseInSec
+	rs.WriteThroughput = float64(c.writeBytes) / 1024.0 / 1024.0 / elapseInSec
+	rs.ReadBytes = c.readBytes
+	rs.WriteBytes = c.writeBytes
+	rs.DecodedReadThroughput = float64(c.decodedReadBytes) / 1024.0 / 1024.0 / elapseInSec
+	rs.DecodedWriteThroughput = float64(c.decodedWriteBytes) / 1024.0 / 1024.0 / elapseInSec
+
+	rs.Codes = make(map[string]int64, len(c.codes))
+	for k, v := range c.codes {
+		rs.Codes[k] = v
+	}
+	rs.Errors = make(map[string]int64, len(c.errors))
+	for k, v := range c.errors {
 		rs.Errors[k] = v
 	}
 
@@ -237,23 +1741,290 @@ func (s *StreamReport) Snapshot() *SnapshotReport {
 		rs.Percentiles[i] = &struct {
 			Percentile float64
 			Latency    time.Duration
-		}{p, time.Duration(s.latencyQuantile.Query(p))}
+		}{p, time.Duration(c.percentiles[i])}
+	}
+
+	if c.queueStats.count > 0 {
+		rs.QueueStats = &struct {
+			Min    time.Duration
+			Mean   time.Duration
+			StdDev time.Duration
+			Max    time.Duration
+		}{time.Duration(c.queueStats.min), time.Duration(c.queueStats.Mean()),
+			time.Duration(c.queueStats.Stddev()), time.Duration(c.queueStats.max)}
 	}
 
-	hisBins := s.latencyHistogram.Bins()
-	rs.Histograms = make([]*struct {
+	if c.serverStats.count > 0 {
+		rs.ServerStats = &struct {
+			Min    time.Duration
+			Mean   time.Duration
+			StdDev time.Duration
+			Max    time.Duration
+		}{time.Duration(c.serverStats.min), time.Duration(c.serverStats.Mean()),
+			time.Duration(c.serverStats.Stddev()), time.Duration(c.serverStats.max)}
+		rs.OverheadStats = &struct {
+			Min    time.Duration
+			Mean   time.Duration
+			StdDev time.Duration
+			Max    time.Duration
+		}{time.Duration(c.overheadStats.min), time.Duration(c.overheadStats.Mean()),
+			time.Duration(c.overheadStats.Stddev()), time.Duration(c.overheadStats.max)}
+		for i, p := range quantiles {
+			rs.OverheadPercentiles = append(rs.OverheadPercentiles, &struct {
+				Percentile float64
+				Latency    time.Duration
+			}{p, time.Duration(c.overheadPercentiles[i])})
+		}
+	}
+
+	if c.tlsFull+c.tlsResumed > 0 {
+		rs.TLSHandshakes = &struct {
+			Full    int64
+			Resumed int64
+		}{c.tlsFull, c.tlsResumed}
+		for i, p := range quantiles {
+			rs.TLSPercentiles = append(rs.TLSPercentiles, &struct {
+				Percentile float64
+				Latency    time.Duration
+			}{p, time.Duration(c.tlsPercentiles[i])})
+		}
+	}
+
+	if len(c.cacheCounts) > 0 {
+		rs.Cache = c.cacheReport()
+	}
+	if len(c.followCodes) > 0 || c.followMissing > 0 {
+		rs.FollowUps = c.followUpReport()
+	}
+	if len(c.expects) > 0 {
+		rs.Continue = c.continueReport()
+	}
+	if c.split != nil {
+		rs.LatencySplit = c.split.report()
+	}
+	if c.perKBStats.count > 0 {
+		rs.PerKB = c.perKBReport()
+	}
+	if c.burnInStats != nil {
+		rs.BurnIn = c.burnInReport()
+	}
+	if c.ttfb != nil && c.ttfb.stats.count > 0 {
+		rs.TTFB = c.ttfb.report()
+		rs.TTLB = c.ttlb.report()
+	}
+	if c.firstEventStats != nil {
+		rs.Events = c.eventsReport(rs.RPS)
+	}
+	rs.Checks = c.checks
+	rs.Connections = c.connections
+	rs.Variants = c.variants
+
+	rs.Histograms = histogramReport(c.histogram)
+	return rs
+}
+
+func histogramReport(bins []histogram.Bin) []*struct {
+	Mean  time.Duration
+	Count int
+} {
+	report := make([]*struct {
 		Mean  time.Duration
 		Count int
-	}, len(hisBins))
-	for i, b := range hisBins {
-		rs.Histograms[i] = &struct {
+	}, len(bins))
+	for i := range bins {
+		b := &bins[i]
+		report[i] = &struct {
 			Mean  time.Duration
 			Count int
 		}{time.Duration(b.Mean()), b.Count}
 	}
+	return report
+}
 
-	s.lock.Unlock()
-	return rs
+func (c *reportCopy) perKBReport() *PerKBReport {
+	pr := &PerKBReport{
+		Size: &struct {
+			Min    float64
+			Mean   float64
+			StdDev float64
+			Max    float64
+		}{c.sizeStats.min, c.sizeStats.Mean(), c.sizeStats.Stddev(), c.sizeStats.max},
+		Latency: &struct {
+			Min    time.Duration
+			Mean   time.Duration
+			StdDev time.Duration
+			Max    time.Duration
+		}{time.Duration(c.perKBStats.min), time.Duration(c.perKBStats.Mean()),
+			time.Duration(c.perKBStats.Stddev()), time.Duration(c.perKBStats.max)},
+	}
+	for i, p := range quantiles {
+		pr.Percentiles = append(pr.Percentiles, &struct {
+			Percentile float64
+			Latency    time.Duration
+		}{p, time.Duration(c.perKBPercentiles[i])})
+	}
+	return pr
+}
+
+// worstConnectionReports gives rc the connections with the most errors, then
+// the highest P99 latency, once queried. It must be called with the lock held.
+func (s *StreamReport) worstConnectionReports(rc *reportCopy) {
+	reports := make([]*ConnectionReport, 0, len(s.connections))
+	for key, c := range s.connections {
+		id := "#" + strconv.FormatInt(key.id, 10)
+		if key.worker != "" {
+			id = key.worker + " " + id
+		}
+		r := &ConnectionReport{
+			ID:     id,
+			IP:     c.ip,
+			Count:  c.count,
+			Errors: c.errors,
+			Mean:   time.Duration(c.latency.Mean()),
+			Max:    time.Duration(c.latency.max),
+		}
+		rc.queryLater(c.quantile, connectionTarget, func(q *quantileCopy) {
+			r.P99 = time.Duration(q.Query(0.99))
+		})
+		reports = append(reports, r)
+	}
+	rc.queries = append(rc.queries, func() { rc.connections = worstConnections(reports, s.worstConnections) })
+}
+
+func worstConnections(reports []*ConnectionReport, n int) []*ConnectionReport {
+	sort.Slice(reports, func(i, j int) bool {
+		a, b := reports[i], reports[j]
+		if a.Errors != b.Errors {
+			return a.Errors > b.Errors
+		}
+		if a.P99 != b.P99 {
+			return a.P99 > b.P99
+		}
+		return a.ID < b.ID
+	})
+	if len(reports) > n {
+		reports = reports[:n]
+	}
+	return reports
+}
+
+func (c *reportCopy) eventsReport(rps float64) *EventsReport {
+	stats := c.firstEventStats
+	er := &EventsReport{
+		Streams: stats.count,
+		FirstEvent: &struct {
+			Min    time.Duration
+			Mean   time.Duration
+			StdDev time.Duration
+			Max    time.Duration
+		}{time.Duration(stats.min), time.Duration(stats.Mean()),
+			time.Duration(stats.Stddev()), time.Duration(stats.max)},
+	}
+	if c.concurrency > 0 {
+		er.PerConn = rps / float64(c.concurrency)
+	}
+	return er
+}
+
+func (c *reportCopy) burnInReport() *BurnInReport {
+	stats := c.burnInStats
+	br := &BurnInReport{
+		Count: stats.count,
+		Stats: &struct {
+			Min    time.Duration
+			Mean   time.Duration
+			StdDev time.Duration
+			Max    time.Duration
+		}{time.Duration(stats.min), time.Duration(stats.Mean()),
+			time.Duration(stats.Stddev()), time.Duration(stats.max)},
+	}
+	for i, p := range c.burnInPercentiles {
+		br.Percentiles = append(br.Percentiles, &struct {
+			Percentile float64
+			Latency    time.Duration
+		}{quantiles[i], time.Duration(p)})
+	}
+	return br
+}
+
+func (c *reportCopy) followUpReport() *FollowUpReport {
+	fr := &FollowUpReport{Codes: c.followCodes, Missing: c.followMissing}
+	if c.followStats.count > 0 {
+		fr.FirstLeg = &struct {
+			Min    time.Duration
+			Mean   time.Duration
+			StdDev time.Duration
+			Max    time.Duration
+		}{time.Duration(c.firstLegStats.min), time.Duration(c.firstLegStats.Mean()),
+			time.Duration(c.firstLegStats.Stddev()), time.Duration(c.firstLegStats.max)}
+		fr.FollowUp = &struct {
+			Min    time.Duration
+			Mean   time.Duration
+			StdDev time.Duration
+			Max    time.Duration
+		}{time.Duration(c.followStats.min), time.Duration(c.followStats.Mean()),
+			time.Duration(c.followStats.Stddev()), time.Duration(c.followStats.max)}
+	}
+	return fr
+}
+
+func (c *reportCopy) continueReport() *ContinueReport {
+	cr := &ContinueReport{Outcomes: c.expects}
+	if c.continueStats.count > 0 {
+		cr.Wait = &struct {
+			Min    time.Duration
+			Mean   time.Duration
+			StdDev time.Duration
+			Max    time.Duration
+		}{time.Duration(c.continueStats.min), time.Duration(c.continueStats.Mean()),
+			time.Duration(c.continueStats.Stddev()), time.Duration(c.continueStats.max)}
+	}
+	return cr
+}
+
+func (c *reportCopy) cacheReport() *CacheReport {
+	cr := &CacheReport{}
+	if served := c.cacheCounts["hit"] + c.cacheCounts["miss"]; served > 0 {
+		cr.HitRatio = float64(c.cacheCounts["hit"]) / float64(served)
+	}
+	for _, status := range cacheStatuses {
+		count, ok := c.cacheCounts[status]
+		if !ok {
+			continue
+		}
+		stats := c.cacheLatency[status]
+		sr := &CacheStatusReport{
+			Status: status,
+			Count:  count,
+			Mean:   time.Duration(stats.Mean()),
+			Max:    time.Duration(stats.max),
+		}
+		if bins, ok := c.cacheHistograms[status]; ok {
+			sr.Histograms = histogramReport(bins)
+		}
+		cr.Statuses = append(cr.Statuses, sr)
+	}
+	if c.ageStats.count > 0 {
+		// ages are whole seconds
+		cr.Age = &struct {
+			Min    time.Duration
+			Mean   time.Duration
+			StdDev time.Duration
+			Max    time.Duration
+		}{time.Duration(c.ageStats.min), time.Duration(c.ageStats.Mean()).Round(time.Second),
+			time.Duration(c.ageStats.Stddev()).Round(time.Second), time.Duration(c.ageStats.max)}
+	}
+	for v, count := range c.cacheControls {
+		cr.CacheControls = append(cr.CacheControls, &CacheControlReport{Value: v, Count: count})
+	}
+	sort.Slice(cr.CacheControls, func(i, j int) bool {
+		a, b := cr.CacheControls[i], cr.CacheControls[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Value < b.Value
+	})
+	return cr
 }
 
 func (s *StreamReport) Done() <-chan struct{} {
@@ -261,21 +2032,151 @@ func (s *StreamReport) Done() <-chan struct{} {
 }
 
 type ChartsReport struct {
+	Time    time.Time
 	RPS     float64
 	Latency Stats
+	// Percentiles are the chartPercentiles of the latency
+	Percentiles []float64 `json:",omitempty"`
+	// Codes are the rates of the responses by status class, like 2xx
+	Codes map[string]float64 `json:",omitempty"`
+	// Heatmap counts the latencies of the second by the buckets of
+	// heatmapBounds
+	Heatmap []int64 `json:",omitempty"`
+	// Errors is the rate of errors, and the throughputs are in MB/s
+	Errors          float64     `json:",omitempty"`
+	ReadThroughput  float64     `json:",omitempty"`
+	WriteThroughput float64     `json:",omitempty"`
+	Target          *LoadTarget `json:",omitempty"`
+	// LatencySplit is set along with the one of the snapshots
+	LatencySplit *LatencySplit `json:",omitempty"`
+	// TTFB and TTLB are set with '--report-ttfb', nil for the seconds
+	// without a response
+	TTFB *Stats `json:",omitempty"`
+	TTLB *Stats `json:",omitempty"`
+	// Endpoints are the P99 latencies of the endpoints requested within the
+	// second, with '--group-by' or 'replay'
+	Endpoints map[string]float64 `json:",omitempty"`
+}
+
+// endpoint returns the P99 latency of the endpoint within the second, false
+// if it wasn't requested.
+func (cr *ChartsReport) endpoint(endpoint string) (float64, bool) {
+	if cr == nil {
+		return 0, false
+	}
+	v, ok := cr.Endpoints[endpoint]
+	return v, ok
 }
 
 func (s *StreamReport) Charts() *ChartsReport {
 	s.lock.Lock()
 	var cr *ChartsReport
-	if s.noDateWithinSec {
-		cr = nil
-	} else {
-		cr = &ChartsReport{
-			RPS:     s.rpsWithinSec,
-			Latency: *s.latencyWithinSec,
-		}
+	if !s.noDateWithinSec && s.chartsWithinSec != nil {
+		c := *s.chartsWithinSec
+		c.Time = time.Now()
+		cr = &c
 	}
 	s.lock.Unlock()
 	return cr
 }
+
+func (s *StreamReport) ChartsHistory() []*ChartsReport {
+	s.lock.Lock()
+	history := make([]*ChartsReport, len(s.chartsHistory))
+	copy(history, s.chartsHistory)
+	s.lock.Unlock()
+	return history
+}
+
+// SavedReport is the JSON document written by '--json' and read back by 'plow serve',
+// 'report' and 'compare'.
+type SavedReport struct {
+	Description string
+	// RunID and Started are set on the runs saved to '--history'
+	RunID   string    `json:",omitempty"`
+	Started time.Time `json:",omitempty"`
+	Summary *SnapshotReport
+	Charts  []*ChartsReport
+	// ChartPercentiles are those of the Percentiles of Charts
+	ChartPercentiles []float64 `json:",omitempty"`
+}
+
+func (s *StreamReport) saved(desc string) *SavedReport {
+	return &SavedReport{
+		Description:      desc,
+		Summary:          s.Snapshot(),
+		Charts:           s.ChartsHistory(),
+		ChartPercentiles: chartPercentiles,
+	}
+}
+
+func writeReport(fileName string, saved *SavedReport) error {
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, data, 0644)
+}
+
+func (s *StreamReport) Save(fileName string, desc string) error {
+	return writeReport(fileName, s.saved(desc))
+}
+
+func LoadReport(fileName string) (*SavedReport, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var saved SavedReport
+	if err = json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+	if saved.Summary == nil {
+		return nil, fmt.Errorf("%s: no summary found in report", fileName)
+	}
+	return &saved, nil
+}
+
+// CooldownReport aggregates the probes sent during '--cooldown' into
+// consecutive time slices, showing how the latency recovers after the load.
+type CooldownReport struct {
+	duration time.Duration
+	slice    time.Duration
+	Slices   []*CooldownSlice
+}
+
+type CooldownSlice struct {
+	Start   time.Duration
+	Latency Stats
+	Errors  int64
+}
+
+func NewCooldownReport(duration time.Duration) *CooldownReport {
+	slice := (duration / 10).Truncate(time.Second)
+	if slice < time.Second {
+		slice = time.Second
+	}
+	return &CooldownReport{duration: duration, slice: slice}
+}
+
+// Collect puts the probes in the slices of the time they were sent at, not
+// of when they are collected, after the report of the run.
+func (c *CooldownReport) Collect(probes <-chan *cooldownProbe) {
+	for p := range probes {
+		r, elapsed := p.record, p.at
+		if elapsed >= c.duration {
+			elapsed = c.duration - 1
+		}
+		idx := int(elapsed / c.slice)
+		for len(c.Slices) <= idx {
+			c.Slices = append(c.Slices, &CooldownSlice{Start: time.Duration(len(c.Slices)) * c.slice})
+		}
+		cs := c.Slices[idx]
+		if r.error != "" {
+			cs.Errors++
+		} else {
+			cs.Latency.Update(float64(r.cost))
+		}
+		recordPool.Put(r)
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"crypto/tls"
 	"fmt"
 	"github.com/valyala/fasthttp"
-	"github.com/valyala/fasthttp/fasthttpproxy"
 	"go.uber.org/automaxprocs/maxprocs"
+	"golang.org/x/net/http/httpproxy"
+	"io"
 	"io/ioutil"
+	"math/rand"
 	"net"
 	url2 "net/url"
 	"os"
@@ -17,6 +19,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"syscall"
+	"text/template"
 	"time"
 )
 
@@ -25,12 +28,93 @@ var (
 	sendOnCloseError interface{}
 )
 
+const cooldownProbeInterval = 100 * time.Millisecond
+
+// prewarmParallelism is the number of connections '--prewarm' opens at once.
+const prewarmParallelism = 64
+
 type ReportRecord struct {
 	cost       time.Duration
 	code       string
 	error      string
 	readBytes  int64
 	writeBytes int64
+
+	decodedReadBytes  int64
+	decodedWriteBytes int64
+
+	conn ConnTimings
+	// connID is the number of the connection of the request, 0 if none
+	connID int64
+	// variant is A or B with '--url-b', the url the request went to
+	variant string
+
+	host      string
+	ip        string
+	hostBusy  int64
+	hostConns int64
+
+	affinityChecked bool
+	affinityBroken  bool
+
+	// serverTime is the processing time reported by the server, -1 if none
+	serverTime time.Duration
+
+	redirect redirectKey
+	hsts     string
+
+	violations []string
+
+	// queue is the time from when the request was due by the rate of
+	// '--schedule' until it was written, -1 without a rate
+	queue time.Duration
+
+	// body is the name of the body of '--body-weighted' sent, if any
+	body string
+	// endpoint is the group of the request by '--group-by', or its path
+	// without the query with 'replay', if any
+	endpoint string
+	// worker is the worker of the controller which sent the request, if any
+	worker string
+
+	// cache is the cache status of the response with '--report-cache', along
+	// with its age, -1 if none, and its Cache-Control header
+	cache        string
+	age          time.Duration
+	cacheControl string
+
+	// follow is the time of the follow-up request of '--follow-header' part
+	// of the cost, -1 if none was sent, and followCode its status class.
+	// followMissing tells the response lacked the header to follow.
+	follow        time.Duration
+	followCode    string
+	followMissing bool
+
+	// size is the size of the response body with '--per-kb', -1 if none
+	size int64
+
+	// checksRun and checksFailed are the bits of the checks of '--check'
+	// attached to the URL of the request, and of those it failed
+	checksRun    uint64
+	checksFailed uint64
+
+	// firstEvent tells the record is the first event of a stream of '--sse',
+	// its cost being the time to it from the request
+	firstEvent bool
+
+	// expect is how the server took the Expect: 100-continue of
+	// '--expect-continue', "" without, and continueWait the time to its
+	// 100 Continue, -1 if none came
+	expect       string
+	continueWait time.Duration
+
+	// ttfb and ttlb are the times to the first and to the last byte of the
+	// response with '--report-ttfb', -1 if none
+	ttfb time.Duration
+	ttlb time.Duration
+
+	// requestID is the ID sent in the header of '--request-id-header', if any
+	requestID string
 }
 
 var recordPool = sync.Pool{
@@ -90,42 +174,188 @@ func ThroughputInterceptorDial(dial fasthttp.DialFunc, r *int64, w *int64) fasth
 
 type Requester struct {
 	concurrency int
+	// workerIndex offsets the indexes of the connections of a worker of a
+	// controller among the workerCount connections of all workers, 0 for a
+	// run of its own
+	workerIndex int64
+	workerCount int64
 	requests    int64
 	duration    time.Duration
 	clientOpt   *ClientOpt
-	httpClient  *fasthttp.HostClient
-	httpHeader  *fasthttp.RequestHeader
+	targets     []*target
+
+	workersLock sync.Mutex
+	workers     []*worker
+	// warm are the workers of '--warmup' in order, which the run goes on with
+	warm []*worker
+	// clients are those of all workers, to close their idle connections
+	clients []idleCloser
+	// pipelines are the clients shared by the workers with '--pipeline'
+	pipelines map[pipelineKey]*pipelineClient
 
-	recordChan chan *ReportRecord
-	closeOnce  sync.Once
-	wg         sync.WaitGroup
+	recordChan   chan *ReportRecord
+	cooldownChan chan *cooldownProbe
+	closeOnce    sync.Once
+	// exited is closed once the requests in flight are done
+	exited chan struct{}
+	wg     sync.WaitGroup
 
 	readBytes  int64
 	writeBytes int64
 
+	decodedReadBytes  int64
+	decodedWriteBytes int64
+
+	// body holds the *requestBody sent, unless weighted bodies are
+	body   atomic.Value
+	bodies []weightedRequestBody
+	// bodiesWeight is the sum of the weights of bodies
+	bodiesWeight int
+	// requestCount numbers the requests rendered by '--template'
+	requestCount int64
+	// hostCount numbers the requests sent with the hosts of '--host'
+	hostCount int64
+
+	gate    *ConcurrencyGate
+	limiter *RateLimiter
+
+	resources *resourceTracker
+	// followClient sends the follow-up requests of '--follow-header'
+	followClient *fasthttp.Client
+
+	// failure is the first failed request of '--stop-on-error', "" until one
+	failureLock sync.Mutex
+	failure     string
+	// sampled counts the requests printed by '--sample', samplesDone being
+	// closed once they all are
+	sampled     int64
+	samplesDone chan struct{}
+
 	cancel func()
 }
 
 type ClientOpt struct {
-	url       string
-	method    string
-	headers   []string
-	bodyBytes []byte
-	bodyFile  string
-
-	certPath string
-	keyPath  string
-	insecure bool
-
-	maxConns     int
-	doTimeout    time.Duration
-	readTimeout  time.Duration
-	writeTimeout time.Duration
-	dialTimeout  time.Duration
+	urls       []string
+	method     string
+	headers    []string
+	bodyBytes  []byte
+	bodyStream func() (io.Reader, error)
+
+	bodyPath   string
+	bodyReload time.Duration
+	// randomBodySize is the size of the random body of every request of
+	// '--body-size SIZE:random', 0 without
+	randomBodySize int
+
+	compressBody string
+	decompress   bool
+
+	certPath      string
+	keyPath       string
+	certPass      string
+	insecure      bool
+	tlsResumption bool
+	sni           string
+
+	doTimeout     time.Duration
+	readTimeout   time.Duration
+	writeTimeout  time.Duration
+	phaseTimeouts PhaseTimeouts
 
 	socks5Proxy string
+	socks5User  string
+	socks5Pass  string
+	httpProxy   string
+	unixSocket  string
+	resolve     []string
+	localAddrs  []string
+	ipVersion   int
+	dnsServer   string
+	dnsRefresh  time.Duration
 	contentType string
-	host        string
+	// hosts are the Host headers of '--host', rotated over the requests if
+	// more than one
+	hosts []string
+
+	wireCapture *WireCapture
+	errorLog    *ErrorLog
+	// stopOnError stops the run at the first failed request
+	stopOnError bool
+	// replay paces the requests of 'replay', sending those of its access log
+	replay *replayer
+	// groupBy groups the requests by endpoint with '--group-by'
+	groupBy *EndpointGrouper
+	// sample is the number of the first requests printed with their responses
+	sample int
+
+	maxConnsPerHost int
+	ipSpread        string
+
+	affinityHeader string
+	latencyHeader  string
+	// requestIDHeader is set to a fresh UUID on every request, if given
+	requestIDHeader string
+
+	templateFuncs template.FuncMap
+	templateRun   *templateRun
+	templateVars  map[string]string
+
+	strict bool
+
+	disableKeepAlive bool
+	maxConnRequests  int
+	reportRedirects  bool
+	reportCache      bool
+	perKB            bool
+
+	followHeader string
+	followMethod string
+
+	checks []*Check
+
+	// pipeline is the number of workers sharing a connection, sending their
+	// requests without waiting for the responses of the others
+	pipeline int
+
+	// expectContinue holds the bodies back until the 100 Continue, or for
+	// expectTimeout, see continueClient
+	expectContinue bool
+	expectTimeout  time.Duration
+
+	reportTTFB bool
+
+	// abSplit is the percent of the requests sent to the second url, B, of
+	// '--url-b', 0 without
+	abSplit int
+
+	// sse makes the requests event streams, see streamEvents
+	sse bool
+
+	// idleConnTimeout is how long the connections are kept idle, the default
+	// of fasthttp if 0, see maxIdleConnDuration
+	idleConnTimeout time.Duration
+
+	weightedBodies []*WeightedBody
+
+	thinkTime ThinkTime
+
+	warmup   time.Duration
+	cooldown time.Duration
+
+	schedule Schedule
+	arrival  string
+	// control lets the run be adjusted while running, see RunControl
+	control bool
+}
+
+// maxIdleConnDuration is how long the clients keep their connections idle,
+// those of the load phase being kept around for the probes of '--cooldown'.
+func (o *ClientOpt) maxIdleConnDuration() time.Duration {
+	d := o.idleConnTimeout
+	if d <= 0 {
+		d = fasthttp.DefaultMaxIdleConnDuration
+	}
+	return d + o.cooldown
 }
 
 func NewRequester(concurrency int, requests int64, duration time.Duration, clientOpt *ClientOpt) (*Requester, error) {
@@ -139,16 +369,131 @@ func NewRequester(concurrency int, requests int64, duration time.Duration, clien
 		duration:    duration,
 		clientOpt:   clientOpt,
 		recordChan:  make(chan *ReportRecord, maxResult),
+		resources:   newResourceTracker(),
+		exited:      make(chan struct{}),
+		samplesDone: make(chan struct{}),
 	}
-	client, header, err := buildRequestClient(clientOpt, &r.readBytes, &r.writeBytes)
-	if err != nil {
+	r.resources.idleTimeout = clientOpt.maxIdleConnDuration()
+	if clientOpt.cooldown > 0 {
+		// room for every probe, so that none waits for the report
+		r.cooldownChan = make(chan *cooldownProbe, clientOpt.cooldown/cooldownProbeInterval+1)
+	}
+	if clientOpt.followHeader != "" {
+		var err error
+		if r.followClient, err = r.newFollowClient(); err != nil {
+			return nil, err
+		}
+	}
+	if len(clientOpt.schedule) > 0 {
+		r.concurrency = clientOpt.schedule.MaxConcurrency()
+		r.gate = NewConcurrencyGate(0)
+		r.limiter = NewRateLimiter(clientOpt.arrival)
+	} else if clientOpt.control {
+		r.gate = NewConcurrencyGate(concurrency)
+		r.limiter = NewRateLimiter(clientOpt.arrival)
+	}
+	hosts := make(map[string]*hostPool)
+	for _, u := range clientOpt.urls {
+		addr, dial, header, err := buildRequestClient(clientOpt, u, &r.readBytes, &r.writeBytes)
+		if err != nil {
+			return nil, err
+		}
+		host, ok := hosts[addr]
+		if !ok {
+			host = newHostPool(addr, clientOpt.maxConnsPerHost)
+			hosts[addr] = host
+		}
+		base, _ := url2.Parse(u)
+		base.Host = string(header.Host())
+		var tmpl *RequestTemplate
+		if clientOpt.templateFuncs != nil {
+			tmpl, err = NewRequestTemplate(rawRequestURI(u), header, clientOpt.templateFuncs)
+			if err != nil {
+				return nil, err
+			}
+		}
+		r.targets = append(r.targets, &target{url: u, base: base, dial: dial, header: header, host: host, tmpl: tmpl,
+			checks: checkMask(clientOpt.checks, u)})
+	}
+	if clientOpt.abSplit > 0 {
+		r.targets[0].variant, r.targets[1].variant = "A", "B"
+	}
+	if err := r.setBody(clientOpt.bodyBytes); err != nil {
 		return nil, err
 	}
-	r.httpClient = client
-	r.httpHeader = header
+	for _, wb := range clientOpt.weightedBodies {
+		b, err := r.newRequestBody(wb.Name, wb.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		r.bodies = append(r.bodies, weightedRequestBody{name: wb.Name, weight: wb.Weight, body: b})
+		r.bodiesWeight += wb.Weight
+	}
+	if clientOpt.compressBody != "" && clientOpt.bodyStream != nil {
+		clientOpt.bodyStream = compressStream(clientOpt.compressBody, clientOpt.bodyStream, &r.decodedWriteBytes)
+	}
 	return r, nil
 }
 
+// requestBody is a body as sent, compressed with '--compress-body'.
+type requestBody struct {
+	bytes []byte
+	// tmpl renders the body for every request with '--template', or nil
+	tmpl    *template.Template
+	rawSize int64
+}
+
+// weightedRequestBody is one of the bodies of '--body-weighted'.
+type weightedRequestBody struct {
+	name   string
+	weight int
+	body   *requestBody
+}
+
+func (r *Requester) newRequestBody(name string, bodyBytes []byte) (*requestBody, error) {
+	b := &requestBody{rawSize: int64(len(bodyBytes))}
+	if r.clientOpt.templateFuncs != nil && hasTemplate(string(bodyBytes)) {
+		var err error
+		if b.tmpl, err = parseTemplate(name, string(bodyBytes), r.clientOpt.templateFuncs); err != nil {
+			return nil, err
+		}
+	}
+	if r.clientOpt.compressBody != "" && len(bodyBytes) > 0 {
+		var err error
+		bodyBytes, err = compressBytes(r.clientOpt.compressBody, bodyBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	b.bytes = bodyBytes
+	return b, nil
+}
+
+func (r *Requester) setBody(bodyBytes []byte) error {
+	b, err := r.newRequestBody("body", bodyBytes)
+	if err != nil {
+		return err
+	}
+	r.body.Store(b)
+	return nil
+}
+
+// pickBody picks the body of a request, one of the weighted bodies in
+// proportion to their weights if any. The name of a weighted body is returned.
+func (r *Requester) pickBody() (*requestBody, string) {
+	if len(r.bodies) == 0 {
+		return r.body.Load().(*requestBody), ""
+	}
+	n := rand.Intn(r.bodiesWeight)
+	for _, wb := range r.bodies {
+		if n < wb.weight {
+			return wb.body, wb.name
+		}
+		n -= wb.weight
+	}
+	return nil, ""
+}
+
 func addMissingPort(addr string, isTLS bool) string {
 	n := strings.Index(addr, ":")
 	if n >= 0 {
@@ -163,55 +508,100 @@ func addMissingPort(addr string, isTLS bool) string {
 
 func buildTLSConfig(opt *ClientOpt) (*tls.Config, error) {
 	var certs []tls.Certificate
-	if opt.certPath != "" && opt.keyPath != "" {
-		c, err := tls.LoadX509KeyPair(opt.certPath, opt.keyPath)
+	if opt.certPath != "" && (opt.keyPath != "" || isPKCS12(opt.certPath)) {
+		c, err := loadClientCert(opt.certPath, opt.keyPath, opt.certPass)
 		if err != nil {
 			return nil, err
 		}
 		certs = append(certs, c)
 	}
-	return &tls.Config{
+	cfg := &tls.Config{
 		InsecureSkipVerify: opt.insecure,
 		Certificates:       certs,
-	}, nil
+	}
+	if opt.tlsResumption {
+		// the sessions are shared by the connections of all workers
+		cfg.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	} else {
+		cfg.SessionTicketsDisabled = true
+	}
+	return cfg, nil
 }
 
-func buildRequestClient(opt *ClientOpt, r *int64, w *int64) (*fasthttp.HostClient, *fasthttp.RequestHeader, error) {
-	u, err := url2.Parse(opt.url)
+// buildRequestClient returns the address and the dial builder shared by the
+// HostClients of all workers for the url, along with its request header template.
+func buildRequestClient(opt *ClientOpt, rawURL string, r *int64, w *int64) (string, DialBuilder, *fasthttp.RequestHeader, error) {
+	u, err := url2.Parse(rawURL)
 	if err != nil {
-		return nil, nil, err
+		return "", nil, nil, err
 	}
-	httpClient := &fasthttp.HostClient{
-		Addr:                          addMissingPort(u.Host, u.Scheme == "https"),
-		IsTLS:                         u.Scheme == "https",
-		Name:                          "plow",
-		MaxConns:                      opt.maxConns,
-		ReadTimeout:                   opt.readTimeout,
-		WriteTimeout:                  opt.writeTimeout,
-		DisableHeaderNamesNormalizing: true,
+	isTLS := u.Scheme == "https"
+	proxy, err := proxyURL(opt, u)
+	if err != nil {
+		return "", nil, nil, err
 	}
+	local, err := parseLocalAddrs(opt.localAddrs)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	var socksDial fasthttp.DialFunc
 	if opt.socks5Proxy != "" {
-		if !strings.Contains(opt.socks5Proxy, "://") {
-			opt.socks5Proxy = "socks5://" + opt.socks5Proxy
+		socksDial, err = buildSocksDial(opt, local)
+		if err != nil {
+			return "", nil, nil, err
 		}
-		httpClient.Dial = fasthttpproxy.FasthttpSocksDialer(opt.socks5Proxy)
-	} else {
-		httpClient.Dial = fasthttpproxy.FasthttpProxyHTTPDialerTimeout(opt.dialTimeout)
 	}
-	httpClient.Dial = ThroughputInterceptorDial(httpClient.Dial, r, w)
-
-	tlsConfig, err := buildTLSConfig(opt)
+	resolve, err := parseResolve(opt.resolve)
 	if err != nil {
-		return nil, nil, err
+		return "", nil, nil, err
+	}
+	resolver := NewResolver(opt.dnsServer, opt.dnsRefresh, opt.ipSpread == "random", opt.ipVersion)
+	var tlsConfig *tls.Config
+	if isTLS {
+		tlsConfig, err = buildTLSConfig(opt)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		tlsConfig.ServerName = u.Hostname()
+		if opt.sni != "" {
+			tlsConfig.ServerName = opt.sni
+		}
+	}
+
+	dialBuilder := func(t *connTracker) fasthttp.DialFunc {
+		var dial fasthttp.DialFunc
+		switch {
+		case opt.unixSocket != "":
+			dial = ConnectDial(UnixDial(opt.unixSocket, opt.phaseTimeouts.connect), t)
+		case opt.socks5Proxy != "":
+			dial = ConnectDial(socksDial, t)
+		case proxy != nil:
+			dial = ProxyDial(proxy, opt.phaseTimeouts.connect, local, opt.ipVersion, t)
+		default:
+			dial = resolver.Dial(ConnectDial(DirectDial(opt.phaseTimeouts.connect, local, opt.ipVersion), t), t)
+		}
+		if len(resolve) > 0 {
+			dial = ResolveDial(dial, resolve)
+		}
+		dial = ThroughputInterceptorDial(dial, r, w)
+		if tlsConfig != nil {
+			dial = TLSDial(dial, tlsConfig, opt.phaseTimeouts.tls, t)
+		}
+		if opt.strict {
+			dial = StrictDial(dial, t)
+		}
+		if opt.wireCapture != nil {
+			dial = opt.wireCapture.Dial(dial)
+		}
+		return t.Dial(dial, &opt.phaseTimeouts)
 	}
-	httpClient.TLSConfig = tlsConfig
 
 	var requestHeader fasthttp.RequestHeader
 	if opt.contentType != "" {
 		requestHeader.SetContentType(opt.contentType)
 	}
-	if opt.host != "" {
-		requestHeader.SetHost(opt.host)
+	if len(opt.hosts) > 0 {
+		requestHeader.SetHost(opt.hosts[0])
 	} else {
 		requestHeader.SetHost(u.Host)
 	}
@@ -220,35 +610,542 @@ func buildRequestClient(opt *ClientOpt, r *int64, w *int64) (*fasthttp.HostClien
 	for _, h := range opt.headers {
 		n := strings.SplitN(h, ":", 2)
 		if len(n) != 2 {
-			return nil, nil, fmt.Errorf("invalid header: %s", h)
+			return "", nil, nil, fmt.Errorf("invalid header: %s", h)
 		}
 		requestHeader.Set(n[0], n[1])
 	}
+	if opt.compressBody != "" {
+		requestHeader.Set(fasthttp.HeaderContentEncoding, opt.compressBody)
+	}
+	if opt.decompress && len(requestHeader.Peek(fasthttp.HeaderAcceptEncoding)) == 0 {
+		requestHeader.Set(fasthttp.HeaderAcceptEncoding, strings.Join(compressEncodings, ", "))
+	}
+	if opt.disableKeepAlive {
+		// the client closes the connection after the response, so that every
+		// request connects and handshakes again
+		requestHeader.SetConnectionClose()
+	}
+
+	return addMissingPort(u.Host, isTLS), dialBuilder, &requestHeader, nil
+}
+
+// buildSocksDial returns the dial func of '--socks5', whose credentials are
+// either in the url or given by '--socks5-user' and '--socks5-pass'.
+func buildSocksDial(opt *ClientOpt, local *LocalAddrs) (fasthttp.DialFunc, error) {
+	proxy := opt.socks5Proxy
+	if !strings.Contains(proxy, "://") {
+		proxy = "socks5://" + proxy
+	}
+	u, err := url2.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+	if opt.socks5User != "" {
+		u.User = url2.UserPassword(opt.socks5User, opt.socks5Pass)
+	}
+	return SocksDial(u, opt.phaseTimeouts.connect, local)
+}
+
+// proxyURL returns the HTTP(S) proxy to tunnel through: '--proxy' if given,
+// else the one HTTP_PROXY, HTTPS_PROXY and NO_PROXY choose for the url.
+func proxyURL(opt *ClientOpt, u *url2.URL) (*url2.URL, error) {
+	if opt.unixSocket != "" || opt.socks5Proxy != "" {
+		return nil, nil
+	}
+	if opt.httpProxy == "" {
+		return httpproxy.FromEnvironment().ProxyFunc()(u)
+	}
+	proxy := opt.httpProxy
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	pu, err := url2.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+	if pu.Scheme != "http" && pu.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported proxy scheme: %s", pu.Scheme)
+	}
+	return pu, nil
+}
+
+// target is one of the urls to benchmark, workers send their requests to all
+// targets in turn.
+type target struct {
+	url string
+	// base is the URL with the Host header which redirects are resolved against
+	base   *url2.URL
+	dial   DialBuilder
+	header *fasthttp.RequestHeader
+	host   *hostPool
+	tmpl   *RequestTemplate
+	// checks are the bits of the checks attached to the URL
+	checks uint64
+	// variant is A or B with '--url-b'
+	variant string
+}
+
+// worker is a virtual client, owning a HostClient with one connection at most
+// for every target. It has one request in flight at most, so whatever happens
+// on a connection can be attributed to its request.
+type worker struct {
+	targets []*workerTarget
+	next    int
+	// scope is the worker of the templates
+	scope *templateWorker
+	// requests is the number of requests rendered by '--template' for the worker
+	requests int64
+	// due is when the request in flight was due by the rate limiter, if any
+	due time.Time
+	// replay is the request of the access log of 'replay' in flight, if any
+	replay *accessLogEntry
+	// randomBody is the body of '--body-size SIZE:random' filled from rand
+	// for every request
+	randomBody []byte
+	rand       *rand.Rand
+}
+
+// nextRandomBody fills the random body of the worker anew.
+func (w *worker) nextRandomBody(size int) []byte {
+	if w.randomBody == nil {
+		w.randomBody = make([]byte, size)
+		w.rand = rand.New(rand.NewSource(time.Now().UnixNano() + w.scope.Index))
+	}
+	w.rand.Read(w.randomBody)
+	return w.randomBody
+}
+
+type workerTarget struct {
+	client httpDoer
+	req    *fasthttp.Request
+	// conn is shared by the workers of a group with '--pipeline'
+	conn *connTracker
+
+	// backend is the last value of the affinity header seen on the connection
+	backend string
+	// connRequests is the number of requests sent on the connection
+	connRequests int
+
+	// dial opens the connections of the client, nil for the workers sharing
+	// the client of '--pipeline' opened by another
+	dial fasthttp.DialFunc
+	// prewarmed is the connection of '--prewarm' the client takes first
+	prewarmed net.Conn
+}
+
+// dialConn opens a connection of the client, handing out the one of
+// '--prewarm' first.
+func (wt *workerTarget) dialConn(addr string) (net.Conn, error) {
+	if conn := wt.prewarmed; conn != nil {
+		wt.prewarmed = nil
+		return conn, nil
+	}
+	return wt.dial(addr)
+}
+
+func (r *Requester) newWorker(i int) *worker {
+	w := &worker{
+		targets: make([]*workerTarget, len(r.targets)),
+		next:    i,
+		scope:   &templateWorker{Index: r.workerIndex + int64(i), Count: int64(r.concurrency)},
+	}
+	if r.workerCount > 0 {
+		w.scope.Count = r.workerCount
+	}
+	r.workersLock.Lock()
+	r.workers = append(r.workers, w)
+	r.workersLock.Unlock()
+	return w
+}
+
+// target returns the client and request of the worker for the i-th target,
+// creating them on first use.
+func (r *Requester) target(w *worker, i int) *workerTarget {
+	if wt := w.targets[i]; wt != nil {
+		return wt
+	}
+	t := r.targets[i]
+	wt := &workerTarget{req: &fasthttp.Request{}, conn: &connTracker{}}
+	t.header.CopyTo(&wt.req.Header)
+	if r.clientOpt.pipeline > 1 {
+		r.setPipelineClient(w, i, wt)
+		w.targets[i] = wt
+		return wt
+	}
+	wt.dial = t.host.Dial(r.resources.Dial(t.dial(wt.conn)))
+	if r.clientOpt.expectContinue {
+		client := &continueClient{
+			addr:          t.host.addr,
+			dial:          wt.dialConn,
+			tracker:       wt.conn,
+			readTimeout:   r.clientOpt.readTimeout,
+			writeTimeout:  r.clientOpt.writeTimeout,
+			expectTimeout: r.clientOpt.expectTimeout,
+			maxIdle:       r.clientOpt.maxIdleConnDuration(),
+		}
+		wt.client = client
+		w.targets[i] = wt
+		r.workersLock.Lock()
+		r.clients = append(r.clients, client)
+		r.workersLock.Unlock()
+		return wt
+	}
+	client := &fasthttp.HostClient{
+		Addr:                          t.host.addr,
+		Name:                          "plow",
+		MaxConns:                      1,
+		ReadTimeout:                   r.clientOpt.readTimeout,
+		WriteTimeout:                  r.clientOpt.writeTimeout,
+		DisableHeaderNamesNormalizing: true,
+		Dial:                          wt.dialConn,
+	}
+	if r.clientOpt.dnsRefresh > 0 {
+		// reconnect to pick up the addresses of the refreshed answers
+		client.MaxConnDuration = r.clientOpt.dnsRefresh
+	}
+	client.MaxIdleConnDuration = r.clientOpt.maxIdleConnDuration()
+	wt.client = client
+	w.targets[i] = wt
+	r.workersLock.Lock()
+	r.clients = append(r.clients, client)
+	r.workersLock.Unlock()
+	return wt
+}
 
-	return httpClient, &requestHeader, nil
+// pickTarget returns the index of the next target of the worker whose host
+// has a connection slot left, waiting for a slot of the next one if none has.
+// The slot must be released once the request is done.
+func (r *Requester) pickTarget(ctx context.Context, w *worker) (int, bool) {
+	if r.clientOpt.abSplit > 0 {
+		// A or B at random, in the proportion of the split
+		i := 0
+		if rand.Intn(100) < r.clientOpt.abSplit {
+			i = 1
+		}
+		return i, r.targets[i].host.acquire(ctx)
+	}
+	n := len(r.targets)
+	for k := 0; k < n; k++ {
+		i := (w.next + k) % n
+		if r.targets[i].host.tryAcquire() {
+			w.next = i + 1
+			return i, true
+		}
+	}
+	i := w.next % n
+	w.next = i + 1
+	return i, r.targets[i].host.acquire(ctx)
 }
 
 func (r *Requester) Cancel() {
 	r.cancel()
 }
 
+// Validate renders the templates of the urls, headers and bodies once, to find
+// the errors only showing when executed, like a missing key.
+func (r *Requester) Validate() error {
+	data := &templateData{
+		Run:    r.clientOpt.templateRun,
+		Worker: &templateWorker{Count: int64(r.concurrency)},
+		Vars:   r.clientOpt.templateVars,
+	}
+	req := &fasthttp.Request{}
+	for _, t := range r.targets {
+		if t.tmpl == nil {
+			continue
+		}
+		t.header.CopyTo(&req.Header)
+		if err := t.tmpl.Render(req, data); err != nil {
+			return err
+		}
+	}
+	bodies := []*requestBody{r.body.Load().(*requestBody)}
+	for _, wb := range r.bodies {
+		bodies = append(bodies, wb.body)
+	}
+	for _, b := range bodies {
+		if b.tmpl == nil {
+			continue
+		}
+		if _, err := executeTemplate(b.tmpl, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetRate changes the rate of the run, 0 for no limit, until the next step
+// of '--schedule' if any.
+func (r *Requester) SetRate(rate float64) error {
+	if r.limiter == nil {
+		return fmt.Errorf("the run can not be controlled")
+	}
+	if rate < 0 {
+		return fmt.Errorf("invalid rate %v", rate)
+	}
+	r.limiter.SetRate(rate)
+	return nil
+}
+
+// SetConcurrency changes the number of connections sending requests, up to
+// the one the run started with, until the next step of '--schedule' if any.
+func (r *Requester) SetConcurrency(n int) error {
+	if r.gate == nil {
+		return fmt.Errorf("the run can not be controlled")
+	}
+	if n < 1 || n > r.concurrency {
+		return fmt.Errorf("invalid concurrency %d, expected 1 to %d", n, r.concurrency)
+	}
+	r.gate.Set(n)
+	return nil
+}
+
+func (r *Requester) Pause(paused bool) error {
+	if r.gate == nil {
+		return fmt.Errorf("the run can not be controlled")
+	}
+	r.gate.Pause(paused)
+	return nil
+}
+
+func (r *Requester) Paused() bool {
+	return r.gate != nil && r.gate.Paused()
+}
+
 func (r *Requester) RecordChan() <-chan *ReportRecord {
 	return r.recordChan
 }
 
+// cooldownProbe is the record of a probe of '--cooldown', sent at since the
+// cooldown started.
+type cooldownProbe struct {
+	at     time.Duration
+	record *ReportRecord
+}
+
+// CooldownChan delivers the probes sent during '--cooldown'.
+func (r *Requester) CooldownChan() <-chan *cooldownProbe {
+	return r.cooldownChan
+}
+
 func (r *Requester) closeRecord() {
 	r.closeOnce.Do(func() {
 		close(r.recordChan)
 	})
 }
 
-func (r *Requester) DoRequest(req *fasthttp.Request, resp *fasthttp.Response, rr *ReportRecord) {
+// closeIdleConns closes the connections of the workers once the run is over,
+// rather than leaving them to the server.
+func (r *Requester) closeIdleConns() {
+	r.workersLock.Lock()
+	defer r.workersLock.Unlock()
+	for _, c := range r.clients {
+		c.CloseIdleConnections()
+	}
+	if r.followClient != nil {
+		r.followClient.CloseIdleConnections()
+	}
+}
+
+// Resources reports the peaks of connections and file descriptors so far, and
+// the sockets left closing once the run is over.
+func (r *Requester) Resources() *ResourceReport {
+	rr := r.resources.report()
+	select {
+	case <-r.exited:
+		rr.TimeWait = r.resources.countTimeWait()
+	default:
+	}
+	return rr
+}
+
+// Exited is closed once the requests in flight are done after the run.
+func (r *Requester) Exited() <-chan struct{} {
+	return r.exited
+}
+
+func (r *Requester) setRequestBody(req *fasthttp.Request, b *requestBody, data *templateData) error {
+	if r.clientOpt.bodyStream != nil {
+		bodyReader, err := r.clientOpt.bodyStream()
+		if err != nil {
+			return err
+		}
+		req.SetBodyStream(bodyReader, -1)
+		return nil
+	}
+	if b.tmpl != nil {
+		body, err := executeTemplate(b.tmpl, data)
+		if err != nil {
+			return err
+		}
+		if r.clientOpt.compressBody != "" {
+			atomic.AddInt64(&r.decodedWriteBytes, int64(len(body)))
+			if body, err = compressBytes(r.clientOpt.compressBody, body); err != nil {
+				return err
+			}
+		}
+		req.SetBodyRaw(body)
+		return nil
+	}
+	req.SetBodyRaw(b.bytes)
+	if r.clientOpt.compressBody != "" {
+		atomic.AddInt64(&r.decodedWriteBytes, b.rawSize)
+	}
+	return nil
+}
+
+func (r *Requester) DoRequest(w *worker, i int, resp *fasthttp.Response, rr *ReportRecord) {
+	t := r.targets[i]
+	wt := r.target(w, i)
+	req := wt.req
+	var respBody []byte
+	defer func() {
+		rr.host = t.host.addr
+		rr.hostBusy = atomic.LoadInt64(&t.host.busy)
+		rr.hostConns = atomic.LoadInt64(&t.host.conns)
+		rr.readBytes = atomic.LoadInt64(&r.readBytes)
+		rr.writeBytes = atomic.LoadInt64(&r.writeBytes)
+		rr.decodedReadBytes = atomic.LoadInt64(&r.decodedReadBytes)
+		rr.decodedWriteBytes = atomic.LoadInt64(&r.decodedWriteBytes)
+		rr.conn = wt.conn.takeTimings()
+		rr.ip = wt.conn.remoteIP()
+		rr.connID = wt.conn.connID()
+		rr.variant = t.variant
+		rr.violations = wt.conn.takeViolations()
+		// the requests which failed fail their checks
+		rr.checksRun = t.checks
+		if rr.error != "" {
+			rr.checksFailed = t.checks
+		}
+		if r.clientOpt.errorLog != nil {
+			if rr.error != "" {
+				r.clientOpt.errorLog.Log(req, nil, nil, rr)
+			} else {
+				r.clientOpt.errorLog.Log(req, &resp.Header, respBody, rr)
+			}
+		}
+		if r.clientOpt.stopOnError {
+			r.stopOnFailure(req, resp, respBody, rr)
+		}
+		if r.clientOpt.sample > 0 {
+			r.printSample(req, resp, respBody, rr)
+		}
+	}()
+
+	rr.affinityChecked = false
+	rr.affinityBroken = false
+	rr.serverTime = -1
+	rr.follow = -1
+	rr.size = -1
+	rr.expect = ""
+	rr.continueWait = -1
+	rr.ttfb = -1
+	rr.ttlb = -1
+	rr.followCode = ""
+	rr.followMissing = false
+	rr.queue = -1
+	rr.redirect = redirectKey{}
+	rr.hsts = ""
+	rr.cache = ""
+	rr.checksFailed = 0
+	rr.endpoint = ""
+	var data *templateData
+	if r.clientOpt.templateFuncs != nil {
+		data = &templateData{
+			Run:         r.clientOpt.templateRun,
+			Worker:      w.scope,
+			Vars:        r.clientOpt.templateVars,
+			Request:     atomic.AddInt64(&r.requestCount, 1) - 1,
+			ConnRequest: w.requests,
+		}
+		w.requests++
+	}
+	body, bodyName := r.pickBody()
+	rr.body = bodyName
+	if r.clientOpt.randomBodySize > 0 {
+		req.SetBodyRaw(w.nextRandomBody(r.clientOpt.randomBodySize))
+	} else if err := r.setRequestBody(req, body, data); err != nil {
+		rr.cost = 0
+		rr.code = ""
+		rr.error = err.Error()
+		return
+	}
+	if r.clientOpt.maxConnRequests > 0 && !r.clientOpt.disableKeepAlive {
+		// close the connection after its last request, a new one is opened
+		// for the request which follows
+		if wt.connRequests+1 >= r.clientOpt.maxConnRequests {
+			req.Header.SetConnectionClose()
+		} else {
+			req.Header.ResetConnectionClose()
+		}
+	}
+	if t.tmpl != nil {
+		if err := t.tmpl.Render(req, data); err != nil {
+			rr.cost = 0
+			rr.code = ""
+			rr.error = err.Error()
+			return
+		}
+	}
+	if len(r.clientOpt.hosts) > 1 {
+		req.Header.SetHost(r.nextHost())
+	}
+	if e := w.replay; e != nil {
+		req.Header.SetMethod(e.method)
+		req.URI().Update(e.path)
+		rr.endpoint = e.group
+	}
+	if r.clientOpt.groupBy != nil {
+		rr.endpoint = r.clientOpt.groupBy.endpoint(t, req)
+	}
+	rr.requestID = ""
+	if r.clientOpt.requestIDHeader != "" {
+		rr.requestID = newRequestID()
+		req.Header.Set(r.clientOpt.requestIDHeader, rr.requestID)
+	}
 	t1 := time.Since(startTime)
 	var err error
+	wt.conn.takeTimeoutPhase()
+	wt.conn.takeWroteAt()
+	wt.conn.takeFirstByteAt()
 	if r.clientOpt.doTimeout > 0 {
-		err = r.httpClient.DoTimeout(req, resp, r.clientOpt.doTimeout)
+		err = wt.client.DoTimeout(req, resp, r.clientOpt.doTimeout)
 	} else {
-		err = r.httpClient.Do(req, resp)
+		err = wt.client.Do(req, resp)
+	}
+	if r.clientOpt.reportTTFB && err == nil {
+		// the response is read whole by Do, before it's decoded or followed
+		t2 := time.Since(startTime)
+		if firstByteAt := wt.conn.takeFirstByteAt(); !firstByteAt.IsZero() {
+			rr.ttfb = firstByteAt.Sub(startTime) - t1
+			rr.ttlb = t2 - t1
+		}
+	}
+	if wroteAt := wt.conn.takeWroteAt(); !w.due.IsZero() && !wroteAt.IsZero() {
+		rr.queue = wroteAt.Sub(w.due)
+		if rr.queue < 0 {
+			rr.queue = 0
+		}
+	}
+	w.due = time.Time{}
+	if c, ok := wt.client.(*continueClient); ok {
+		rr.expect, rr.continueWait = c.takeContinue()
+	}
+	if err == nil {
+		if !wt.conn.isNew() {
+			r.resources.addReused()
+		}
+		if resp.ConnectionClose() && !req.ConnectionClose() {
+			r.resources.addCloseAsked()
+		}
+	}
+	switch {
+	case err != nil || resp.ConnectionClose() || req.ConnectionClose():
+		wt.connRequests = 0
+	case wt.conn.isNew():
+		wt.connRequests = 1
+	default:
+		wt.connRequests++
 	}
 	var code string
 
@@ -256,21 +1153,41 @@ func (r *Requester) DoRequest(req *fasthttp.Request, resp *fasthttp.Response, rr
 		rr.cost = time.Since(startTime) - t1
 		rr.code = ""
 		rr.error = err.Error()
+		if phase := wt.conn.takeTimeoutPhase(); phase != "" {
+			rr.error = phase + " timeout"
+		}
 		return
 	}
-	switch resp.StatusCode() / 100 {
-	case 1:
-		code = "1xx"
-	case 2:
-		code = "2xx"
-	case 3:
-		code = "3xx"
-	case 4:
-		code = "4xx"
-	case 5:
-		code = "5xx"
+	code = statusClass(resp.StatusCode())
+	if r.clientOpt.affinityHeader != "" {
+		r.checkAffinity(wt, resp, rr)
+	}
+	if r.clientOpt.reportRedirects {
+		rr.redirect.kind, rr.redirect.target = classifyRedirect(t.base, resp)
+		if t.base.Scheme == "https" {
+			rr.hsts = checkHSTS(resp)
+		}
+	}
+	if r.clientOpt.reportCache {
+		rr.cache, rr.age = classifyCache(resp)
+		rr.cacheControl = cacheControl(resp)
+	}
+	if r.clientOpt.latencyHeader != "" {
+		rr.serverTime = parseServerTime(resp.Header.Peek(r.clientOpt.latencyHeader))
+	}
+	if r.clientOpt.perKB {
+		rr.size = int64(len(resp.Body()))
+	}
+	if r.clientOpt.decompress {
+		respBody, err = decodeBody(resp)
+		atomic.AddInt64(&r.decodedReadBytes, int64(len(respBody)))
+	} else {
+		err = resp.BodyWriteTo(ioutil.Discard)
+		respBody = resp.Body()
+	}
+	if err == nil && r.followClient != nil {
+		err = r.followUp(t, resp, rr)
 	}
-	err = resp.BodyWriteTo(ioutil.Discard)
 	if err != nil {
 		rr.cost = time.Since(startTime) - t1
 		rr.code = ""
@@ -281,6 +1198,92 @@ func (r *Requester) DoRequest(req *fasthttp.Request, resp *fasthttp.Response, rr
 	rr.cost = time.Since(startTime) - t1
 	rr.code = code
 	rr.error = ""
+	if t.checks != 0 {
+		rr.checksFailed = failedChecks(r.clientOpt.checks, t.checks, resp, respBody, rr.cost)
+	}
+}
+
+// statusClass returns the class of a status code like 2xx, or "" for an
+// unknown one.
+func statusClass(status int) string {
+	switch status / 100 {
+	case 1:
+		return "1xx"
+	case 2:
+		return "2xx"
+	case 3:
+		return "3xx"
+	case 4:
+		return "4xx"
+	case 5:
+		return "5xx"
+	}
+	return ""
+}
+
+// parseServerTime parses the processing time reported by the server, either a
+// duration like 12.5ms or a number of milliseconds. It returns -1 if the value
+// is missing or invalid.
+func parseServerTime(v []byte) time.Duration {
+	s := strings.TrimSpace(string(v))
+	if s == "" {
+		return -1
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return d
+	}
+	if ms, err := strconv.ParseFloat(s, 64); err == nil && ms >= 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	return -1
+}
+
+// checkAffinity compares the backend identifier echoed by the response with
+// the one of the previous response on the same connection, and sends back the
+// cookies set by the response, so that cookie based stickiness is honored.
+func (r *Requester) checkAffinity(wt *workerTarget, resp *fasthttp.Response, rr *ReportRecord) {
+	resp.Header.VisitAllCookie(func(key, value []byte) {
+		c := fasthttp.AcquireCookie()
+		if c.ParseBytes(value) == nil {
+			wt.req.Header.SetCookieBytesKV(c.Key(), c.Value())
+		}
+		fasthttp.ReleaseCookie(c)
+	})
+	backend := string(resp.Header.Peek(r.clientOpt.affinityHeader))
+	rr.affinityChecked = backend != "" && wt.backend != "" && !wt.conn.isNew()
+	rr.affinityBroken = rr.affinityChecked && backend != wt.backend
+	if backend != "" {
+		wt.backend = backend
+	}
+}
+
+// reloadBody polls the body file every interval and swaps in its content
+// once the modification time or size changes. A failed read keeps the
+// previous body, since the file may be in the middle of being rotated.
+func (r *Requester) reloadBody(ctx context.Context, fileName string, interval time.Duration) {
+	var lastMod time.Time
+	var lastSize int64
+	if fi, err := os.Stat(fileName); err == nil {
+		lastMod, lastSize = fi.ModTime(), fi.Size()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		fi, err := os.Stat(fileName)
+		if err != nil || (fi.ModTime().Equal(lastMod) && fi.Size() == lastSize) {
+			continue
+		}
+		bodyBytes, err := ioutil.ReadFile(fileName)
+		if err != nil || r.setBody(bodyBytes) != nil {
+			continue
+		}
+		lastMod, lastSize = fi.ModTime(), fi.Size()
+	}
 }
 
 func (r *Requester) Run() {
@@ -289,14 +1292,20 @@ func (r *Requester) Run() {
 	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
 	defer signal.Stop(sigs)
 
-	ctx, cancelFunc := context.WithCancel(context.Background())
+	stopCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+	ctx, cancelFunc := context.WithCancel(stopCtx)
 	r.cancel = cancelFunc
 	go func() {
 		<-sigs
 		r.closeRecord()
-		cancelFunc()
+		stop()
 	}()
 	startTime = time.Now()
+	go r.resources.watchFDs(stopCtx)
+	if r.clientOpt.bodyReload > 0 {
+		go r.reloadBody(ctx, r.clientOpt.bodyPath, r.clientOpt.bodyReload)
+	}
 	if r.duration > 0 {
 		time.AfterFunc(r.duration, func() {
 			r.closeRecord()
@@ -304,10 +1313,15 @@ func (r *Requester) Run() {
 		})
 	}
 
+	if len(r.clientOpt.schedule) > 0 {
+		r.applyStep(&r.clientOpt.schedule[0])
+		go r.runSchedule(ctx)
+	}
+
 	semaphore := r.requests
 	for i := 0; i < r.concurrency; i++ {
 		r.wg.Add(1)
-		go func() {
+		go func(i int) {
 			defer func() {
 				r.wg.Done()
 				v := recover()
@@ -315,51 +1329,269 @@ func (r *Requester) Run() {
 					panic(v)
 				}
 			}()
-			req := &fasthttp.Request{}
+			var w *worker
+			if r.warm != nil {
+				w = r.warm[i]
+			} else {
+				w = r.newWorker(i)
+			}
 			resp := &fasthttp.Response{}
-			r.httpHeader.CopyTo(&req.Header)
-			if r.httpClient.IsTLS {
-				req.URI().SetScheme("https")
-				req.URI().SetHostBytes(req.Header.Host())
+			// the events of '--sse' count as the requests
+			emitEvent := func(rr *ReportRecord) bool {
+				if r.requests > 0 && atomic.AddInt64(&semaphore, -1) < 0 {
+					recordPool.Put(rr)
+					cancelFunc()
+					return false
+				}
+				r.recordChan <- rr
+				return true
 			}
 
 			for {
+				if !r.wait(ctx, w, i) {
+					return
+				}
 				select {
 				case <-ctx.Done():
 					return
 				default:
 				}
 
+				if r.clientOpt.sse {
+					ti, ok := r.pickTarget(ctx, w)
+					if !ok {
+						return
+					}
+					ok = r.streamEvents(ctx, w, ti, emitEvent)
+					r.targets[ti].host.release()
+					if !ok {
+						return
+					}
+					continue
+				}
+
 				if r.requests > 0 && atomic.AddInt64(&semaphore, -1) < 0 {
 					cancelFunc()
 					return
 				}
 
-				if r.clientOpt.bodyFile != "" {
-					file, err := os.Open(r.clientOpt.bodyFile)
-					if err != nil {
-						rr := recordPool.Get().(*ReportRecord)
-						rr.cost = 0
-						rr.error = err.Error()
-						rr.readBytes = atomic.LoadInt64(&r.readBytes)
-						rr.writeBytes = atomic.LoadInt64(&r.writeBytes)
-						r.recordChan <- rr
-						continue
-					}
-					req.SetBodyStream(file, -1)
-				} else {
-					req.SetBodyRaw(r.clientOpt.bodyBytes)
+				ti, ok := r.pickTarget(ctx, w)
+				if !ok {
+					return
 				}
 				resp.Reset()
 				rr := recordPool.Get().(*ReportRecord)
-				r.DoRequest(req, resp, rr)
-				rr.readBytes = atomic.LoadInt64(&r.readBytes)
-				rr.writeBytes = atomic.LoadInt64(&r.writeBytes)
+				r.DoRequest(w, ti, resp, rr)
+				r.targets[ti].host.release()
 				r.recordChan <- rr
+				if !r.clientOpt.thinkTime.Pause(ctx) {
+					return
+				}
 			}
-		}()
+		}(i)
 	}
 
 	r.wg.Wait()
 	r.closeRecord()
+	r.resources.sampleFDs()
+	if r.clientOpt.cooldown == 0 {
+		r.closeIdleConns()
+	}
+	close(r.exited)
+
+	if r.clientOpt.cooldown > 0 {
+		r.runCooldown(stopCtx)
+	}
+}
+
+// wait blocks until the i-th worker may send a request with '--schedule', it
+// returns false if ctx is done first.
+func (r *Requester) wait(ctx context.Context, w *worker, i int) bool {
+	if r.clientOpt.replay != nil {
+		var ok bool
+		w.replay, w.due, ok = r.clientOpt.replay.Next(ctx)
+		return ok
+	}
+	if r.gate == nil {
+		return true
+	}
+	if !r.gate.Wait(ctx, i) {
+		return false
+	}
+	var ok bool
+	w.due, ok = r.limiter.Wait(ctx)
+	return ok
+}
+
+// Warmup sends requests for the duration of '--warmup' without recording
+// them, so that the connections are open and the target settled once the run
+// starts. The run goes on with the same workers.
+func (r *Requester) Warmup() {
+	ctx, cancel := context.WithTimeout(context.Background(), r.clientOpt.warmup)
+	defer cancel()
+	if len(r.clientOpt.schedule) > 0 {
+		r.applyStep(&r.clientOpt.schedule[0])
+	}
+
+	var wg sync.WaitGroup
+	r.warm = make([]*worker, r.concurrency)
+	for i := range r.warm {
+		r.warm[i] = r.newWorker(i)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := r.warm[i]
+			resp := &fasthttp.Response{}
+			rr := &ReportRecord{}
+			for r.wait(ctx, w, i) && ctx.Err() == nil {
+				ti, ok := r.pickTarget(ctx, w)
+				if !ok {
+					return
+				}
+				resp.Reset()
+				r.DoRequest(w, ti, resp, rr)
+				r.targets[ti].host.release()
+				if !r.clientOpt.thinkTime.Pause(ctx) {
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	atomic.StoreInt64(&r.readBytes, 0)
+	atomic.StoreInt64(&r.writeBytes, 0)
+	atomic.StoreInt64(&r.decodedReadBytes, 0)
+	atomic.StoreInt64(&r.decodedWriteBytes, 0)
+	if r.limiter != nil {
+		// the run starts over at the rate of its first step
+		r.limiter.SetRate(0)
+	}
+}
+
+// Prewarm opens the connections of every worker to every target of
+// '--prewarm' before the run, TLS handshakes included, so that the run
+// doesn't start with the workers connecting. The run goes on with the same
+// workers, which send their first requests over these connections. It returns
+// the number of connections opened out of those tried, and the first error.
+func (r *Requester) Prewarm() (int, int, error) {
+	var (
+		targets []*workerTarget
+		addrs   []string
+	)
+	r.warm = make([]*worker, r.concurrency)
+	for i := range r.warm {
+		r.warm[i] = r.newWorker(i)
+		for ti, t := range r.targets {
+			if wt := r.target(r.warm[i], ti); wt.dial != nil {
+				targets = append(targets, wt)
+				addrs = append(addrs, t.host.addr)
+			}
+		}
+	}
+
+	var (
+		wg       sync.WaitGroup
+		lock     sync.Mutex
+		opened   int
+		firstErr error
+	)
+	// not to flood the targets with handshakes
+	slots := make(chan struct{}, prewarmParallelism)
+	for k, wt := range targets {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(wt *workerTarget, addr string) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			conn, err := wt.dial(addr)
+			// the first request is timed as one over an open connection
+			wt.conn.takeTimings()
+			wt.conn.takeTimeoutPhase()
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			wt.prewarmed = conn
+			opened++
+		}(wt, addrs[k])
+	}
+	wg.Wait()
+	atomic.StoreInt64(&r.readBytes, 0)
+	atomic.StoreInt64(&r.writeBytes, 0)
+	return opened, len(targets), firstErr
+}
+
+func (r *Requester) applyStep(step *ScheduleStep) {
+	r.gate.Set(step.Concurrency)
+	r.limiter.SetRate(step.Rate)
+}
+
+// runSchedule applies the steps of '--schedule' in time, the first one being
+// applied from the start.
+func (r *Requester) runSchedule(ctx context.Context) {
+	for i := 1; i < len(r.clientOpt.schedule); i++ {
+		step := &r.clientOpt.schedule[i]
+		timer := time.NewTimer(time.Until(startTime.Add(step.At)))
+		select {
+		case <-timer.C:
+			r.applyStep(step)
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// TargetAt returns the load aimed at by the schedule step in effect at the
+// time, or nil without '--schedule'.
+func (r *Requester) TargetAt(at time.Time) *LoadTarget {
+	schedule := r.clientOpt.schedule
+	if len(schedule) == 0 {
+		return nil
+	}
+	elapsed := at.Sub(startTime)
+	i := 0
+	for i+1 < len(schedule) && schedule[i+1].At <= elapsed {
+		i++
+	}
+	return &schedule[i].LoadTarget
+}
+
+// runCooldown keeps probing the target lightly after the load stopped, going
+// through the connections of all workers in turn, unless the run was interrupted.
+func (r *Requester) runCooldown(ctx context.Context) {
+	defer close(r.cooldownChan)
+	r.workersLock.Lock()
+	workers := r.workers
+	r.workersLock.Unlock()
+	// no worker started if the run was stopped before its first request
+	if ctx.Err() != nil || len(workers) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.clientOpt.cooldown)
+	defer cancel()
+
+	resp := &fasthttp.Response{}
+	start := time.Now()
+	ticker := time.NewTicker(cooldownProbeInterval)
+	defer ticker.Stop()
+	for i := 0; ; i++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		w := workers[i%len(workers)]
+		rr := recordPool.Get().(*ReportRecord)
+		resp.Reset()
+		at := time.Since(start)
+		r.DoRequest(w, (i/len(workers))%len(r.targets), resp, rr)
+		r.cooldownChan <- &cooldownProbe{at: at, record: rr}
+	}
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Resolver resolves the hosts to dial to addresses of the IP version, through
+// '--dns-server' if given, and keeps the answers for '--dns-refresh', a minute
+// by default. New connections are spread over the addresses of the latest
+// answer, in turn or at random.
+type Resolver struct {
+	resolver  *net.Resolver
+	refresh   time.Duration
+	random    bool
+	ipVersion int
+
+	lock  sync.Mutex
+	hosts map[string]*resolvedHost
+}
+
+type resolvedHost struct {
+	ips     []net.IP
+	expires time.Time
+	next    int
+}
+
+func NewResolver(server string, refresh time.Duration, random bool, ipVersion int) *Resolver {
+	resolver := net.DefaultResolver
+	if server != "" {
+		server = addMissingDNSPort(server)
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+	if refresh <= 0 {
+		refresh = fasthttp.DefaultDNSCacheDuration
+	}
+	return &Resolver{
+		resolver: resolver,
+		refresh:  refresh,
+		random:   random,
+
+		ipVersion: ipVersion,
+		hosts:     make(map[string]*resolvedHost),
+	}
+}
+
+func addMissingDNSPort(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(server, "53")
+}
+
+func (r *Resolver) ipNetwork() string {
+	if r.ipVersion == 6 {
+		return "ip6"
+	}
+	return "ip4"
+}
+
+func (r *Resolver) ipVersionOrDefault() int {
+	if r.ipVersion == 6 {
+		return 6
+	}
+	return 4
+}
+
+// pick returns the address to connect to for host, resolving it again once
+// the answer expired. A failed refresh keeps the previous answer.
+func (r *Resolver) pick(host string) (net.IP, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	h, ok := r.hosts[host]
+	if !ok || time.Now().After(h.expires) {
+		ips, err := r.resolver.LookupIP(context.Background(), r.ipNetwork(), host)
+		if err == nil && len(ips) == 0 {
+			err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		if err != nil {
+			if !ok {
+				return nil, err
+			}
+		} else {
+			if !ok {
+				h = &resolvedHost{}
+				r.hosts[host] = h
+			}
+			h.ips = ips
+		}
+		h.expires = time.Now().Add(r.refresh)
+	}
+	if r.random {
+		return h.ips[rand.Intn(len(h.ips))], nil
+	}
+	ip := h.ips[h.next%len(h.ips)]
+	h.next++
+	return ip, nil
+}
+
+// Dial resolves the host of addr before passing it to dial, addresses with an
+// IP are passed as is. The IP connected to is recorded on t.
+func (r *Resolver) Dial(dial fasthttp.DialFunc, t *connTracker) fasthttp.DialFunc {
+	return func(addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return dial(addr)
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			if (ip.To4() != nil) != (r.ipVersion != 6) {
+				return nil, fmt.Errorf("address %s is not IPv%d", host, r.ipVersionOrDefault())
+			}
+		} else {
+			start := time.Now()
+			ip, err := r.pick(host)
+			if err != nil {
+				return nil, err
+			}
+			t.addTiming("dns", time.Since(start))
+			host = ip.String()
+			addr = net.JoinHostPort(host, port)
+		}
+		conn, err := dial(addr)
+		if err == nil {
+			t.setRemoteIP(host)
+		}
+		return conn, err
+	}
+}
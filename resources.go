@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// fdSampleInterval is how often the open file descriptors are counted.
+const fdSampleInterval = 100 * time.Millisecond
+
+// timeWaitWindow is how long the sockets closed stay in TIME_WAIT, the
+// TCP_TIMEWAIT_LEN of Linux, past which they are forgotten.
+const timeWaitWindow = 60 * time.Second
+
+// ResourceReport is what the run took of the resources of the OS, so that the
+// errors can be told apart from the exhaustion of the local ones.
+type ResourceReport struct {
+	PeakConns int64
+	// PeakFDs is 0 where the descriptors can't be counted, outside of Linux
+	PeakFDs int64 `json:",omitempty"`
+	// TimeWait is the number of sockets to the targets left closing once the
+	// run is over, in TIME_WAIT or on their way to it, -1 before or where
+	// unknown.
+	TimeWait int64
+
+	// Opened counts the connections opened and Reused the requests sent over
+	// one already open. ClosedByPeer counts the connections the server closed,
+	// with Connection: close or else, and IdleEvicted those closed by plow
+	// once idle for '--idle-conn-timeout'.
+	Opened       int64
+	Reused       int64
+	ClosedByPeer int64
+	IdleEvicted  int64
+}
+
+// resourceTracker counts the connections opened by the run and the file
+// descriptors of the process, and remembers the sockets of the connections.
+type resourceTracker struct {
+	conns     int64
+	peakConns int64
+	peakFDs   int64
+
+	// idleTimeout is the one of the clients, for the closes to be told idle
+	// evictions
+	idleTimeout time.Duration
+	opened      int64
+	reused      int64
+	peerCloses  int64
+	closeAsked  int64
+	idleEvicted int64
+
+	lock sync.Mutex
+	// sockets are the local and remote addresses of the TCP connections, with
+	// when they were closed, zero while open
+	sockets map[string]time.Time
+	// pruned is when the sockets out of TIME_WAIT were last forgotten
+	pruned time.Time
+}
+
+func newResourceTracker() *resourceTracker {
+	return &resourceTracker{sockets: make(map[string]time.Time), pruned: time.Now()}
+}
+
+// prune forgets the sockets closed before the TIME_WAIT window, once a
+// second at most, for the runs opening a connection per request not to grow
+// them along. It must be called with the lock held.
+func (t *resourceTracker) prune(now time.Time) {
+	if now.Sub(t.pruned) < time.Second {
+		return
+	}
+	t.pruned = now
+	for key, closed := range t.sockets {
+		if !closed.IsZero() && now.Sub(closed) > timeWaitWindow {
+			delete(t.sockets, key)
+		}
+	}
+}
+
+func storeMax(peak *int64, v int64) {
+	for {
+		p := atomic.LoadInt64(peak)
+		if v <= p || atomic.CompareAndSwapInt64(peak, p, v) {
+			return
+		}
+	}
+}
+
+func (t *resourceTracker) Dial(dial func(addr string) (net.Conn, error)) func(addr string) (net.Conn, error) {
+	return func(addr string) (net.Conn, error) {
+		conn, err := dial(addr)
+		if err != nil {
+			return nil, err
+		}
+		storeMax(&t.peakConns, atomic.AddInt64(&t.conns, 1))
+		atomic.AddInt64(&t.opened, 1)
+		tc := &trackedConn{Conn: conn, tracker: t, lastUsed: time.Now().UnixNano()}
+		local, ok1 := conn.LocalAddr().(*net.TCPAddr)
+		remote, ok2 := conn.RemoteAddr().(*net.TCPAddr)
+		if ok1 && ok2 {
+			tc.socket = socketKey(local.IP, local.Port, remote.IP, remote.Port)
+			t.lock.Lock()
+			t.sockets[tc.socket] = time.Time{}
+			t.prune(time.Now())
+			t.lock.Unlock()
+		}
+		return tc, nil
+	}
+}
+
+// addReused counts a request sent over a connection already open.
+func (t *resourceTracker) addReused() {
+	atomic.AddInt64(&t.reused, 1)
+}
+
+// addCloseAsked counts a response with Connection: close, after which the
+// client closes the connection as asked by the server.
+func (t *resourceTracker) addCloseAsked() {
+	atomic.AddInt64(&t.closeAsked, 1)
+}
+
+// trackedConn counts the connection until closed, telling the connections
+// found closed by the server and the ones closed once idle.
+type trackedConn struct {
+	net.Conn
+	tracker *resourceTracker
+	closed  int32
+	// lastUsed is the UnixNano of the last read or write
+	lastUsed   int64
+	peerClosed int32
+	// socket is the key of the TCP connection in the sockets of the tracker
+	socket string
+}
+
+// isPeerClose tells whether err is the one of a connection closed by the
+// server.
+func isPeerClose(err error) bool {
+	return err == io.EOF || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
+
+func (c *trackedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.StoreInt64(&c.lastUsed, time.Now().UnixNano())
+	if err != nil && isPeerClose(err) {
+		atomic.StoreInt32(&c.peerClosed, 1)
+	}
+	return n, err
+}
+
+func (c *trackedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.StoreInt64(&c.lastUsed, time.Now().UnixNano())
+	if err != nil && isPeerClose(err) {
+		atomic.StoreInt32(&c.peerClosed, 1)
+	}
+	return n, err
+}
+
+func (c *trackedConn) Close() error {
+	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		t := c.tracker
+		atomic.AddInt64(&t.conns, -1)
+		idle := time.Duration(time.Now().UnixNano() - atomic.LoadInt64(&c.lastUsed))
+		switch {
+		case atomic.LoadInt32(&c.peerClosed) == 1:
+			atomic.AddInt64(&t.peerCloses, 1)
+		case t.idleTimeout > 0 && idle >= t.idleTimeout:
+			atomic.AddInt64(&t.idleEvicted, 1)
+		}
+		if c.socket != "" {
+			t.lock.Lock()
+			t.sockets[c.socket] = time.Now()
+			t.lock.Unlock()
+		}
+	}
+	return c.Conn.Close()
+}
+
+func addrKey(ip net.IP, port int) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return net.JoinHostPort(ip.String(), strconv.Itoa(port))
+}
+
+func socketKey(localIP net.IP, localPort int, remoteIP net.IP, remotePort int) string {
+	return addrKey(localIP, localPort) + " " + addrKey(remoteIP, remotePort)
+}
+
+// watchFDs counts the open file descriptors until ctx is done.
+func (t *resourceTracker) watchFDs(ctx context.Context) {
+	ticker := time.NewTicker(fdSampleInterval)
+	defer ticker.Stop()
+	for {
+		if !t.sampleFDs() {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *resourceTracker) sampleFDs() bool {
+	n, ok := countFDs()
+	if ok {
+		storeMax(&t.peakFDs, n)
+	}
+	return ok
+}
+
+func countFDs() (int64, bool) {
+	dir, err := os.Open("/proc/self/fd")
+	if err != nil {
+		return 0, false
+	}
+	defer dir.Close()
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return 0, false
+	}
+	// without the one of the directory itself
+	return int64(len(names) - 1), true
+}
+
+// tcpClosingStates are the states of /proc/net/tcp a socket closed first on
+// our side goes through: FIN_WAIT1, FIN_WAIT2, TIME_WAIT and CLOSING.
+var tcpClosingStates = map[string]bool{"04": true, "05": true, "06": true, "0B": true}
+
+// countTimeWait counts the sockets of the connections which are closing, -1
+// if /proc/net/tcp can't be read.
+func (t *resourceTracker) countTimeWait() int64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	n := int64(-1)
+	for _, path := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		if n < 0 {
+			n = 0
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			// sl local_address rem_address st ...
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 4 || !tcpClosingStates[fields[3]] {
+				continue
+			}
+			localIP, localPort, ok1 := parseProcAddr(fields[1])
+			remoteIP, remotePort, ok2 := parseProcAddr(fields[2])
+			if !ok1 || !ok2 {
+				continue
+			}
+			if _, ok := t.sockets[socketKey(localIP, localPort, remoteIP, remotePort)]; ok {
+				n++
+			}
+		}
+		f.Close()
+	}
+	return n
+}
+
+// parseProcAddr parses an address of /proc/net/tcp like 0100007F:1F90, the
+// IP being made of 32 bits words in host order, little endian on the
+// platforms plow runs on.
+func parseProcAddr(s string) (net.IP, int, bool) {
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return nil, 0, false
+	}
+	b, err := hex.DecodeString(s[:i])
+	if err != nil || (len(b) != net.IPv4len && len(b) != net.IPv6len) {
+		return nil, 0, false
+	}
+	for w := 0; w < len(b); w += 4 {
+		b[w], b[w+1], b[w+2], b[w+3] = b[w+3], b[w+2], b[w+1], b[w]
+	}
+	port, err := strconv.ParseUint(s[i+1:], 16, 16)
+	if err != nil {
+		return nil, 0, false
+	}
+	return net.IP(b), int(port), true
+}
+
+func (t *resourceTracker) report() *ResourceReport {
+	return &ResourceReport{
+		PeakConns: atomic.LoadInt64(&t.peakConns),
+		PeakFDs:   atomic.LoadInt64(&t.peakFDs),
+		TimeWait:  -1,
+
+		Opened:       atomic.LoadInt64(&t.opened),
+		Reused:       atomic.LoadInt64(&t.reused),
+		ClosedByPeer: atomic.LoadInt64(&t.peerCloses) + atomic.LoadInt64(&t.closeAsked),
+		IdleEvicted:  atomic.LoadInt64(&t.idleEvicted),
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/valyala/fasthttp"
+)
+
+// sampleMaxBody bounds the bodies printed by '--sample'.
+const sampleMaxBody = 1024
+
+// printSample prints the request and its response to stderr if it is one of
+// the first ones of '--sample', those of '--warmup' included as they are sent
+// alike.
+func (r *Requester) printSample(req *fasthttp.Request, resp *fasthttp.Response, body []byte, rr *ReportRecord) {
+	n := atomic.AddInt64(&r.sampled, 1)
+	if n > int64(r.clientOpt.sample) {
+		return
+	}
+	// written at once, not to mix with the samples of other requests
+	os.Stderr.WriteString(fmt.Sprintf("Sample %d of %d:\n\n%s\n",
+		n, r.clientOpt.sample, formatExchange(req, resp, body, rr, r.clientOpt.checks, sampleMaxBody)))
+	if n == int64(r.clientOpt.sample) {
+		close(r.samplesDone)
+	}
+}
+
+// SamplesDone is closed once the requests of '--sample' are printed.
+func (r *Requester) SamplesDone() <-chan struct{} {
+	return r.samplesDone
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadTarget is the load aimed at, as set by a schedule step. A zero rate
+// means as fast as the concurrency allows.
+type LoadTarget struct {
+	Concurrency int
+	Rate        float64
+}
+
+type ScheduleStep struct {
+	At time.Duration
+	LoadTarget
+}
+
+// Schedule drives the load over a run, each step holding until the next one.
+type Schedule []ScheduleStep
+
+// LoadSchedule reads a CSV file of time,concurrency,rate rows, the time being
+// either a duration like 1m30s or a number of seconds since the start. The
+// rate may be left empty, and a first row without a time is skipped as a
+// header.
+func LoadSchedule(fileName string) (Schedule, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+	var schedule Schedule
+	for line := 1; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		step, err := parseScheduleStep(record)
+		if err != nil {
+			// a first row without a time is a header
+			if line == 1 && !isScheduleTime(record[0]) {
+				continue
+			}
+			return nil, fmt.Errorf("%s:%d: %v", fileName, line, err)
+		}
+		if len(schedule) > 0 && step.At <= schedule[len(schedule)-1].At {
+			return nil, fmt.Errorf("%s:%d: time is not after the previous row", fileName, line)
+		}
+		schedule = append(schedule, step)
+	}
+	if len(schedule) == 0 {
+		return nil, fmt.Errorf("%s: empty schedule", fileName)
+	}
+	if schedule.MaxConcurrency() == 0 {
+		return nil, fmt.Errorf("%s: concurrency is never above 0", fileName)
+	}
+	return schedule, nil
+}
+
+// isScheduleTime tells whether s is a duration or a number of seconds.
+func isScheduleTime(s string) bool {
+	s = strings.TrimSpace(s)
+	if _, err := time.ParseDuration(s); err == nil {
+		return true
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+func parseScheduleStep(record []string) (step ScheduleStep, err error) {
+	if len(record) < 2 || len(record) > 3 {
+		return step, fmt.Errorf("expecting time,concurrency[,rate]")
+	}
+	at := strings.TrimSpace(record[0])
+	if step.At, err = time.ParseDuration(at); err != nil {
+		secs, perr := strconv.ParseFloat(at, 64)
+		if perr != nil {
+			return step, fmt.Errorf("invalid time: %s", at)
+		}
+		step.At = time.Duration(secs * float64(time.Second))
+	}
+	if step.At < 0 {
+		return step, fmt.Errorf("invalid time: %s", at)
+	}
+	if step.Concurrency, err = strconv.Atoi(strings.TrimSpace(record[1])); err != nil || step.Concurrency < 0 {
+		return step, fmt.Errorf("invalid concurrency: %s", record[1])
+	}
+	if len(record) == 3 && strings.TrimSpace(record[2]) != "" {
+		if step.Rate, err = strconv.ParseFloat(strings.TrimSpace(record[2]), 64); err != nil || step.Rate < 0 {
+			return step, fmt.Errorf("invalid rate: %s", record[2])
+		}
+	}
+	return step, nil
+}
+
+func (s Schedule) MaxConcurrency() int {
+	max := 0
+	for _, step := range s {
+		if step.Concurrency > max {
+			max = step.Concurrency
+		}
+	}
+	return max
+}
+
+// End is the time of the last step, where the run ends unless a duration is
+// given, the load of the last step applying only past it.
+func (s Schedule) End() time.Duration {
+	return s[len(s)-1].At
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// SinkMessage is a line written to the stdin of a '--sink-exec' process. Type
+// is "snapshot" for the reports taken every interval and "final" for the last
+// one, after which stdin is closed.
+type SinkMessage struct {
+	Type        string
+	Time        time.Time
+	Description string `json:",omitempty"`
+	Report      *SnapshotReport
+}
+
+// ExecSink pipes the snapshots of a run to a child process as JSON lines.
+type ExecSink struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	enc   *json.Encoder
+	err   error
+	done  chan struct{}
+}
+
+// NewExecSink starts the command, split on spaces, with its stdout and stderr
+// going to plow's stderr so that they don't mess with the printed report.
+func NewExecSink(command string) (*ExecSink, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty sink command")
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &ExecSink{
+		cmd:   cmd,
+		stdin: stdin,
+		enc:   json.NewEncoder(stdin),
+		done:  make(chan struct{}),
+	}, nil
+}
+
+func (s *ExecSink) write(typ string, desc string, report *SnapshotReport) {
+	if s.err != nil {
+		return
+	}
+	s.err = s.enc.Encode(&SinkMessage{Type: typ, Time: time.Now(), Description: desc, Report: report})
+}
+
+// Loop writes a snapshot every interval until doneChan is closed, then the
+// final report. A sink which stopped reading only fails itself, the run goes on.
+func (s *ExecSink) Loop(snapshot func() *SnapshotReport, interval time.Duration, desc string, doneChan <-chan struct{}) {
+	defer close(s.done)
+	sinkLoop(snapshot, interval, doneChan, func(rs *SnapshotReport) {
+		s.write("snapshot", "", rs)
+	})
+	s.write("final", desc, snapshot())
+}
+
+// sinkLoop passes a snapshot to write every interval until doneChan is closed.
+func sinkLoop(snapshot func() *SnapshotReport, interval time.Duration, doneChan <-chan struct{}, write func(*SnapshotReport)) {
+	if interval <= 0 {
+		<-doneChan
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			write(snapshot())
+		case <-doneChan:
+			return
+		}
+	}
+}
+
+// Close waits for the final report to be written, closes the stdin of the
+// process and waits for it to exit.
+func (s *ExecSink) Close() error {
+	<-s.done
+	s.stdin.Close()
+	err := s.cmd.Wait()
+	if s.err != nil {
+		return fmt.Errorf("sink: %v", s.err)
+	}
+	if err != nil {
+		return fmt.Errorf("sink: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// chartsPath serves the charts of the run so far to 'plow serve --source',
+// from the index of ?from=N on.
+var chartsPath = "/api/charts"
+
+// chartsFeed is what chartsPath answers.
+type chartsFeed struct {
+	Description      string
+	Schedule         bool
+	Split            bool
+	TTFB             bool
+	ChartPercentiles []float64
+	// Endpoints are those charted apart, if any
+	Endpoints []string `json:",omitempty"`
+	// Live is set while the run goes on, the charts growing
+	Live   bool
+	Charts []*ChartsReport
+}
+
+func (c *Charts) serveChartsFeed(ctx *fasthttp.RequestCtx) {
+	history := c.chartsHistory()
+	from, _ := strconv.Atoi(string(ctx.QueryArgs().Peek("from")))
+	if from < 0 || from > len(history) {
+		from = len(history)
+	}
+	feed := &chartsFeed{
+		Description:      c.desc,
+		Schedule:         c.schedule,
+		Split:            c.split,
+		TTFB:             c.ttfb,
+		ChartPercentiles: chartPercentiles,
+		Endpoints:        c.endpoints,
+		Live:             c.dataFunc != nil,
+		Charts:           history[from:],
+	}
+	ctx.SetContentType("application/json")
+	_ = json.NewEncoder(ctx).Encode(feed)
+}
+
+// ChartsSource follows the charts of the run of another plow through its
+// Web UI, for 'plow serve --source' to serve them.
+type ChartsSource struct {
+	url    string
+	client *fasthttp.Client
+	// name is the url without the credentials, to be shown
+	name string
+
+	lock    sync.Mutex
+	history []*ChartsReport
+	// fresh is set once the last poll brought new charts
+	fresh bool
+}
+
+func NewChartsSource(rawURL string, insecure bool) (*ChartsSource, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid source %q, expected like http://loadgen:18888", rawURL)
+	}
+	u.User = nil
+	return &ChartsSource{
+		url:    rawURL,
+		name:   u.String(),
+		client: &fasthttp.Client{Name: "plow", TLSConfig: &tls.Config{InsecureSkipVerify: insecure}},
+	}, nil
+}
+
+func (s *ChartsSource) String() string {
+	return s.name
+}
+
+func (s *ChartsSource) fetch(from int) (*chartsFeed, error) {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(resp)
+	}()
+	req.SetRequestURI(s.url)
+	req.URI().SetPath(chartsPath)
+	req.URI().QueryArgs().SetUint("from", from)
+	if err := s.client.DoTimeout(req, resp, 10*refreshInterval); err != nil {
+		return nil, fmt.Errorf("source %s: %v", s.name, err)
+	}
+	if resp.StatusCode() != fasthttp.StatusOK {
+		return nil, fmt.Errorf("source %s: status %d, expected the Web UI of plow", s.name, resp.StatusCode())
+	}
+	var feed chartsFeed
+	if err := json.Unmarshal(resp.Body(), &feed); err != nil {
+		return nil, fmt.Errorf("source %s: %v", s.name, err)
+	}
+	return &feed, nil
+}
+
+// Attach takes the charts of the run so far.
+func (s *ChartsSource) Attach() (*chartsFeed, error) {
+	feed, err := s.fetch(0)
+	if err != nil {
+		return nil, err
+	}
+	s.history = feed.Charts
+	return feed, nil
+}
+
+// Follow polls the new charts every refreshInterval until the run is over,
+// its plow gone. The failed polls are retried.
+func (s *ChartsSource) Follow() {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.lock.Lock()
+		from := len(s.history)
+		s.lock.Unlock()
+		feed, err := s.fetch(from)
+		if err != nil {
+			continue
+		}
+		s.lock.Lock()
+		s.history = append(s.history, feed.Charts...)
+		s.fresh = len(feed.Charts) > 0
+		s.lock.Unlock()
+		if !feed.Live {
+			return
+		}
+	}
+}
+
+// Charts returns the latest charts, nil unless the last poll brought some.
+func (s *ChartsSource) Charts() *ChartsReport {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.fresh || len(s.history) == 0 {
+		return nil
+	}
+	cr := *s.history[len(s.history)-1]
+	cr.Time = time.Now()
+	return &cr
+}
+
+func (s *ChartsSource) History() []*ChartsReport {
+	s.lock.Lock()
+	history := make([]*ChartsReport, len(s.history))
+	copy(history, s.history)
+	s.lock.Unlock()
+	return history
+}
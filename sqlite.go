@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqliteSchema creates the tables of '--sqlite', the latencies in
+// milliseconds and the times in RFC 3339. Every snapshot is a row of
+// snapshots, final being 1 for the last one, with its percentiles and status
+// codes in rows of their own, and the run a row of runs, summary being the
+// JSON of its final report.
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS runs (
+  id TEXT PRIMARY KEY, started TEXT, description TEXT, summary TEXT);
+CREATE TABLE IF NOT EXISTS snapshots (
+  run_id TEXT, time TEXT, final INTEGER, elapsed REAL, count INTEGER, errors INTEGER,
+  rps REAL, read_throughput REAL, write_throughput REAL,
+  latency_min REAL, latency_mean REAL, latency_stddev REAL, latency_max REAL);
+CREATE TABLE IF NOT EXISTS percentiles (
+  run_id TEXT, time TEXT, final INTEGER, percentile REAL, latency REAL);
+CREATE TABLE IF NOT EXISTS codes (
+  run_id TEXT, time TEXT, final INTEGER, code TEXT, count INTEGER);
+CREATE INDEX IF NOT EXISTS snapshots_run ON snapshots (run_id);
+CREATE INDEX IF NOT EXISTS percentiles_run ON percentiles (run_id);
+CREATE INDEX IF NOT EXISTS codes_run ON codes (run_id);
+`
+
+// SQLiteSink appends the snapshots of a run to a SQLite database, piping the
+// statements to the sqlite3 command like '--sink-exec' does its JSON lines,
+// which keeps plow free of cgo.
+type SQLiteSink struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	runID string
+	err   error
+	done  chan struct{}
+}
+
+// NewSQLiteSink opens the database at path with sqlite3, creating its tables
+// unless they exist, and adds the run of runID to them. A run of the same ID
+// already there is replaced, as when a run is repeated with '--run-id'.
+func NewSQLiteSink(path, runID string) (*SQLiteSink, error) {
+	sqlite, err := exec.LookPath("sqlite3")
+	if err != nil {
+		return nil, fmt.Errorf("sqlite requires the sqlite3 command: %v", err)
+	}
+	// -bail stops at the first failed statement, reported once closed
+	cmd := exec.Command(sqlite, "-bail", path)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	s := &SQLiteSink{cmd: cmd, stdin: stdin, runID: runID, done: make(chan struct{})}
+	id := sqlQuote(runID)
+	s.exec(sqliteSchema + fmt.Sprintf(`BEGIN;
+DELETE FROM snapshots WHERE run_id = %[1]s;
+DELETE FROM percentiles WHERE run_id = %[1]s;
+DELETE FROM codes WHERE run_id = %[1]s;
+INSERT OR REPLACE INTO runs (id, started) VALUES (%[1]s, %[2]s);
+COMMIT;
+`, id, sqlQuote(time.Now().Format(time.RFC3339Nano))))
+	return s, nil
+}
+
+func (s *SQLiteSink) exec(statements string) {
+	if s.err != nil {
+		return
+	}
+	_, s.err = io.WriteString(s.stdin, statements)
+}
+
+// write appends the rows of a snapshot in a transaction of its own.
+func (s *SQLiteSink) write(rs *SnapshotReport, final bool) {
+	var buf bytes.Buffer
+	key := fmt.Sprintf("%s, %s, %d", sqlQuote(s.runID), sqlQuote(time.Now().Format(time.RFC3339Nano)), boolToInt(final))
+	var errs int64
+	for _, n := range rs.Errors {
+		errs += n
+	}
+	buf.WriteString("BEGIN;\n")
+	fmt.Fprintf(&buf, "INSERT INTO snapshots VALUES (%s, %s, %d, %d, %s, %s, %s, %s, %s, %s, %s);\n",
+		key, sqlFloat(rs.Elapsed.Seconds()), rs.Count, errs,
+		sqlFloat(rs.RPS), sqlFloat(rs.ReadThroughput), sqlFloat(rs.WriteThroughput),
+		sqlMillis(rs.Stats.Min), sqlMillis(rs.Stats.Mean), sqlMillis(rs.Stats.StdDev), sqlMillis(rs.Stats.Max))
+	for _, p := range rs.Percentiles {
+		fmt.Fprintf(&buf, "INSERT INTO percentiles VALUES (%s, %s, %s);\n",
+			key, sqlFloat(math.Round(p.Percentile*1e9)/1e7), sqlMillis(p.Latency))
+	}
+	codes := make([]string, 0, len(rs.Codes))
+	for code := range rs.Codes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Fprintf(&buf, "INSERT INTO codes VALUES (%s, %s, %d);\n", key, sqlQuote(code), rs.Codes[code])
+	}
+	buf.WriteString("COMMIT;\n")
+	s.exec(buf.String())
+}
+
+// Loop appends a snapshot every interval until doneChan is closed, then the
+// final one, the summary of the run set along with its description. A
+// database which failed only fails the sink, the run goes on.
+func (s *SQLiteSink) Loop(snapshot func() *SnapshotReport, interval time.Duration, desc string, doneChan <-chan struct{}) {
+	defer close(s.done)
+	sinkLoop(snapshot, interval, doneChan, func(rs *SnapshotReport) {
+		s.write(rs, false)
+	})
+	rs := snapshot()
+	s.write(rs, true)
+	summary, err := json.Marshal(rs)
+	if err != nil {
+		s.err = err
+		return
+	}
+	s.exec(fmt.Sprintf("UPDATE runs SET description = %s, summary = %s WHERE id = %s;\n",
+		sqlQuote(desc), sqlQuote(string(summary)), sqlQuote(s.runID)))
+}
+
+// Close waits for the final snapshot to be written, then for sqlite3 to
+// commit it and exit.
+func (s *SQLiteSink) Close() error {
+	<-s.done
+	s.stdin.Close()
+	err := s.cmd.Wait()
+	if s.err != nil {
+		return fmt.Errorf("sqlite: %v", s.err)
+	}
+	if err != nil {
+		return fmt.Errorf("sqlite: %v", err)
+	}
+	return nil
+}
+
+// sqlQuote returns s as a SQL string literal.
+func sqlQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// sqlFloat returns f as a SQL number, NULL if it isn't one.
+func sqlFloat(f float64) string {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "NULL"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sqlMillis(d time.Duration) string {
+	return sqlFloat(float64(d) / float64(time.Millisecond))
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httputil"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// errNoEvent is the error of a stream closed before its first event.
+var errNoEvent = errors.New("stream closed before an event")
+
+// streamEvents opens an event stream of '--sse' to the i-th target and emits
+// a record for every event until the stream or ctx is done, the first one
+// timing the wait for it and the others the time since the previous event.
+// It returns false once emit refuses a record.
+func (r *Requester) streamEvents(ctx context.Context, w *worker, i int, emit func(rr *ReportRecord) bool) bool {
+	t := r.targets[i]
+	wt := r.target(w, i)
+	start := time.Now()
+	if len(r.clientOpt.hosts) > 1 {
+		wt.req.Header.SetHost(r.nextHost())
+	}
+	var requestID string
+	if r.clientOpt.requestIDHeader != "" {
+		requestID = newRequestID()
+		wt.req.Header.Set(r.clientOpt.requestIDHeader, requestID)
+	}
+	record := func(code, err string) *ReportRecord {
+		rr := recordPool.Get().(*ReportRecord)
+		*rr = ReportRecord{
+			cost:              time.Since(start),
+			code:              code,
+			error:             err,
+			host:              t.host.addr,
+			hostBusy:          atomic.LoadInt64(&t.host.busy),
+			hostConns:         atomic.LoadInt64(&t.host.conns),
+			readBytes:         atomic.LoadInt64(&r.readBytes),
+			writeBytes:        atomic.LoadInt64(&r.writeBytes),
+			decodedReadBytes:  atomic.LoadInt64(&r.decodedReadBytes),
+			decodedWriteBytes: atomic.LoadInt64(&r.decodedWriteBytes),
+			conn:              wt.conn.takeTimings(),
+			ip:                wt.conn.remoteIP(),
+			connID:            wt.conn.connID(),
+			variant:           t.variant,
+			serverTime:        -1,
+			queue:             -1,
+			follow:            -1,
+			continueWait:      -1,
+			ttfb:              -1,
+			ttlb:              -1,
+			size:              -1,
+			checksRun:         t.checks,
+			requestID:         requestID,
+		}
+		if err != "" {
+			rr.checksFailed = t.checks
+		}
+		return rr
+	}
+	// fail emits the record of a failed stream, with the header of its
+	// response if any
+	fail := func(code, err string, header *fasthttp.ResponseHeader) bool {
+		rr := record(code, err)
+		if r.clientOpt.errorLog != nil {
+			r.clientOpt.errorLog.Log(wt.req, header, nil, rr)
+		}
+		return emit(rr)
+	}
+
+	conn, err := wt.dialConn(t.host.addr)
+	if err != nil {
+		if phase := wt.conn.takeTimeoutPhase(); phase != "" {
+			return fail("", phase+" timeout", nil)
+		}
+		return fail("", err.Error(), nil)
+	}
+	defer conn.Close()
+	// unblock the reads once the run is over
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if len(wt.req.Header.Peek(fasthttp.HeaderAccept)) == 0 {
+		wt.req.Header.Set(fasthttp.HeaderAccept, "text/event-stream")
+	}
+	bw := bufio.NewWriter(conn)
+	if err = wt.req.Write(bw); err == nil {
+		err = bw.Flush()
+	}
+	br := bufio.NewReader(conn)
+	var header fasthttp.ResponseHeader
+	if err == nil {
+		r.setEventDeadline(conn)
+		err = header.Read(br)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return true
+		}
+		return fail("", err.Error(), nil)
+	}
+	code := statusClass(header.StatusCode())
+	if code != "2xx" {
+		return fail(code, fmt.Sprintf("no event stream, status %d", header.StatusCode()), &header)
+	}
+
+	var body io.Reader = br
+	switch cl := header.ContentLength(); {
+	case cl == -1:
+		body = httputil.NewChunkedReader(br)
+	case cl >= 0:
+		body = io.LimitReader(br, int64(cl))
+	}
+	events := 0
+	data := false
+	lines := bufio.NewReader(body)
+	for {
+		r.setEventDeadline(conn)
+		line, err := lines.ReadString('\n')
+		if err != nil {
+			switch {
+			case ctx.Err() != nil:
+				return true
+			case err == io.EOF && events > 0:
+				// the stream ended, another one is opened
+				return true
+			case err == io.EOF:
+				err = errNoEvent
+			case isTimeout(err):
+				err = errors.New("event timeout")
+			}
+			return fail(code, err.Error(), nil)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line != "" {
+			// the comments sent to keep the stream alive are no events
+			data = data || strings.HasPrefix(line, "data")
+			continue
+		}
+		if !data {
+			continue
+		}
+		data = false
+		rr := record(code, "")
+		rr.firstEvent = events == 0
+		events++
+		start = time.Now()
+		if !emit(rr) {
+			return false
+		}
+	}
+}
+
+// setEventDeadline makes the wait for the next line of the stream time out
+// after '--resp-timeout', if given.
+func (r *Requester) setEventDeadline(conn net.Conn) {
+	if r.clientOpt.readTimeout > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(r.clientOpt.readTimeout))
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stableCheckInterval is how often the latency is sampled by '--stop-on-stable'.
+const stableCheckInterval = time.Second
+
+// StableCondition is the latency convergence given to '--stop-on-stable', as
+// in "p99 within 2% for 2m": the run stops once the percentile, or the mean,
+// stayed within the margin of its latest value for the whole window.
+type StableCondition struct {
+	Metric   string
+	Quantile float64 // 0 for the mean
+	Margin   float64
+	Window   time.Duration
+}
+
+type stableSample struct {
+	at    time.Time
+	value float64
+}
+
+func ParseStableCondition(s string) (*StableCondition, error) {
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) != 5 || fields[1] != "within" || fields[3] != "for" || !strings.HasSuffix(fields[2], "%") {
+		return nil, fmt.Errorf("invalid stable condition %q, expected like 'p99 within 2%% for 2m'", s)
+	}
+	c := &StableCondition{Metric: fields[0]}
+	if c.Metric != "mean" {
+		q, err := strconv.ParseFloat(strings.TrimPrefix(c.Metric, "p"), 64)
+		if err != nil || !strings.HasPrefix(c.Metric, "p") {
+			return nil, fmt.Errorf("invalid stable metric %q, expected mean or a percentile like p99", fields[0])
+		}
+		c.Quantile = percentileQuantile(q)
+		if _, ok := quantilesTarget[c.Quantile]; !ok {
+			return nil, fmt.Errorf("unsupported stable percentile %q, the reported ones are %s", fields[0], percentileNames())
+		}
+	}
+	margin, err := strconv.ParseFloat(strings.TrimSuffix(fields[2], "%"), 64)
+	if err != nil || margin <= 0 {
+		return nil, fmt.Errorf("invalid stable margin %q", fields[2])
+	}
+	c.Margin = margin / 100
+	c.Window, err = time.ParseDuration(fields[4])
+	if err != nil || c.Window <= 0 {
+		return nil, fmt.Errorf("invalid stable window %q", fields[4])
+	}
+	return c, nil
+}
+
+func (c *StableCondition) String() string {
+	return fmt.Sprintf("%s within %s%% for %s", c.Metric, strconv.FormatFloat(c.Margin*100, 'f', -1, 64), c.Window)
+}
+
+// Watch samples the latency every second and calls stop once it is stable,
+// unless doneChan is closed first.
+func (c *StableCondition) Watch(latency func(q float64) (float64, bool), stop func(), doneChan <-chan struct{}) {
+	ticker := time.NewTicker(stableCheckInterval)
+	defer ticker.Stop()
+	var samples []stableSample
+	for {
+		select {
+		case <-doneChan:
+			return
+		case now := <-ticker.C:
+			v, ok := latency(c.Quantile)
+			if !ok {
+				continue
+			}
+			samples = append(samples, stableSample{now, v})
+			// keep the samples covering the window, the oldest one from before it
+			for len(samples) > 1 && !samples[1].at.After(now.Add(-c.Window)) {
+				samples = samples[1:]
+			}
+			if now.Sub(samples[0].at) >= c.Window && c.within(samples, v) {
+				stop()
+				return
+			}
+		}
+	}
+}
+
+func (c *StableCondition) within(samples []stableSample, latest float64) bool {
+	for _, s := range samples {
+		if math.Abs(s.value-latest) > c.Margin*latest {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+var statusPath = "/api/status"
+
+// RunConfig is the configuration of the run reported by '/api/status'.
+type RunConfig struct {
+	URLs        []string
+	Method      string
+	Concurrency int
+	Requests    int64         `json:",omitempty"`
+	Duration    time.Duration `json:",omitempty"`
+	Warmup      time.Duration `json:",omitempty"`
+	Schedule    string        `json:",omitempty"`
+	// RunID is the one of '--template' or '--history'
+	RunID string `json:",omitempty"`
+}
+
+// RunStatus is the state of the run answered by '/api/status', so that other
+// tools can follow it. Phase is "running", "paused", "cooldown" or "done", and Progress
+// the part of the requests or the duration done, 0 if the run has no end.
+type RunStatus struct {
+	Phase       string
+	Description string
+	Config      *RunConfig
+	Progress    float64
+	Summary     *SnapshotReport
+}
+
+// StatusTracker follows the phase of the run to answer '/api/status'.
+type StatusTracker struct {
+	desc     string
+	config   *RunConfig
+	snapshot func() *SnapshotReport
+
+	lock  sync.Mutex
+	phase string
+	// paused tells whether the running run is paused, if it can be
+	paused func() bool
+}
+
+func NewStatusTracker(desc string, config *RunConfig, snapshot func() *SnapshotReport) *StatusTracker {
+	return &StatusTracker{desc: desc, config: config, snapshot: snapshot, phase: "running"}
+}
+
+func (s *StatusTracker) SetPhase(phase string) {
+	s.lock.Lock()
+	s.phase = phase
+	s.lock.Unlock()
+}
+
+// FollowPause reports the run as paused while paused returns true.
+func (s *StatusTracker) FollowPause(paused func() bool) {
+	s.lock.Lock()
+	s.paused = paused
+	s.lock.Unlock()
+}
+
+func (s *StatusTracker) Status() *RunStatus {
+	s.lock.Lock()
+	phase := s.phase
+	if phase == "running" && s.paused != nil && s.paused() {
+		phase = "paused"
+	}
+	s.lock.Unlock()
+	rs := &RunStatus{
+		Phase:       phase,
+		Description: s.desc,
+		Config:      s.config,
+		Summary:     s.snapshot(),
+	}
+	switch {
+	case phase != "running" && phase != "paused":
+		rs.Progress = 1
+	case s.config.Duration > 0:
+		rs.Progress = float64(rs.Summary.Elapsed) / float64(s.config.Duration)
+	case s.config.Requests > 0:
+		rs.Progress = float64(rs.Summary.Count) / float64(s.config.Requests)
+	}
+	if rs.Progress > 1 {
+		rs.Progress = 1
+	}
+	return rs
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// stopErrorMaxBody bounds the bodies printed by '--stop-on-error', not to
+// flood the terminal with a download.
+const stopErrorMaxBody = 64 * 1024
+
+// failedRequest tells whether the request of the record failed, with an
+// error, a 4xx or 5xx status, failed checks or protocol violations.
+func failedRequest(rr *ReportRecord) bool {
+	return rr.error != "" || rr.code == "4xx" || rr.code == "5xx" || rr.checksFailed != 0 || len(rr.violations) > 0
+}
+
+// stopOnFailure cancels the run at the first failed request, keeping the
+// exchange to be printed once the run is over.
+func (r *Requester) stopOnFailure(req *fasthttp.Request, resp *fasthttp.Response, body []byte, rr *ReportRecord) {
+	// the requests of '--warmup', before the run, are left to it
+	if !failedRequest(rr) || r.cancel == nil {
+		return
+	}
+	r.failureLock.Lock()
+	defer r.failureLock.Unlock()
+	if r.failure != "" {
+		return
+	}
+	r.failure = formatExchange(req, resp, body, rr, r.clientOpt.checks, stopErrorMaxBody)
+	r.cancel()
+}
+
+// Failure returns the first failed request of '--stop-on-error', "" if none
+// failed.
+func (r *Requester) Failure() string {
+	r.failureLock.Lock()
+	defer r.failureLock.Unlock()
+	return r.failure
+}
+
+// formatExchange lays out a request and its response like curl -v does, the
+// bodies truncated to maxBody bytes, followed by why it failed if it did. The
+// response is left out of the requests failing with an error, which may have
+// got none.
+func formatExchange(req *fasthttp.Request, resp *fasthttp.Response, body []byte, rr *ReportRecord, checks []*Check, maxBody int) string {
+	var buf bytes.Buffer
+	writePrefixed(&buf, "> ", req.Header.Header())
+	if req.IsBodyStream() {
+		buf.WriteString("> (streamed body)\n")
+	} else {
+		writeBody(&buf, "> ", req.Body(), maxBody)
+	}
+	if rr.error == "" {
+		buf.WriteString("\n")
+		writePrefixed(&buf, "< ", resp.Header.Header())
+		writeBody(&buf, "< ", body, maxBody)
+	}
+	buf.WriteString("\n")
+	if rr.error != "" {
+		fmt.Fprintf(&buf, "Error: %s\n", rr.error)
+	} else if rr.code == "4xx" || rr.code == "5xx" {
+		fmt.Fprintf(&buf, "Status: %d\n", resp.StatusCode())
+	}
+	var failed []string
+	for i, c := range checks {
+		if rr.checksFailed&(1<<uint(i)) != 0 {
+			failed = append(failed, c.Name)
+		}
+	}
+	if len(failed) > 0 {
+		fmt.Fprintf(&buf, "Failed checks: %s\n", strings.Join(failed, ", "))
+	}
+	if len(rr.violations) > 0 {
+		fmt.Fprintf(&buf, "Protocol violations: %s\n", strings.Join(rr.violations, ", "))
+	}
+	fmt.Fprintf(&buf, "Latency: %s\n", rr.cost)
+	return buf.String()
+}
+
+// writePrefixed writes the lines of text, the blank lines ending it aside,
+// each after prefix.
+func writePrefixed(buf *bytes.Buffer, prefix string, text []byte) {
+	for _, line := range strings.Split(strings.TrimRight(string(text), "\r\n"), "\n") {
+		buf.WriteString(prefix + strings.TrimSuffix(line, "\r") + "\n")
+	}
+}
+
+func writeBody(buf *bytes.Buffer, prefix string, body []byte, maxBody int) {
+	if len(body) == 0 {
+		return
+	}
+	buf.WriteString(strings.TrimSpace(prefix) + "\n")
+	if len(body) > maxBody {
+		writePrefixed(buf, prefix, body[:maxBody])
+		fmt.Fprintf(buf, "%s... (%d bytes in all)\n", prefix, len(body))
+		return
+	}
+	writePrefixed(buf, prefix, body)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// streamMetrics is an event of '/data/stream', the values of all the views
+// of the page at once.
+type streamMetrics struct {
+	Values map[string][]interface{} `json:"values"`
+	Time   string                   `json:"time"`
+}
+
+// chartsStream takes a snapshot of the charts data once per refresh, however
+// many pages follow it, and pushes it to all of them.
+type chartsStream struct {
+	lock        sync.Mutex
+	subscribers map[chan []byte]struct{}
+}
+
+func newChartsStream() *chartsStream {
+	return &chartsStream{subscribers: make(map[chan []byte]struct{})}
+}
+
+func (s *chartsStream) subscribe() chan []byte {
+	// a page too slow to take an event misses it rather than holding up the
+	// others
+	ch := make(chan []byte, 1)
+	s.lock.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.lock.Unlock()
+	return ch
+}
+
+func (s *chartsStream) unsubscribe(ch chan []byte) {
+	s.lock.Lock()
+	delete(s.subscribers, ch)
+	s.lock.Unlock()
+}
+
+func (s *chartsStream) run(dataFunc func() *ChartsReport, views []string, values func(view string, data *ChartsReport) []interface{}) {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.lock.Lock()
+		n := len(s.subscribers)
+		s.lock.Unlock()
+		if n == 0 {
+			continue
+		}
+		data := dataFunc()
+		metrics := &streamMetrics{
+			Values: make(map[string][]interface{}, len(views)),
+			Time:   time.Now().Format(timeFormat),
+		}
+		for _, view := range views {
+			metrics.Values[view] = values(view, data)
+		}
+		event, err := json.Marshal(metrics)
+		if err != nil {
+			continue
+		}
+		s.lock.Lock()
+		for ch := range s.subscribers {
+			select {
+			case ch <- event:
+			default:
+			}
+		}
+		s.lock.Unlock()
+	}
+}
+
+// serveStream streams the charts data as server-sent events until the page
+// is gone.
+func (c *Charts) serveStream(ctx *fasthttp.RequestCtx) {
+	ctx.SetContentType("text/event-stream")
+	ctx.Response.Header.Set("Cache-Control", "no-cache")
+	ch := c.stream.subscribe()
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer c.stream.unsubscribe(ch)
+		for event := range ch {
+			_, _ = w.WriteString("data: ")
+			_, _ = w.Write(event)
+			_, _ = w.WriteString("\n\n")
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+}
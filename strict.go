@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// maxStrictLine caps the status, header and chunk size lines kept by '--strict'.
+const maxStrictLine = 64 * 1024
+
+const (
+	strictIdle = iota
+	strictStatus
+	strictHeaders
+	strictBody
+	strictChunkSize
+	strictChunkData
+	strictChunkEnd
+	strictTrailers
+	strictUntilClose
+	strictDone
+	// strictBroken ignores the rest of the response once its framing is lost
+	strictBroken
+)
+
+// StrictDial follows the responses read from the connections of dial to
+// report the violations of the HTTP/1.1 syntax and framing to t, including
+// those fasthttp tolerates or reports as generic errors.
+func StrictDial(dial fasthttp.DialFunc, t *connTracker) fasthttp.DialFunc {
+	return func(addr string) (net.Conn, error) {
+		conn, err := dial(addr)
+		if err != nil {
+			return nil, err
+		}
+		return &strictConn{Conn: conn, parser: strictParser{tracker: t}}, nil
+	}
+}
+
+type strictConn struct {
+	net.Conn
+	parser strictParser
+}
+
+func (c *strictConn) Write(b []byte) (int, error) {
+	c.parser.request(b)
+	return c.Conn.Write(b)
+}
+
+func (c *strictConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.parser.feed(b[:n])
+	if err == io.EOF {
+		c.parser.closed()
+	}
+	return n, err
+}
+
+type strictParser struct {
+	tracker *connTracker
+
+	state    int
+	inFlight bool
+	received bool
+	head     bool
+	line     []byte
+	lineLong bool
+
+	status        int
+	contentLength int64
+	chunked       bool
+	remaining     int64
+	crlf          int
+}
+
+func (p *strictParser) violation(kind string) {
+	p.tracker.addViolation(kind)
+}
+
+// request starts following a response once a new request is written, the
+// writes of its body excepted.
+func (p *strictParser) request(b []byte) {
+	if p.inFlight {
+		return
+	}
+	p.inFlight = true
+	p.received = false
+	p.head = bytes.HasPrefix(b, []byte("HEAD "))
+	p.startResponse()
+}
+
+func (p *strictParser) startResponse() {
+	p.state = strictStatus
+	p.line = p.line[:0]
+	p.lineLong = false
+	p.status = 0
+	p.contentLength = -1
+	p.chunked = false
+}
+
+// broken gives up on the response, the next request starts over.
+func (p *strictParser) broken(kind string) {
+	p.violation(kind)
+	p.state = strictBroken
+	p.inFlight = false
+}
+
+func (p *strictParser) finish() {
+	p.state = strictDone
+	p.inFlight = false
+}
+
+func (p *strictParser) closed() {
+	switch {
+	case p.state == strictUntilClose:
+		p.finish()
+	case p.inFlight && p.received && p.state != strictBroken:
+		p.violation("connection closed mid-response")
+		p.finish()
+	}
+}
+
+func (p *strictParser) feed(b []byte) {
+	if len(b) > 0 {
+		p.received = true
+	}
+	for len(b) > 0 {
+		switch p.state {
+		case strictIdle, strictDone:
+			p.broken("data after response")
+			return
+		case strictBroken, strictUntilClose:
+			return
+		case strictBody, strictChunkData:
+			n := int64(len(b))
+			if n > p.remaining {
+				n = p.remaining
+			}
+			b = b[n:]
+			p.remaining -= n
+			if p.remaining == 0 {
+				if p.state == strictBody {
+					p.finish()
+				} else {
+					p.state = strictChunkEnd
+					p.crlf = 0
+				}
+			}
+		case strictChunkEnd:
+			if b[0] != "\r\n"[p.crlf] {
+				p.broken("missing CRLF after chunk data")
+				return
+			}
+			b = b[1:]
+			if p.crlf++; p.crlf == 2 {
+				p.state = strictChunkSize
+			}
+		default:
+			i := bytes.IndexByte(b, '\n')
+			if i < 0 {
+				p.appendLine(b)
+				return
+			}
+			p.appendLine(b[:i+1])
+			b = b[i+1:]
+			p.parseLine()
+			p.line = p.line[:0]
+			p.lineLong = false
+		}
+	}
+}
+
+func (p *strictParser) appendLine(b []byte) {
+	if len(p.line)+len(b) > maxStrictLine {
+		p.lineLong = true
+		return
+	}
+	p.line = append(p.line, b...)
+}
+
+func (p *strictParser) parseLine() {
+	if p.lineLong {
+		p.broken("line too long")
+		return
+	}
+	line := p.line[:len(p.line)-1]
+	if len(line) == 0 || line[len(line)-1] != '\r' {
+		p.violation("bare LF line ending")
+	} else {
+		line = line[:len(line)-1]
+	}
+	switch p.state {
+	case strictStatus:
+		p.parseStatus(line)
+	case strictHeaders, strictTrailers:
+		p.parseHeader(line)
+	case strictChunkSize:
+		p.parseChunkSize(line)
+	}
+}
+
+func (p *strictParser) parseStatus(line []byte) {
+	// HTTP/1.1 200 OK, the reason phrase may be empty but not its separator
+	if len(line) < 13 || !bytes.HasPrefix(line, []byte("HTTP/1.")) || (line[7] != '0' && line[7] != '1') ||
+		line[8] != ' ' || line[12] != ' ' {
+		p.broken("invalid status line")
+		return
+	}
+	status, err := strconv.Atoi(string(line[9:12]))
+	if err != nil || status < 100 {
+		p.broken("invalid status line")
+		return
+	}
+	p.status = status
+	p.state = strictHeaders
+}
+
+func isTokenChar(c byte) bool {
+	return c > ' ' && c < 0x7f && !strings.ContainsRune("\"(),/:;<=>?@[\\]{}", rune(c))
+}
+
+func (p *strictParser) parseHeader(line []byte) {
+	if len(line) == 0 {
+		if p.state == strictTrailers {
+			p.finish()
+		} else {
+			p.endHeaders()
+		}
+		return
+	}
+	if line[0] == ' ' || line[0] == '\t' {
+		p.violation("obsolete header line folding")
+		return
+	}
+	i := bytes.IndexByte(line, ':')
+	if i <= 0 {
+		p.violation("invalid header line")
+		return
+	}
+	name := line[:i]
+	for _, c := range name {
+		if !isTokenChar(c) {
+			p.violation("invalid header name")
+			return
+		}
+	}
+	value := bytes.Trim(line[i+1:], " \t")
+	for _, c := range value {
+		if (c < ' ' && c != '\t') || c == 0x7f {
+			p.violation("invalid header value")
+			return
+		}
+	}
+	if p.state == strictTrailers {
+		return
+	}
+	switch strings.ToLower(string(name)) {
+	case "content-length":
+		n, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil || n < 0 {
+			p.violation("invalid Content-Length")
+			return
+		}
+		if p.contentLength >= 0 && p.contentLength != n {
+			p.violation("conflicting Content-Length")
+		}
+		p.contentLength = n
+	case "transfer-encoding":
+		codings := strings.Split(string(value), ",")
+		p.chunked = strings.EqualFold(strings.TrimSpace(codings[len(codings)-1]), "chunked")
+	}
+}
+
+func (p *strictParser) endHeaders() {
+	switch {
+	case p.status < 200:
+		// an interim response, the final one follows
+		p.startResponse()
+		return
+	case p.head || p.status == 204 || p.status == 304:
+		p.finish()
+		return
+	}
+	if p.chunked && p.contentLength >= 0 {
+		p.violation("Content-Length with Transfer-Encoding")
+	}
+	switch {
+	case p.chunked:
+		p.state = strictChunkSize
+	case p.contentLength == 0:
+		p.finish()
+	case p.contentLength > 0:
+		p.state = strictBody
+		p.remaining = p.contentLength
+	default:
+		p.state = strictUntilClose
+	}
+}
+
+func (p *strictParser) parseChunkSize(line []byte) {
+	if i := bytes.IndexByte(line, ';'); i >= 0 {
+		line = line[:i]
+	}
+	size, err := strconv.ParseInt(string(bytes.TrimRight(line, " \t")), 16, 64)
+	if err != nil || size < 0 {
+		p.broken("invalid chunk size")
+		return
+	}
+	if size == 0 {
+		p.state = strictTrailers
+		return
+	}
+	p.state = strictChunkData
+	p.remaining = size
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ParseSweepLevels parses the increasing concurrency levels of 'sweep', like
+// 1,2,4,8.
+func ParseSweepLevels(s string) ([]int, error) {
+	var levels []int
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid concurrency level %q, expected a positive number", f)
+		}
+		if len(levels) > 0 && n <= levels[len(levels)-1] {
+			return nil, fmt.Errorf("invalid concurrency levels %q, expected increasing ones", s)
+		}
+		levels = append(levels, n)
+	}
+	return levels, nil
+}
+
+// sweepResult is the outcome of the run at a concurrency level.
+type sweepResult struct {
+	Concurrency int
+	Count       int64
+	Errors      int64
+	RPS         float64
+	Mean        time.Duration
+	P50         time.Duration
+	P99         time.Duration
+}
+
+func newSweepResult(concurrency int, rs *SnapshotReport) *sweepResult {
+	sr := &sweepResult{Concurrency: concurrency, Count: rs.Count, RPS: rs.RPS, Mean: rs.Stats.Mean}
+	for _, n := range rs.Errors {
+		sr.Errors += n
+	}
+	for _, p := range rs.Percentiles {
+		switch p.Percentile {
+		case 0.50:
+			sr.P50 = p.Latency
+		case 0.99:
+			sr.P99 = p.Latency
+		}
+	}
+	return sr
+}
+
+// runSweepLevel runs the benchmark of the options at a concurrency level.
+func runSweepLevel(concurrencyLevel int) *SnapshotReport {
+	*concurrency = concurrencyLevel
+	requester, _ := buildRequester()
+	if *warmup > 0 {
+		requester.Warmup()
+	} else if *prewarm {
+		_, _, _ = requester.Prewarm()
+	}
+	go requester.Run()
+	report := NewStreamReport()
+	if *burnIn {
+		report.BurnIn()
+	}
+	report.SetChecks(requester.clientOpt.checks)
+	if *reportConns > 0 {
+		report.ReportConnections(*reportConns)
+	}
+	if *sse {
+		report.Events(concurrencyLevel)
+	}
+	go report.Collect(requester.RecordChan())
+	<-report.Done()
+	return report.Snapshot()
+}
+
+// runSweep runs the benchmark at every concurrency level in turn, stopping
+// once the RPS grows less than saturation percent over the previous level.
+func runSweep(levels []int, saturation float64) {
+	if *duration <= 0 && *requests < 0 {
+		errAndExit("sweep requires a duration or a number of requests for every level")
+		return
+	}
+	if *schedule != "" || *cooldown > 0 {
+		errAndExit("can not use schedule or cooldown with sweep")
+		return
+	}
+	// the Web UI and its control endpoints follow a single run
+	*chartsListenAddr = ""
+	if *waitReady != "" {
+		fmt.Printf("Waiting for %s to be ready...\n\n", *waitReady)
+		requester, _ := buildRequester()
+		if err := requester.WaitReady(*waitReady, *waitTimeout); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+	}
+
+	var perLevel string
+	if *duration > 0 {
+		perLevel = fmt.Sprintf("for %s", duration.String())
+	} else {
+		perLevel = fmt.Sprintf("with %d request(s)", *requests)
+	}
+	levelStrs := make([]string, len(levels))
+	for i, l := range levels {
+		levelStrs[i] = strconv.Itoa(l)
+	}
+	fmt.Printf("Sweeping %s %s per level over %s connection(s).\n\n",
+		strings.Join(*urls, ", "), perLevel, strings.Join(levelStrs, ", "))
+
+	// stop the sweep along with the level running
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	var results []*sweepResult
+	var stopped string
+	for _, level := range levels {
+		if *warmup > 0 {
+			fmt.Printf("Warming up %d connection(s) for %s...\n", level, warmup.String())
+		}
+		sr := newSweepResult(level, runSweepLevel(level))
+		fmt.Printf("%d connection(s): %s RPS, P99 %s, %d error(s)\n",
+			level, rateToString(sr.RPS), durationToString(sr.P99, *seconds), sr.Errors)
+		results = append(results, sr)
+
+		select {
+		case <-sigs:
+			stopped = "Interrupted."
+		default:
+		}
+		if stopped != "" {
+			break
+		}
+		if n := len(results); saturation > 0 && n > 1 {
+			prev := results[n-2]
+			if growth := (sr.RPS/prev.RPS - 1) * 100; growth < saturation {
+				stopped = fmt.Sprintf("Stopped at %d connection(s) as saturated: the RPS grew by %.1f%% only.", level, growth)
+				break
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("\nSweep:\n")
+	writeBulk(&buf, buildSweepTable(results, *seconds))
+	os.Stdout.Write(buf.Bytes())
+	if stopped != "" {
+		fmt.Printf("\n%s\n", stopped)
+	}
+}
+
+// buildSweepTable lays out the results of the levels with a bar of their RPS.
+func buildSweepTable(results []*sweepResult, useSeconds bool) [][]string {
+	var maxRPS float64
+	for _, sr := range results {
+		if sr.RPS > maxRPS {
+			maxRPS = sr.RPS
+		}
+	}
+	bulk := [][]string{{"Concurrency", "RPS", "Mean", "P50", "P99", "Errors", ""}}
+	for _, sr := range results {
+		barLen := 0
+		if maxRPS > 0 {
+			barLen = int(sr.RPS/maxRPS*float64(maxBarLen) + 0.5)
+		}
+		errs := strconv.FormatInt(sr.Errors, 10)
+		if sr.Errors > 0 {
+			errs = colorize(errs, FgMagentaColor)
+		}
+		bulk = append(bulk, []string{
+			strconv.Itoa(sr.Concurrency),
+			rateToString(sr.RPS),
+			durationToString(sr.Mean, useSeconds),
+			durationToString(sr.P50, useSeconds),
+			durationToString(sr.P99, useSeconds),
+			errs,
+			strings.Repeat(barBody, barLen),
+		})
+	}
+	alignBulk(bulk, AlignRight, AlignRight, AlignRight, AlignRight, AlignRight, AlignRight, AlignLeft)
+	return bulk
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/valyala/fasthttp"
+)
+
+// templateFuncs are the functions available to '--template' on top of the
+// builtin ones of text/template.
+func templateFuncs(faker *Faker) template.FuncMap {
+	return template.FuncMap{
+		"fakeName":      faker.Name,
+		"fakeFirstName": faker.FirstName,
+		"fakeLastName":  faker.LastName,
+		"fakeEmail":     faker.Email,
+		"fakePhone":     faker.Phone,
+		"fakeAddress":   faker.Address,
+
+		"mod":   templateMod,
+		"add":   templateAdd,
+		"mul":   templateMul,
+		"every": templateEvery,
+	}
+}
+
+// templateData is what templates are executed with, the counters start at 0.
+type templateData struct {
+	// Run is the same for all the requests of the run
+	Run *templateRun
+	// Worker is the connection of '--concurrency' sending the request
+	Worker *templateWorker
+	// Vars are the values of '--var', rendered once at the start of the run
+	Vars map[string]string
+
+	// Request counts the requests of the run
+	Request int64
+	// ConnRequest counts the requests of the connection, one of '--concurrency'
+	ConnRequest int64
+}
+
+type templateRun struct {
+	ID string
+}
+
+// templateWorker lets a connection take its own part of the values, like
+// {{add .Worker.Index (mul .Worker.Count .ConnRequest)}}.
+type templateWorker struct {
+	// Index is the one of the connection, from 0 to Count-1
+	Index int64
+	Count int64
+}
+
+// newRunID returns a random ID of the run, unless given with '--run-id'.
+func newRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// newRequestID returns a random UUID, the ID of a request sent in the header
+// of '--request-id-header'.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+var varNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseTemplateVars renders the NAME=VALUE values of '--var' in order, each
+// one with the run and the values before it.
+func ParseTemplateVars(specs []string, funcs template.FuncMap, run *templateRun) (map[string]string, error) {
+	vars := make(map[string]string, len(specs))
+	data := &templateData{Run: run, Vars: vars}
+	for _, spec := range specs {
+		n := strings.SplitN(spec, "=", 2)
+		if len(n) != 2 || !varNameRe.MatchString(n[0]) {
+			return nil, fmt.Errorf("invalid var %q, expected NAME=VALUE with NAME made of letters, digits and _", spec)
+		}
+		if _, ok := vars[n[0]]; ok {
+			return nil, fmt.Errorf("duplicate var %q", n[0])
+		}
+		tmpl, err := parseTemplate(n[0], n[1], funcs)
+		if err != nil {
+			return nil, err
+		}
+		value, err := executeTemplate(tmpl, data)
+		if err != nil {
+			return nil, err
+		}
+		vars[n[0]] = string(value)
+	}
+	return vars, nil
+}
+
+func templateMod(a, b int64) (int64, error) {
+	if b == 0 {
+		return 0, fmt.Errorf("mod by zero")
+	}
+	return a % b, nil
+}
+
+func templateAdd(a, b int64) int64 {
+	return a + b
+}
+
+func templateMul(a, b int64) int64 {
+	return a * b
+}
+
+// templateEvery reports whether i is a multiple of n, as in
+// {{if every 10 .Request}}, true for 1 request in n.
+func templateEvery(n, i int64) (bool, error) {
+	if n <= 0 {
+		return false, fmt.Errorf("every needs a positive count, got %d", n)
+	}
+	return i%n == 0, nil
+}
+
+func hasTemplate(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+func parseTemplate(name, text string, funcs template.FuncMap) (*template.Template, error) {
+	return template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+}
+
+func executeTemplate(tmpl *template.Template, data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// rawRequestURI returns the request URI of rawURL as given, since parsing the
+// URL escapes the braces of template actions.
+func rawRequestURI(rawURL string) string {
+	s := rawURL
+	if i := strings.Index(s, "://"); i >= 0 {
+		s = s[i+3:]
+	}
+	if i := strings.IndexByte(s, '#'); i >= 0 {
+		s = s[:i]
+	}
+	i := strings.IndexAny(s, "/?")
+	if i < 0 {
+		return "/"
+	}
+	if s[i] == '?' {
+		return "/" + s[i:]
+	}
+	return s[i:]
+}
+
+// RequestTemplate renders the request URI and the header values of a target
+// which contain template actions, anew for every request.
+type RequestTemplate struct {
+	uri     *template.Template
+	headers []headerTemplate
+}
+
+type headerTemplate struct {
+	key   string
+	value *template.Template
+}
+
+// NewRequestTemplate returns nil if neither the URI nor a header value is a
+// template.
+func NewRequestTemplate(rawURI string, header *fasthttp.RequestHeader, funcs template.FuncMap) (*RequestTemplate, error) {
+	t := &RequestTemplate{}
+	var err error
+	if hasTemplate(rawURI) {
+		if t.uri, err = parseTemplate("url", rawURI, funcs); err != nil {
+			return nil, err
+		}
+	}
+	header.VisitAll(func(key, value []byte) {
+		if err != nil || !hasTemplate(string(value)) {
+			return
+		}
+		var tmpl *template.Template
+		if tmpl, err = parseTemplate(string(key), string(value), funcs); err == nil {
+			t.headers = append(t.headers, headerTemplate{string(key), tmpl})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if t.uri == nil && len(t.headers) == 0 {
+		return nil, nil
+	}
+	return t, nil
+}
+
+func (t *RequestTemplate) Render(req *fasthttp.Request, data interface{}) error {
+	if t.uri != nil {
+		uri, err := executeTemplate(t.uri, data)
+		if err != nil {
+			return err
+		}
+		req.SetRequestURIBytes(uri)
+	}
+	for _, h := range t.headers {
+		value, err := executeTemplate(h.value, data)
+		if err != nil {
+			return err
+		}
+		req.Header.SetBytesV(h.key, value)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"time"
+
+	"github.com/beorn7/perks/histogram"
+	"github.com/beorn7/perks/quantile"
+)
+
+// timingStats are the stats, percentiles and histogram of the time to the
+// first or to the last byte of the responses of '--report-ttfb'.
+type timingStats struct {
+	stats     Stats
+	quantile  *quantile.Stream
+	histogram *histogram.Histogram
+}
+
+func newTimingStats() *timingStats {
+	return &timingStats{quantile: quantile.NewTargeted(quantilesTarget), histogram: histogram.New(8)}
+}
+
+func (t *timingStats) add(d time.Duration) {
+	t.stats.Update(float64(d))
+	t.quantile.Insert(float64(d))
+	t.histogram.Insert(float64(d))
+}
+
+// timingCopy is a timingStats as of a snapshot.
+type timingCopy struct {
+	stats       Stats
+	percentiles []float64
+	bins        []histogram.Bin
+}
+
+// copy must be called with the lock of the report held, the percentiles
+// given once rc is queried.
+func (t *timingStats) copy(rc *reportCopy) *timingCopy {
+	c := &timingCopy{stats: t.stats}
+	if t.stats.count > 0 {
+		rc.queryLater(t.quantile, quantilesTarget, func(q *quantileCopy) {
+			c.percentiles = queryQuantiles(q)
+		})
+		c.bins = copyBins(t.histogram)
+	}
+	return c
+}
+
+// TimingReport is the time from the start of the requests to the first byte
+// of their responses, TTFB, or to the last one, TTLB, which once apart tell
+// the time the server took to answer from the time its answer took to come.
+type TimingReport struct {
+	Stats *struct {
+		Min    time.Duration
+		Mean   time.Duration
+		StdDev time.Duration
+		Max    time.Duration
+	}
+	Percentiles []*struct {
+		Percentile float64
+		Latency    time.Duration
+	}
+	Histograms []*struct {
+		Mean  time.Duration
+		Count int
+	}
+}
+
+func (t *timingCopy) report() *TimingReport {
+	tr := &TimingReport{
+		Stats: &struct {
+			Min    time.Duration
+			Mean   time.Duration
+			StdDev time.Duration
+			Max    time.Duration
+		}{time.Duration(t.stats.min), time.Duration(t.stats.Mean()),
+			time.Duration(t.stats.Stddev()), time.Duration(t.stats.max)},
+		Histograms: histogramReport(t.bins),
+	}
+	for i, p := range t.percentiles {
+		tr.Percentiles = append(tr.Percentiles, &struct {
+			Percentile float64
+			Latency    time.Duration
+		}{quantiles[i], time.Duration(p)})
+	}
+	return tr
+}
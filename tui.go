@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// sparkBlocks are the heights of the bars of a sparkline, lowest first.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// tuiHelp lists the keys of the terminal UI.
+const tuiHelp = "space pause/resume   ↑/↓ or +/- concurrency   →/← or ]/[ rate ±10%   u no rate limit   q quit"
+
+// tui is the full-screen terminal UI of '--tui', showing the run live and
+// adjusting it with keys, like the Web UI does for a browser.
+type tui struct {
+	control    RunControl
+	paused     func() bool
+	desc       string
+	useSeconds bool
+
+	// concurrency is the one set out of maxConcurrency, rate the one set, 0
+	// for no limit
+	concurrency    int
+	maxConcurrency int
+	rate           float64
+	quitting       bool
+	// note is the outcome of the last key, like an error
+	note string
+
+	// rps are the RPS of the last seconds, for the sparkline
+	rps       []float64
+	lastCount int64
+	lastAt    time.Time
+}
+
+func newTUI(control RunControl, paused func() bool, desc string, concurrency int, useSeconds bool) *tui {
+	return &tui{control: control, paused: paused, desc: desc, useSeconds: useSeconds,
+		concurrency: concurrency, maxConcurrency: concurrency, lastAt: time.Now()}
+}
+
+// Loop draws the run every interval until done, on the alternate screen of the
+// terminal which is put back as it was once done.
+func (t *tui) Loop(snapshot func() *SnapshotReport, interval time.Duration, done <-chan struct{}) {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		errAndExit("tui: " + err.Error())
+		return
+	}
+	os.Stdout.WriteString("\033[?1049h\033[?25l")
+	defer func() {
+		os.Stdout.WriteString("\033[?25h\033[?1049l")
+		_ = term.Restore(fd, state)
+	}()
+
+	keys := make(chan string)
+	go readKeys(os.Stdin, keys)
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	sampler := time.NewTicker(time.Second)
+	defer sampler.Stop()
+	rs := snapshot()
+	for {
+		t.draw(rs)
+		select {
+		case <-ticker.C:
+			rs = snapshot()
+		case <-sampler.C:
+			rs = snapshot()
+			t.sample(rs)
+		case k := <-keys:
+			t.key(k, rs)
+		case <-done:
+			return
+		}
+	}
+}
+
+// readKeys sends the keys read from r, the escape sequences of the arrows
+// named like "up".
+func readKeys(r io.Reader, keys chan<- string) {
+	b := make([]byte, 16)
+	for {
+		n, err := r.Read(b)
+		if err != nil {
+			return
+		}
+		switch s := string(b[:n]); s {
+		case "\033[A":
+			keys <- "up"
+		case "\033[B":
+			keys <- "down"
+		case "\033[C":
+			keys <- "right"
+		case "\033[D":
+			keys <- "left"
+		default:
+			for _, c := range s {
+				keys <- string(c)
+			}
+		}
+	}
+}
+
+// sample adds the RPS of the last second to the sparkline.
+func (t *tui) sample(rs *SnapshotReport) {
+	now := time.Now()
+	if elapsed := now.Sub(t.lastAt).Seconds(); elapsed > 0 {
+		t.rps = append(t.rps, float64(rs.Count-t.lastCount)/elapsed)
+	}
+	t.lastCount, t.lastAt = rs.Count, now
+	// more than any terminal is wide
+	if len(t.rps) > 1000 {
+		t.rps = t.rps[len(t.rps)-1000:]
+	}
+}
+
+// key applies the key pressed, ctrl-c quitting as q does as the terminal is
+// raw.
+func (t *tui) key(k string, rs *SnapshotReport) {
+	var err error
+	t.note = ""
+	switch k {
+	case " ", "p":
+		err = t.control.Pause(!t.paused())
+	case "up", "+", "=":
+		if t.concurrency < t.maxConcurrency {
+			t.concurrency++
+			err = t.control.SetConcurrency(t.concurrency)
+		}
+	case "down", "-":
+		if t.concurrency > 1 {
+			t.concurrency--
+			err = t.control.SetConcurrency(t.concurrency)
+		}
+	case "right", "]", "left", "[":
+		rate := t.rate
+		if rate == 0 {
+			// the limit starts from the throughput without any
+			rate = rs.RPS
+			if len(t.rps) > 0 {
+				rate = t.rps[len(t.rps)-1]
+			}
+		}
+		if k == "right" || k == "]" {
+			rate *= 1.1
+		} else {
+			rate *= 0.9
+		}
+		if rate < 1 {
+			rate = 1
+		}
+		if err = t.control.SetRate(rate); err == nil {
+			t.rate = rate
+		}
+	case "u":
+		if err = t.control.SetRate(0); err == nil {
+			t.rate = 0
+		}
+	case "q", "\x03":
+		if !t.quitting {
+			t.quitting = true
+			t.control.Cancel()
+		}
+	}
+	if err != nil {
+		t.note = err.Error()
+	}
+}
+
+// sparkline draws the last width values, scaled to the highest.
+func sparkline(values []float64, width int) string {
+	if width < 1 {
+		width = 1
+	}
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	var max float64
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	var sb strings.Builder
+	for _, v := range values {
+		i := 0
+		if max > 0 {
+			i = int(v / max * float64(len(sparkBlocks)-1))
+		}
+		sb.WriteRune(sparkBlocks[i])
+	}
+	return sb.String()
+}
+
+func (t *tui) draw(rs *SnapshotReport) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+	lines := t.lines(rs, width)
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	var buf bytes.Buffer
+	buf.WriteString("\033[H")
+	for i, line := range lines {
+		if i > 0 {
+			buf.WriteString("\r\n")
+		}
+		buf.WriteString(line)
+		buf.WriteString("\033[K")
+	}
+	buf.WriteString("\033[J")
+	os.Stdout.Write(buf.Bytes())
+}
+
+// lines lays out the screen: the state of the run, the sparkline of the RPS,
+// the percentiles and the histogram of the latency, and the keys.
+func (t *tui) lines(rs *SnapshotReport, width int) []string {
+	var errs int64
+	for _, n := range rs.Errors {
+		errs += n
+	}
+	state := "running"
+	switch {
+	case t.quitting:
+		state = "stopping"
+	case t.paused():
+		state = colorize("paused", FgYellowColor)
+	}
+	rate := "no limit"
+	if t.rate > 0 {
+		rate = rateToString(t.rate) + "/s"
+	}
+	errsText := fmt.Sprintf("%d", errs)
+	if errs > 0 {
+		errsText = colorize(errsText, FgMagentaColor)
+	}
+	lines := []string{
+		colorize("plow", FgCyanColor) + " " + t.desc,
+		"",
+		fmt.Sprintf("Elapsed %s   Count %d   RPS %s   Errors %s",
+			rs.Elapsed.Truncate(100*time.Millisecond), rs.Count, rateToString(rs.RPS), errsText),
+		fmt.Sprintf("Concurrency %d/%d   Rate %s   %s", t.concurrency, t.maxConcurrency, rate, state),
+		"",
+	}
+	last := 0.0
+	if len(t.rps) > 0 {
+		last = t.rps[len(t.rps)-1]
+	}
+	lines = append(lines, fmt.Sprintf("RPS %s %s", sparkline(t.rps, width-16), rateToString(last)), "")
+
+	var buf bytes.Buffer
+	if len(rs.Percentiles) > 0 {
+		buf.WriteString("Latency Percentile:\n")
+		writeBulk(&buf, buildPercentileBulk(rs.Percentiles, t.useSeconds))
+		buf.WriteString("\nLatency Histogram:\n")
+		writeBulk(&buf, (&Printer{}).buildHistogram(rs.Histograms, t.useSeconds, false))
+	}
+	lines = append(lines, strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")...)
+	lines = append(lines, "", tuiHelp)
+	if t.note != "" {
+		lines = append(lines, colorize(t.note, FgRedColor))
+	}
+	return lines
+}
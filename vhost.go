@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync/atomic"
+)
+
+// ParseHosts parses the Host headers of '--host', a comma-separated list or a
+// @file of one per line, blank lines and # comments left out.
+func ParseHosts(spec string) ([]string, error) {
+	var list []string
+	if strings.HasPrefix(spec, "@") {
+		data, err := ioutil.ReadFile(spec[1:])
+		if err != nil {
+			return nil, err
+		}
+		list = strings.Split(string(data), "\n")
+	} else {
+		list = strings.Split(spec, ",")
+	}
+	var hosts []string
+	for _, h := range list {
+		if h = strings.TrimSpace(h); h != "" && !strings.HasPrefix(h, "#") {
+			hosts = append(hosts, h)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no host in %q", spec)
+	}
+	return hosts, nil
+}
+
+// nextHost returns the Host header of the next request, rotating over those
+// of '--host' in order.
+func (r *Requester) nextHost() string {
+	n := atomic.AddInt64(&r.hostCount, 1) - 1
+	return r.clientOpt.hosts[n%int64(len(r.clientOpt.hosts))]
+}